@@ -3,6 +3,7 @@
 package api
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"github.com/starchain/contracts"
@@ -13,8 +14,32 @@ import (
 	"strings"
 )
 
+// AddressDto is the owner of the challenge: single address,
+// or threshold of owners for co-owned stars
 type AddressDto struct {
-	Address string `json:"address"`
+	Address   string              `json:"address"`
+	Owners    []string            `json:"owners,omitempty"`
+	Threshold int                 `json:"threshold,omitempty"`
+	Star      *StarCoordinatesDto `json:"star,omitempty"`
+}
+
+type StarCoordinatesDto struct {
+	RA  string `json:"ra"`
+	Dec string `json:"dec"`
+}
+
+type ChallengeDto struct {
+	Address      string `json:"address"`
+	Message      string `json:"message"`
+	IssuedAt     int64  `json:"issuedAt"`
+	ExpiresAt    int64  `json:"expiresAt"`
+	ValidFor     int64  `json:"validFor"`
+	MaxClockSkew int64  `json:"maxClockSkew"`
+}
+
+type BlockRangeDto struct {
+	ChainHeight int               `json:"chainHeight"`
+	Blocks      []json.RawMessage `json:"blocks"`
 }
 
 type BlockDto struct {
@@ -24,45 +49,160 @@ type BlockDto struct {
 	Owner             string `json:"owner"`
 	PreviousBlockHash string `json:"previousBlockHash"`
 	Time              int64  `json:"time"`
+	Signature         string `json:"signature"`
+	KeyID             string `json:"keyId"`
+	Message           string `json:"message,omitempty"`
+	MessageSignature  string `json:"messageSignature,omitempty"`
+	HashVersion       int    `json:"hashVersion"`
+	Difficulty        uint64 `json:"difficulty,omitempty"`
+	Nonce             uint64 `json:"nonce,omitempty"`
 }
 
 type StarDto struct {
-	Address   string          `json:"address"`
-	Message   string          `json:"message"`
-	Data      json.RawMessage `json:"star"`
-	Signature string          `json:"signature"`
+	Address    string            `json:"address"`
+	Message    string            `json:"message"`
+	Data       json.RawMessage   `json:"star"`
+	Signature  string            `json:"signature"`
+	Signatures map[string]string `json:"signatures,omitempty"`
+}
+
+type InclusionProofDto struct {
+	Height    int      `json:"height"`
+	BlockHash string   `json:"blockHash"`
+	Root      string   `json:"root"`
+	Size      uint64   `json:"size"`
+	Path      []string `json:"path"`
+	Peaks     []string `json:"peaks"`
+}
+
+type TreeHeadDto struct {
+	Height    int    `json:"height"`
+	HeadHash  string `json:"headHash"`
+	Root      string `json:"root"`
+	Timestamp int64  `json:"timestamp"`
+	KeyID     string `json:"keyId"`
+	Signature string `json:"signature"`
+}
+
+type TreeHeadConsistencyDto struct {
+	OldHeight   int        `json:"oldHeight"`
+	NewHeight   int        `json:"newHeight"`
+	OldRoot     string     `json:"oldRoot"`
+	NewRoot     string     `json:"newRoot"`
+	OldPeaks    []string   `json:"oldPeaks"`
+	Paths       [][]string `json:"paths"`
+	NewPeaks    []string   `json:"newPeaks"`
+	OldHeadPath []string   `json:"oldHeadPath"`
+	NewHeadPath []string   `json:"newHeadPath"`
 }
 
 type ValidationDto struct {
-	Valid    bool     `json:"valid"`
-	ErrorLog []string `json:"errorLog"`
-}
-
-var blockchain *contracts.BlockchainOperator
-
-func Create(b *contracts.BlockchainOperator) http.Handler {
-	blockchain = b
-	api := newRestApi()
-	api.Add("GET /hello", hello)
-	api.Add("GET /block/\\d+", getBlockByHeight)
-	api.Add("GET /block/hash/\\w+", getBlockByHash)
-	api.Add("GET /blocks/\\w+", getBlocks)
-	api.Add("POST /requestvalidation", requestValidation)
-	api.Add("POST /submitstar", submitStar)
-	api.Add("GET /validate", validate)
+	Valid    bool         `json:"valid"`
+	ErrorLog []string     `json:"errorLog"`
+	Findings []FindingDto `json:"findings"`
+}
+
+type FindingDto struct {
+	Kind     string `json:"kind"`
+	Height   int    `json:"height"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Message  string `json:"message"`
+}
+
+type ValidationJobDto struct {
+	ID         string       `json:"id"`
+	State      string       `json:"state"`
+	Checked    int          `json:"checked"`
+	Total      int          `json:"total"`
+	Valid      bool         `json:"valid"`
+	ErrorLog   []string     `json:"errorLog"`
+	Findings   []FindingDto `json:"findings"`
+	StartedAt  int64        `json:"startedAt"`
+	FinishedAt int64        `json:"finishedAt,omitempty"`
+}
+
+// Create fn returns the REST API of the blockchain. Admin endpoints
+// require the adminToken as bearer token, they are disabled when it is empty.
+func Create(b *contracts.BlockchainOperator, adminToken string) http.Handler {
+	api := newRestApi(b)
+	api.addReads()
+	api.addAdmin(adminToken)
+	api.Add("POST /requestvalidation", api.requestValidation)
+	api.Add("POST /submitstar", api.submitStar)
 	log.Println("INFO: REST API created successfully")
 	return api
 }
 
+// CreateFollower fn returns the REST API of a follower node: reads are
+// served from the replicated chain, writes are redirected to the leader.
+// Admin endpoints work on the replicated chain the same way as in Create.
+func CreateFollower(b *contracts.BlockchainOperator, leader string, adminToken string) http.Handler {
+	api := newRestApi(b)
+	api.leader = strings.TrimSuffix(leader, "/")
+	api.addReads()
+	api.addAdmin(adminToken)
+	api.Add("POST /requestvalidation", api.redirectToLeader)
+	api.Add("POST /submitstar", api.redirectToLeader)
+	log.Println("INFO: REST API of follower of " + api.leader + " created successfully")
+	return api
+}
+
 type restApi struct {
+	blockchain *contracts.BlockchainOperator
+	// leader is the URL writes are redirected to, empty unless the node follows one
+	leader   string
 	handlers map[string]http.HandlerFunc
 	cache    map[string]*regexp.Regexp
 }
 
-func newRestApi() *restApi {
+func newRestApi(b *contracts.BlockchainOperator) *restApi {
 	return &restApi{
-		handlers: make(map[string]http.HandlerFunc),
-		cache:    make(map[string]*regexp.Regexp),
+		blockchain: b,
+		handlers:   make(map[string]http.HandlerFunc),
+		cache:      make(map[string]*regexp.Regexp),
+	}
+}
+
+// addReads method registers endpoints which do not change the chain
+func (a *restApi) addReads() {
+	a.Add("GET /hello", a.hello)
+	a.Add("GET /block/\\d+", a.getBlockByHeight)
+	a.Add("GET /block/hash/\\w+", a.getBlockByHash)
+	a.Add("GET /blocks/[^/]+$", a.getBlocks)
+	a.Add("GET /blocks/since/\\d+$", a.getBlocksSince)
+	a.Add("GET /proof/\\d+$", a.getInclusionProof)
+	a.Add("GET /treehead$", a.getLatestTreeHead)
+	a.Add("GET /treeheads$", a.getTreeHeads)
+	a.Add("GET /treeheads/consistency$", a.getTreeHeadConsistency)
+	a.Add("GET /validate", a.validate)
+}
+
+// addAdmin method registers endpoints of node operators, guarded by the
+// token. Nothing is registered when the token is empty.
+func (a *restApi) addAdmin(token string) {
+	if token == "" {
+		log.Println("INFO: admin endpoints are disabled, no admin token is set")
+		return
+	}
+	a.Add("POST /admin/validate$", requireToken(token, a.startValidationJob))
+	a.Add("GET /admin/validate/\\w+$", requireToken(token, a.getValidationJob))
+	a.Add("DELETE /admin/validate/\\w+$", requireToken(token, a.cancelValidationJob))
+}
+
+// requireToken fn returns handler which passes only requests with
+// "Authorization: Bearer <token>" header to the handler
+func requireToken(token string, handler http.HandlerFunc) http.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(res http.ResponseWriter, req *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), expected) != 1 {
+			log.Println("ERR: admin request without valid token: ", req.Method, req.URL.Path)
+			res.Header().Set("WWW-Authenticate", "Bearer")
+			res.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(res, "Admin token is missing or invalid")
+			return
+		}
+		handler(res, req)
 	}
 }
 
@@ -86,12 +226,12 @@ func (a *restApi) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	http.NotFound(res, req)
 }
 
-func hello(res http.ResponseWriter, req *http.Request) {
+func (a *restApi) hello(res http.ResponseWriter, req *http.Request) {
 	log.Println("INFO: hello")
 	fmt.Fprint(res, "hello")
 }
 
-func requestValidation(res http.ResponseWriter, req *http.Request) {
+func (a *restApi) requestValidation(res http.ResponseWriter, req *http.Request) {
 	log.Println("INFO: requestValidation")
 	if req.Body == nil {
 		log.Println("ERR: requestValidation: request body is nil")
@@ -106,24 +246,55 @@ func requestValidation(res http.ResponseWriter, req *http.Request) {
 		fmt.Fprint(res, "Error occurred when decoding address from JSON")
 		return
 	}
-	if addr.Address == "" {
+	if addr.Address == "" && len(addr.Owners) == 0 {
 		log.Println("ERR: requestValidation: empty address field")
 		res.WriteHeader(http.StatusBadRequest)
 		fmt.Fprint(res, "address is required")
 		return
 	}
-	msg, err := (*blockchain).RequestMessageOwnershipVerification(addr.Address)
+	var star *contracts.StarCoordinates
+	if addr.Star != nil {
+		star = &contracts.StarCoordinates{RA: addr.Star.RA, Dec: addr.Star.Dec}
+	}
+	owner := contracts.ChallengeOwner{Address: addr.Address, Owners: addr.Owners, Threshold: addr.Threshold}
+	challenge, err := (*a.blockchain).RequestMessageOwnershipVerification(owner, star)
+	if err == contracts.TooManyChallengesErr || err == contracts.TooManyAddressChallengesErr {
+		log.Println("ERR: requestValidation: ", err)
+		res.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(res, err.Error())
+		return
+	}
 	if err != nil {
 		log.Println("ERR: requestValidation: ", err)
 		res.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(res, "Error occurred when calling blockchain for the validation msg")
+		if err == contracts.OwnersWithAddressErr || strings.HasPrefix(err.Error(), contracts.InvalidOwnersErr.Error()) {
+			fmt.Fprint(res, err.Error())
+		} else {
+			fmt.Fprint(res, "Error occurred when calling blockchain for the validation msg")
+		}
+		return
+	}
+	challengeDto := ChallengeDto{
+		Address:      challenge.Address,
+		Message:      challenge.Message,
+		IssuedAt:     challenge.IssuedAt,
+		ExpiresAt:    challenge.ExpiresAt,
+		ValidFor:     challenge.ValidFor,
+		MaxClockSkew: challenge.MaxClockSkew,
+	}
+	challengeJson, err := json.Marshal(challengeDto)
+	if err != nil {
+		log.Println("ERR: requestValidation failed to marshal challenge: ", err)
+		res.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(res, "Failed to serialize challenge into JSON")
 		return
 	}
+	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(http.StatusOK)
-	fmt.Fprint(res, msg)
+	fmt.Fprint(res, string(challengeJson))
 }
 
-func getBlockByHeight(res http.ResponseWriter, req *http.Request) {
+func (a *restApi) getBlockByHeight(res http.ResponseWriter, req *http.Request) {
 	log.Println("INFO: getBlockByHeight")
 	var parts []string
 	if parts = strings.Split(req.URL.Path, "/"); len(parts) != 3 {
@@ -140,11 +311,151 @@ func getBlockByHeight(res http.ResponseWriter, req *http.Request) {
 		fmt.Fprint(res, "Could not parse block height param: "+heightStr)
 		return
 	}
-	block, err := (*blockchain).GetBlockByHeight(height)
+	block, err := (*a.blockchain).GetBlockByHeight(height)
 	respondWithBlock(res, req, &block, err)
 }
 
-func getBlockByHash(res http.ResponseWriter, req *http.Request) {
+func (a *restApi) getInclusionProof(res http.ResponseWriter, req *http.Request) {
+	log.Println("INFO: getInclusionProof")
+	parts := strings.Split(req.URL.Path, "/")
+	heightStr := parts[len(parts)-1]
+	height, err := strconv.Atoi(heightStr)
+	if err != nil {
+		log.Println("ERR: getInclusionProof: could not parse block height param: ", heightStr)
+		res.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(res, "Could not parse block height param: "+heightStr)
+		return
+	}
+	proof, err := (*a.blockchain).GetInclusionProof(height)
+	if err != nil {
+		log.Println("ERR: getInclusionProof: block not found: ", err)
+		res.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(res, "Block not found")
+		return
+	}
+	respondWithJson(res, "getInclusionProof", InclusionProofDto{
+		Height:    proof.Height,
+		BlockHash: proof.BlockHash,
+		Root:      proof.Root,
+		Size:      proof.Size,
+		Path:      proof.Path,
+		Peaks:     proof.Peaks,
+	})
+}
+
+func (a *restApi) getBlocksSince(res http.ResponseWriter, req *http.Request) {
+	log.Println("INFO: getBlocksSince")
+	parts := strings.Split(req.URL.Path, "/")
+	heightStr := parts[len(parts)-1]
+	height, err := strconv.Atoi(heightStr)
+	if err != nil {
+		log.Println("ERR: getBlocksSince: could not parse block height param: ", heightStr)
+		res.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(res, "Could not parse block height param: "+heightStr)
+		return
+	}
+	blocks, err := (*a.blockchain).GetBlocksSince(height)
+	if err != nil {
+		log.Println("ERR: getBlocksSince: ", err)
+		res.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(res, "Chain is shorter than the height")
+		return
+	}
+	rangeDto := BlockRangeDto{ChainHeight: blocks.ChainHeight, Blocks: make([]json.RawMessage, len(blocks.Blocks))}
+	for i, encoded := range blocks.Blocks {
+		rangeDto.Blocks[i] = json.RawMessage(encoded)
+	}
+	respondWithJson(res, "getBlocksSince", rangeDto)
+}
+
+// redirectToLeader method rejects writes to a follower node, the client
+// is redirected to the same endpoint of the leader
+func (a *restApi) redirectToLeader(res http.ResponseWriter, req *http.Request) {
+	log.Println("INFO: redirectToLeader: ", req.URL.Path)
+	res.Header().Set("Location", a.leader+req.URL.RequestURI())
+	res.WriteHeader(http.StatusTemporaryRedirect)
+	fmt.Fprint(res, "This node is a follower, send writes to the leader "+a.leader)
+}
+
+func mapTreeHeadToDto(head contracts.TreeHead) TreeHeadDto {
+	return TreeHeadDto{
+		Height:    head.Height,
+		HeadHash:  head.HeadHash,
+		Root:      head.Root,
+		Timestamp: head.Timestamp,
+		KeyID:     head.KeyID,
+		Signature: head.Signature,
+	}
+}
+
+func (a *restApi) getLatestTreeHead(res http.ResponseWriter, req *http.Request) {
+	log.Println("INFO: getLatestTreeHead")
+	head, err := (*a.blockchain).GetLatestTreeHead()
+	if err != nil {
+		log.Println("ERR: getLatestTreeHead: ", err)
+		res.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(res, "No tree head was published")
+		return
+	}
+	respondWithJson(res, "getLatestTreeHead", mapTreeHeadToDto(head))
+}
+
+func (a *restApi) getTreeHeads(res http.ResponseWriter, req *http.Request) {
+	log.Println("INFO: getTreeHeads")
+	heads := (*a.blockchain).GetTreeHeads()
+	headsDto := make([]TreeHeadDto, len(heads))
+	for i, head := range heads {
+		headsDto[i] = mapTreeHeadToDto(head)
+	}
+	respondWithJson(res, "getTreeHeads", headsDto)
+}
+
+func (a *restApi) getTreeHeadConsistency(res http.ResponseWriter, req *http.Request) {
+	log.Println("INFO: getTreeHeadConsistency")
+	from, fromErr := strconv.Atoi(req.URL.Query().Get("from"))
+	to, toErr := strconv.Atoi(req.URL.Query().Get("to"))
+	if fromErr != nil || toErr != nil {
+		log.Println("ERR: getTreeHeadConsistency: could not parse heights: ", req.URL.RawQuery)
+		res.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(res, "Could not parse from and to heights")
+		return
+	}
+	consistency, err := (*a.blockchain).GetTreeHeadConsistency(from, to)
+	if err != nil {
+		log.Println("ERR: getTreeHeadConsistency: ", err)
+		res.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(res, err)
+		return
+	}
+	respondWithJson(res, "getTreeHeadConsistency", TreeHeadConsistencyDto{
+		OldHeight:   consistency.OldHeight,
+		NewHeight:   consistency.NewHeight,
+		OldRoot:     consistency.OldRoot,
+		NewRoot:     consistency.NewRoot,
+		OldPeaks:    consistency.OldPeaks,
+		Paths:       consistency.Paths,
+		NewPeaks:    consistency.NewPeaks,
+		OldHeadPath: consistency.OldHeadPath,
+		NewHeadPath: consistency.NewHeadPath,
+	})
+}
+
+// respondWithJson fn writes the value as JSON with status 200,
+// handler is the name logged when marshalling fails
+func respondWithJson(res http.ResponseWriter, handler string, value interface{}) {
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		log.Println("ERR: "+handler+" failed to marshal response: ", err)
+		res.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(res, "Failed to serialize response into JSON")
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	fmt.Fprint(res, string(valueJson))
+}
+
+func (a *restApi) getBlockByHash(res http.ResponseWriter, req *http.Request) {
 	log.Println("INFO: getBlockByHash")
 	var parts []string
 	if parts = strings.Split(req.URL.Path, "/"); len(parts) != 4 {
@@ -154,11 +465,11 @@ func getBlockByHash(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 	hash := parts[3]
-	block, err := (*blockchain).GetBlockByHash(hash)
+	block, err := (*a.blockchain).GetBlockByHash(hash)
 	respondWithBlock(res, req, &block, err)
 }
 
-func getBlocks(res http.ResponseWriter, req *http.Request) {
+func (a *restApi) getBlocks(res http.ResponseWriter, req *http.Request) {
 	log.Println("INFO: getBlocks")
 	var parts []string
 	if parts = strings.Split(req.URL.Path, "/"); len(parts) != 3 {
@@ -168,7 +479,7 @@ func getBlocks(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 	addr := parts[2]
-	blocksData := (*blockchain).GetStarsByWalletAddress(addr)
+	blocksData := (*a.blockchain).GetStarsByWalletAddress(addr)
 	blocksJson := make([]json.RawMessage, len(blocksData))
 	for i, d := range blocksData {
 		blocksJson[i] = json.RawMessage(d)
@@ -185,7 +496,7 @@ func getBlocks(res http.ResponseWriter, req *http.Request) {
 	fmt.Fprint(res, string(json))
 }
 
-func submitStar(res http.ResponseWriter, req *http.Request) {
+func (a *restApi) submitStar(res http.ResponseWriter, req *http.Request) {
 	log.Println("INFO: submitStar")
 	if req.Body == nil {
 		log.Println("ERR: submitStar: request body is nil")
@@ -201,12 +512,13 @@ func submitStar(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 	star := contracts.StarData{
-		Address:   starDto.Address,
-		Message:   starDto.Message,
-		Data:      starDto.Data,
-		Signature: starDto.Signature,
+		Address:    starDto.Address,
+		Message:    starDto.Message,
+		Data:       starDto.Data,
+		Signature:  starDto.Signature,
+		Signatures: starDto.Signatures,
 	}
-	block, err := (*blockchain).SubmitStar(star)
+	block, err := (*a.blockchain).SubmitStar(star)
 	if err != nil {
 		log.Println("ERR: submitStar: ", err)
 		res.WriteHeader(http.StatusInternalServerError)
@@ -220,6 +532,13 @@ func submitStar(res http.ResponseWriter, req *http.Request) {
 		Owner:             block.Owner,
 		PreviousBlockHash: block.PreviousBlockHash,
 		Time:              block.Time,
+		Signature:         block.Signature,
+		KeyID:             block.KeyID,
+		Message:           block.Message,
+		MessageSignature:  block.MessageSignature,
+		HashVersion:       block.HashVersion,
+		Difficulty:        block.Difficulty,
+		Nonce:             block.Nonce,
 	}
 	blockJson, err := json.Marshal(blockDto)
 	if err != nil {
@@ -247,6 +566,13 @@ func respondWithBlock(res http.ResponseWriter, req *http.Request, block *contrac
 		Owner:             block.Owner,
 		PreviousBlockHash: block.PreviousBlockHash,
 		Time:              block.Time,
+		Signature:         block.Signature,
+		KeyID:             block.KeyID,
+		Message:           block.Message,
+		MessageSignature:  block.MessageSignature,
+		HashVersion:       block.HashVersion,
+		Difficulty:        block.Difficulty,
+		Nonce:             block.Nonce,
 	}
 	blockJson, err := json.Marshal(blockDto)
 	if err != nil {
@@ -260,12 +586,20 @@ func respondWithBlock(res http.ResponseWriter, req *http.Request, block *contrac
 	fmt.Fprint(res, string(blockJson))
 }
 
-func validate(res http.ResponseWriter, req *http.Request) {
+func (a *restApi) validate(res http.ResponseWriter, req *http.Request) {
 	log.Println("INFO: validate")
 	var validation ValidationDto
-	isValid, errorLog := (*blockchain).Validate()
+	opts := contracts.ValidationOptions{
+		VerifyProofs: req.URL.Query().Get("proofs") == "true",
+		Full:         req.URL.Query().Get("full") == "true",
+	}
+	isValid, findings := (*a.blockchain).Validate(opts)
 	validation.Valid = isValid
-	validation.ErrorLog = errorLog
+	validation.ErrorLog = make([]string, len(findings))
+	for i, finding := range findings {
+		validation.ErrorLog[i] = finding.Message
+	}
+	validation.Findings = mapFindingsToDto(findings)
 	json, err := json.Marshal(validation)
 	if err != nil {
 		log.Println("ERR: validate failed to marshal validation DTO: ", err)
@@ -277,3 +611,83 @@ func validate(res http.ResponseWriter, req *http.Request) {
 	res.Header().Set("Content-Type", "application/json")
 	fmt.Fprint(res, string(json))
 }
+
+func (a *restApi) startValidationJob(res http.ResponseWriter, req *http.Request) {
+	log.Println("INFO: startValidationJob")
+	opts := contracts.ValidationOptions{
+		VerifyProofs: req.URL.Query().Get("proofs") == "true",
+		Full:         req.URL.Query().Get("full") == "true",
+	}
+	job, err := (*a.blockchain).StartValidation(opts)
+	if err == contracts.JobRunningErr {
+		log.Println("ERR: startValidationJob: ", err)
+		res.WriteHeader(http.StatusConflict)
+		fmt.Fprint(res, err)
+		return
+	}
+	respondWithJob(res, http.StatusAccepted, job, err)
+}
+
+func (a *restApi) getValidationJob(res http.ResponseWriter, req *http.Request) {
+	log.Println("INFO: getValidationJob")
+	parts := strings.Split(req.URL.Path, "/")
+	job, err := (*a.blockchain).GetValidation(parts[len(parts)-1])
+	respondWithJob(res, http.StatusOK, job, err)
+}
+
+func (a *restApi) cancelValidationJob(res http.ResponseWriter, req *http.Request) {
+	log.Println("INFO: cancelValidationJob")
+	parts := strings.Split(req.URL.Path, "/")
+	job, err := (*a.blockchain).CancelValidation(parts[len(parts)-1])
+	respondWithJob(res, http.StatusOK, job, err)
+}
+
+func respondWithJob(res http.ResponseWriter, status int, job contracts.ValidationJob, err error) {
+	if err == contracts.UnknownJobErr {
+		log.Println("ERR: respondWithJob: ", err)
+		res.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(res, err)
+		return
+	}
+	if err != nil {
+		log.Println("ERR: respondWithJob: ", err)
+		res.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(res, "Validation job failed")
+		return
+	}
+	jobDto := ValidationJobDto{
+		ID:         job.ID,
+		State:      job.State,
+		Checked:    job.Checked,
+		Total:      job.Total,
+		Valid:      job.Valid,
+		ErrorLog:   job.ErrorLog,
+		Findings:   mapFindingsToDto(job.Findings),
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+	jobJson, err := json.Marshal(jobDto)
+	if err != nil {
+		log.Println("ERR: respondWithJob failed to marshal job: ", err)
+		res.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(res, "Failed to serialize validation job into JSON")
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	fmt.Fprint(res, string(jobJson))
+}
+
+func mapFindingsToDto(findings []contracts.Finding) []FindingDto {
+	result := make([]FindingDto, len(findings))
+	for i, finding := range findings {
+		result[i] = FindingDto{
+			Kind:     finding.Kind,
+			Height:   finding.Height,
+			Expected: finding.Expected,
+			Actual:   finding.Actual,
+			Message:  finding.Message,
+		}
+	}
+	return result
+}
@@ -9,23 +9,57 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
 // We store raw JSON as data (it comes from http request)
 var mockBlocks [4]contracts.Block = [...]contracts.Block{
-	contracts.Block{`"Genesis Block"`, "123abc456", 0, "", "", 1592156792},
-	contracts.Block{`"Regular Block"`, "789abc987", 0, "7a7b7c", "123abc456", 1592156794},
-	contracts.Block{`"Other Block"`, "fff333", 0, "333fff", "789abc987", 1592156795},
-	contracts.Block{`"Regular Block II"`, "789abc987", 0, "7a7b7c", "fff333", 1592156796},
+	contracts.Block{`"Genesis Block"`, "123abc456", 0, "", "", 1592156792, "5e1f", "0a0b0c0d0e0f1011", "", "", 2, 0, 0},
+	contracts.Block{`"Regular Block"`, "789abc987", 0, "7a7b7c", "123abc456", 1592156794, "5e1f", "0a0b0c0d0e0f1011", "", "", 2, 0, 0},
+	contracts.Block{`"Other Block"`, "fff333", 0, "333fff", "789abc987", 1592156795, "5e1f", "0a0b0c0d0e0f1011", "", "", 2, 0, 0},
+	contracts.Block{`"Regular Block II"`, "789abc987", 0, "7a7b7c", "fff333", 1592156796, "5e1f", "0a0b0c0d0e0f1011", "", "", 2, 0, 0},
 }
 
 var validateScenario int
 
+// validateOptions are the options of the last Validate call
+var validateOptions contracts.ValidationOptions
+
+// starsAddr is the address of the last GetStarsByWalletAddress call
+var starsAddr string
+
 type BlockchainMock struct{}
 
-func (b BlockchainMock) RequestMessageOwnershipVerification(addr string) (string, error) {
-	return addr + " OK", nil
+func (b BlockchainMock) RequestMessageOwnershipVerification(owner contracts.ChallengeOwner, star *contracts.StarCoordinates) (contracts.Challenge, error) {
+	addr := owner.Address
+	if len(owner.Owners) > 0 {
+		if addr != "" {
+			return contracts.Challenge{}, contracts.OwnersWithAddressErr
+		}
+		if owner.Threshold > len(owner.Owners) {
+			return contracts.Challenge{}, errors.New(contracts.InvalidOwnersErr.Error() + ": threshold exceeds owners")
+		}
+		addr = fmt.Sprintf("multisig:%d-of-%d:%s", owner.Threshold, len(owner.Owners), strings.Join(owner.Owners, ","))
+	}
+	switch addr {
+	case "busy":
+		return contracts.Challenge{}, contracts.TooManyAddressChallengesErr
+	case "full":
+		return contracts.Challenge{}, contracts.TooManyChallengesErr
+	}
+	msg := addr + " OK"
+	if star != nil {
+		msg += " " + star.RA + " " + star.Dec
+	}
+	return contracts.Challenge{
+		Address:      addr,
+		Message:      msg,
+		IssuedAt:     1592156792,
+		ExpiresAt:    1592157092,
+		ValidFor:     300,
+		MaxClockSkew: 30,
+	}, nil
 }
 
 func (b BlockchainMock) GetBlockByHeight(h int) (contracts.Block, error) {
@@ -53,6 +87,7 @@ func (b BlockchainMock) GetBlockByHash(h string) (contracts.Block, error) {
 }
 
 func (b BlockchainMock) GetStarsByWalletAddress(addr string) []string {
+	starsAddr = addr
 	var stars []string = make([]string, 0)
 	for _, b := range mockBlocks {
 		if b.Owner == addr {
@@ -62,29 +97,128 @@ func (b BlockchainMock) GetStarsByWalletAddress(addr string) []string {
 	return stars
 }
 
+func (b BlockchainMock) GetBlocksSince(height int) (contracts.BlockRange, error) {
+	if height < 0 || height > len(mockBlocks) {
+		return contracts.BlockRange{}, errors.New("Invalid height")
+	}
+	blocks := contracts.BlockRange{ChainHeight: len(mockBlocks)}
+	for _, block := range mockBlocks[height:] {
+		blocks.Blocks = append(blocks.Blocks, []byte(fmt.Sprintf(`{"height":%d,"hash":"%s"}`, block.Height, block.Hash)))
+	}
+	return blocks, nil
+}
+
 func (b BlockchainMock) SubmitStar(star contracts.StarData) (contracts.Block, error) {
 	var block contracts.Block
 	if star.Message != "" {
-		block := contracts.Block{string(star.Data), "1a32", 1, star.Address, mockBlocks[0].Hash, 1592156792}
+		block := contracts.Block{string(star.Data), "1a32", 1, star.Address, mockBlocks[0].Hash, 1592156792, "5e1f", "0a0b0c0d0e0f1011", star.Message, star.Signature, 2, 0, 0}
 		return block, nil
 	} else {
 		return block, errors.New("Empty message error!")
 	}
 }
 
-func (b BlockchainMock) Validate() (bool, []string) {
-	errs := []string{"Err1", "Err2", "Err3"}
+// mockProof is the proof of block 1 of two block chain
+var mockProof = contracts.InclusionProof{Height: 1, BlockHash: "1a32", Root: "5e1f", Size: 2, Path: []string{"0a0b"}, Peaks: []string{"5e1f"}}
+
+func (b BlockchainMock) GetInclusionProof(height int) (contracts.InclusionProof, error) {
+	if height != mockProof.Height {
+		return contracts.InclusionProof{}, errors.New("Invalid height")
+	}
+	return mockProof, nil
+}
+
+// mockTreeHeads are tree heads published at heights 0 and 1
+var mockTreeHeads = []contracts.TreeHead{
+	{Height: 0, HeadHash: "0a0b", Root: "0c0d", Timestamp: 1592156792, KeyID: "0a0b0c0d0e0f1011", Signature: "5e1f"},
+	{Height: 1, HeadHash: "1a32", Root: "5e1f", Timestamp: 1592156852, KeyID: "0a0b0c0d0e0f1011", Signature: "6e2f"},
+}
+
+func (b BlockchainMock) GetLatestTreeHead() (contracts.TreeHead, error) {
+	return mockTreeHeads[len(mockTreeHeads)-1], nil
+}
+
+func (b BlockchainMock) GetTreeHeads() []contracts.TreeHead {
+	return mockTreeHeads
+}
+
+func (b BlockchainMock) GetTreeHeadConsistency(oldHeight int, newHeight int) (contracts.TreeHeadConsistency, error) {
+	if oldHeight != 0 || newHeight != 1 {
+		return contracts.TreeHeadConsistency{}, errors.New("Invalid heights")
+	}
+	return contracts.TreeHeadConsistency{
+		OldHeight:   0,
+		NewHeight:   1,
+		OldRoot:     mockTreeHeads[0].Root,
+		NewRoot:     mockTreeHeads[1].Root,
+		OldPeaks:    []string{"0c0d"},
+		Paths:       [][]string{{"1b2c"}},
+		NewPeaks:    []string{"5e1f"},
+		OldHeadPath: []string{},
+		NewHeadPath: []string{"0c0d"},
+	}, nil
+}
+
+func (b BlockchainMock) Validate(opts contracts.ValidationOptions) (bool, []contracts.Finding) {
+	findings := []contracts.Finding{
+		{Kind: "hash-mismatch", Height: 1, Expected: "1a32", Actual: "1a33", Message: "Err1"},
+		{Kind: "prev-hash-mismatch", Height: 2, Expected: "1a33", Actual: "1a32", Message: "Err2"},
+		{Kind: "bad-producer", Height: 2, Message: "Err3"},
+	}
+	validateOptions = opts
 	switch validateScenario {
 	case 0:
-		return true, []string{}
+		return true, []contracts.Finding{}
 	default:
-		return false, errs
+		return false, findings
+	}
+}
+
+// mockJob is the only validation job known to the mock
+var mockJob = contracts.ValidationJob{ID: "1a2b", State: "running", Checked: 256, Total: 1024, StartedAt: 1592156792}
+
+// jobRunning makes StartValidation of the mock fail with JobRunningErr
+var jobRunning bool
+
+func (b BlockchainMock) StartValidation(opts contracts.ValidationOptions) (contracts.ValidationJob, error) {
+	validateOptions = opts
+	if jobRunning {
+		return contracts.ValidationJob{}, contracts.JobRunningErr
 	}
+	return mockJob, nil
+}
+
+func (b BlockchainMock) GetValidation(id string) (contracts.ValidationJob, error) {
+	if id != mockJob.ID {
+		return contracts.ValidationJob{}, contracts.UnknownJobErr
+	}
+	return mockJob, nil
+}
+
+func (b BlockchainMock) CancelValidation(id string) (contracts.ValidationJob, error) {
+	job, err := b.GetValidation(id)
+	job.State = "cancelled"
+	return job, err
+}
+
+// mockAdminToken guards admin endpoints of the API created by createApi
+const mockAdminToken = "s3cr3t"
+
+// adminRequest fn sends the request with the admin token
+func adminRequest(method string, url string, token string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return http.DefaultClient.Do(req)
 }
 
 func createApi() *httptest.Server {
 	var blockchain contracts.BlockchainOperator = BlockchainMock{}
-	api := Create(&blockchain)
+	api := Create(&blockchain, mockAdminToken)
 	return httptest.NewServer(api)
 }
 
@@ -127,7 +261,7 @@ func TestRequestValidation(t *testing.T) {
 		t.Log("\tWhen called at /requestValidation")
 		{
 			addr := "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
-			data, _ := json.Marshal(AddressDto{addr})
+			data, _ := json.Marshal(AddressDto{Address: addr})
 			response, err := http.Post(server.URL+"/requestValidation", "application/json", bytes.NewReader(data))
 			if err != nil {
 				t.Fatalf("\t\tShould be able to submit a validation request, got err: %v", err)
@@ -137,16 +271,91 @@ func TestRequestValidation(t *testing.T) {
 				t.Fatalf("\t\tShould get response 200 OK, got: %v", response.StatusCode)
 			}
 			t.Log("\t\tShould get response 200 OK")
-			body, err := ioutil.ReadAll(response.Body)
-			if err != nil {
-				t.Fatalf("\t\tShould not return err, got: %v", err)
+			var challenge ChallengeDto
+			if err := json.NewDecoder(response.Body).Decode(&challenge); err != nil {
+				t.Fatalf("\t\tShould decode ChallengeDto, got err: %v", err)
 			}
-			t.Log("\t\tShould not return err")
+			t.Log("\t\tShould decode ChallengeDto")
 			expected := addr + " OK"
-			if string(body) != expected {
-				t.Fatalf("\t\tShould return correct message: \"%s\", got: \"%s\"", expected, body)
+			if challenge.Message != expected {
+				t.Fatalf("\t\tShould return correct message: \"%s\", got: \"%s\"", expected, challenge.Message)
 			}
 			t.Log("\t\tShould return correct message")
+			if challenge.IssuedAt != 1592156792 ||
+				challenge.ExpiresAt != 1592157092 ||
+				challenge.ValidFor != 300 ||
+				challenge.MaxClockSkew != 30 {
+				t.Fatalf("\t\tShould return challenge time range, got: %v", challenge)
+			}
+			t.Log("\t\tShould return challenge time range")
+		}
+		t.Log("\tWhen called at /requestValidation with star coordinates")
+		{
+			addr := "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
+			data := []byte(`{"address":"` + addr + `","star":{"ra":"16h 29m 1.0s","dec":"68° 52' 56.9"}}`)
+			response, err := http.Post(server.URL+"/requestValidation", "application/json", bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("\t\tShould be able to submit a validation request, got err: %v", err)
+			}
+			var challenge ChallengeDto
+			if err := json.NewDecoder(response.Body).Decode(&challenge); err != nil {
+				t.Fatalf("\t\tShould decode ChallengeDto, got err: %v", err)
+			}
+			expected := addr + " OK 16h 29m 1.0s 68° 52' 56.9"
+			if challenge.Message != expected {
+				t.Fatalf("\t\tShould pass star coordinates: \"%s\", got: \"%s\"", expected, challenge.Message)
+			}
+			t.Log("\t\tShould pass star coordinates")
+		}
+		t.Log("\tWhen called at /requestValidation with owners")
+		{
+			data := []byte(`{"owners":["1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe","0x14791697260E4c9A71f18484C9f997B308e59325"],"threshold":2}`)
+			response, err := http.Post(server.URL+"/requestValidation", "application/json", bytes.NewReader(data))
+			if err != nil || response.StatusCode != 200 {
+				t.Fatalf("\t\tShould get response 200 OK, got: %v, %v", response, err)
+			}
+			var challenge ChallengeDto
+			json.NewDecoder(response.Body).Decode(&challenge)
+			expected := "multisig:2-of-2:1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe,0x14791697260E4c9A71f18484C9f997B308e59325"
+			if challenge.Address != expected || challenge.Message != expected+" OK" {
+				t.Fatalf("\t\tShould issue challenge to the policy address, got: %v", challenge)
+			}
+			t.Log("\t\tShould issue challenge to the policy address")
+			data = []byte(`{"address":"1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe","owners":["1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"],"threshold":1}`)
+			response, err = http.Post(server.URL+"/requestValidation", "application/json", bytes.NewReader(data))
+			if err != nil || response.StatusCode != http.StatusBadRequest {
+				t.Fatalf("\t\tShould get response 400 Bad Request, got: %v, %v", response, err)
+			}
+			if body, _ := ioutil.ReadAll(response.Body); string(body) != contracts.OwnersWithAddressErr.Error() {
+				t.Fatalf("\t\tShould reject address together with owners, got: %s", body)
+			}
+			t.Log("\t\tShould reject address together with owners")
+			data = []byte(`{"owners":["1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"],"threshold":2}`)
+			response, err = http.Post(server.URL+"/requestValidation", "application/json", bytes.NewReader(data))
+			if err != nil || response.StatusCode != http.StatusBadRequest {
+				t.Fatalf("\t\tShould get response 400 Bad Request, got: %v, %v", response, err)
+			}
+			if body, _ := ioutil.ReadAll(response.Body); !strings.HasPrefix(string(body), "Invalid owners policy: ") {
+				t.Fatalf("\t\tShould reject invalid owners policy, got: %s", body)
+			}
+			t.Log("\t\tShould reject invalid owners policy")
+		}
+		t.Log("\tWhen called at /requestValidation beyond the limit of challenges")
+		{
+			for _, c := range []struct {
+				addr string
+				err  error
+			}{{"busy", contracts.TooManyAddressChallengesErr}, {"full", contracts.TooManyChallengesErr}} {
+				data := []byte(`{"address":"` + c.addr + `"}`)
+				response, err := http.Post(server.URL+"/requestValidation", "application/json", bytes.NewReader(data))
+				if err != nil || response.StatusCode != http.StatusTooManyRequests {
+					t.Fatalf("\t\tShould get response 429 Too Many Requests, got: %v, %v", response, err)
+				}
+				if body, _ := ioutil.ReadAll(response.Body); string(body) != c.err.Error() {
+					t.Fatalf("\t\tShould return the limit error, got: %s", body)
+				}
+			}
+			t.Log("\t\tShould get response 429 Too Many Requests")
 		}
 	}
 }
@@ -180,7 +389,9 @@ func TestGetBlockByHeight(t *testing.T) {
 					block.Hash != mockBlocks[0].Hash ||
 					block.Owner != mockBlocks[0].Owner ||
 					block.PreviousBlockHash != mockBlocks[0].PreviousBlockHash ||
-					block.Height != mockBlocks[0].Height {
+					block.Height != mockBlocks[0].Height ||
+					block.Signature != mockBlocks[0].Signature ||
+					block.KeyID != mockBlocks[0].KeyID {
 					t.Fatalf("\t\tShould return genesis block, got: %v", block)
 				}
 				t.Log("\t\tShould return genesis block")
@@ -230,7 +441,9 @@ func TestGetBlockByHash(t *testing.T) {
 					block.Hash != mockBlocks[0].Hash ||
 					block.Owner != mockBlocks[0].Owner ||
 					block.PreviousBlockHash != mockBlocks[0].PreviousBlockHash ||
-					block.Height != mockBlocks[0].Height {
+					block.Height != mockBlocks[0].Height ||
+					block.Signature != mockBlocks[0].Signature ||
+					block.KeyID != mockBlocks[0].KeyID {
 					t.Fatalf("\t\tShould return genesis block, got: %v", block)
 				}
 				t.Log("\t\tShould return genesis block")
@@ -285,6 +498,23 @@ func TestGetBlocks(t *testing.T) {
 				}
 				t.Log("\t\tShould return correct body")
 			}
+			t.Log("\tWhen called with did:key and multisig addresses")
+			{
+				owners := []string{
+					"did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK",
+					"multisig:2-of-3:1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe,bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4,did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK",
+				}
+				for _, owner := range owners {
+					response, err := http.Get(server.URL + "/blocks/" + owner)
+					if err != nil || response.StatusCode != 200 {
+						t.Fatal("\t\tShould get response 200 OK, got: ", response, err)
+					}
+					if starsAddr != owner {
+						t.Fatal("\t\tShould look up the whole address, got: ", starsAddr)
+					}
+				}
+				t.Log("\t\tShould look up the whole address")
+			}
 			t.Log("\tWhen called with wrong address")
 			{
 				response, err := http.Get(server.URL + "/blocks/666")
@@ -359,6 +589,10 @@ func TestSubmitStar(t *testing.T) {
 					t.Fatalf("\t\tShould return block with data: %v, got: %v", starData, block.Body)
 				}
 				t.Logf("\t\tShould return block with correct data")
+				if block.Message != msg || block.MessageSignature != "doesnotmatter" {
+					t.Fatalf("\t\tShould return block with ownership proof, got: %v, %v", block.Message, block.MessageSignature)
+				}
+				t.Logf("\t\tShould return block with ownership proof")
 			}
 			t.Log("\tWhen called with JSON object data")
 			{
@@ -447,6 +681,32 @@ func TestValidate(t *testing.T) {
 					t.Fatalf("\t\tShould return correct validation, got: %v", validation)
 				}
 				t.Log("\t\tShould return correct validation")
+				if validateOptions.VerifyProofs || validateOptions.Full {
+					t.Fatal("\t\tShould not verify ownership proofs nor all blocks by default")
+				}
+				t.Log("\t\tShould not verify ownership proofs nor all blocks by default")
+			}
+			t.Log("\tWhen called with proofs=true")
+			{
+				response, err := http.Get(server.URL + "/validate?proofs=true")
+				if err != nil || response.StatusCode != 200 {
+					t.Fatalf("\t\tShould get response 200 OK, got: %v, %v", response, err)
+				}
+				if !validateOptions.VerifyProofs {
+					t.Fatal("\t\tShould verify ownership proofs")
+				}
+				t.Log("\t\tShould verify ownership proofs")
+			}
+			t.Log("\tWhen called with full=true")
+			{
+				response, err := http.Get(server.URL + "/validate?full=true")
+				if err != nil || response.StatusCode != 200 {
+					t.Fatalf("\t\tShould get response 200 OK, got: %v, %v", response, err)
+				}
+				if !validateOptions.Full || validateOptions.VerifyProofs {
+					t.Fatal("\t\tShould validate every block")
+				}
+				t.Log("\t\tShould validate every block")
 			}
 			t.Log("\tWhen called on blockchain with tempered blocks")
 			{
@@ -470,7 +730,278 @@ func TestValidate(t *testing.T) {
 					t.Fatalf("\t\tShould return failed validation, got: %v", validation)
 				}
 				t.Log("\t\tShould return failed validation")
+				if len(validation.Findings) != 3 || len(validation.ErrorLog) != 3 || validation.ErrorLog[1] != "Err2" {
+					t.Fatalf("\t\tShould return every finding, got: %v", validation)
+				}
+				finding := validation.Findings[0]
+				if finding.Kind != "hash-mismatch" || finding.Height != 1 || finding.Expected != "1a32" || finding.Actual != "1a33" {
+					t.Fatalf("\t\tShould return kind, height, expected and actual value, got: %v", finding)
+				}
+				t.Log("\t\tShould return kind, height, expected and actual value")
+			}
+		}
+	}
+}
+
+func TestValidationJob(t *testing.T) {
+	t.Log("ValidationJob")
+	{
+		server := createApi()
+		defer server.Close()
+		t.Log("\tGiven no running job")
+		{
+			response, err := adminRequest(http.MethodPost, server.URL+"/admin/validate?full=true", mockAdminToken)
+			if err != nil || response.StatusCode != http.StatusAccepted {
+				t.Fatalf("\t\tShould get response 202 Accepted, got: %v, %v", response, err)
+			}
+			var job ValidationJobDto
+			if err := json.NewDecoder(response.Body).Decode(&job); err != nil || job.ID != mockJob.ID || job.State != "running" {
+				t.Fatalf("\t\tShould return the job, got: %v, %v", job, err)
+			}
+			if !validateOptions.Full {
+				t.Fatal("\t\tShould pass the options")
+			}
+			t.Log("\t\tShould start the job")
+		}
+		t.Log("\tGiven running job")
+		{
+			jobRunning = true
+			defer func() { jobRunning = false }()
+			response, err := adminRequest(http.MethodPost, server.URL+"/admin/validate", mockAdminToken)
+			if err != nil || response.StatusCode != http.StatusConflict {
+				t.Fatalf("\t\tShould get response 409 Conflict, got: %v, %v", response, err)
+			}
+			t.Log("\t\tShould not start another job")
+			response, err = adminRequest(http.MethodGet, server.URL+"/admin/validate/"+mockJob.ID, mockAdminToken)
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatalf("\t\tShould get response 200 OK, got: %v, %v", response, err)
+			}
+			var job ValidationJobDto
+			if err := json.NewDecoder(response.Body).Decode(&job); err != nil || job.Checked != 256 || job.Total != 1024 {
+				t.Fatalf("\t\tShould return the progress, got: %v, %v", job, err)
+			}
+			t.Log("\t\tShould return the progress")
+			response, err = adminRequest(http.MethodDelete, server.URL+"/admin/validate/"+mockJob.ID, mockAdminToken)
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatalf("\t\tShould get response 200 OK, got: %v, %v", response, err)
+			}
+			if err := json.NewDecoder(response.Body).Decode(&job); err != nil || job.State != "cancelled" {
+				t.Fatalf("\t\tShould cancel the job, got: %v, %v", job, err)
+			}
+			t.Log("\t\tShould cancel the job")
+		}
+		t.Log("\tGiven unknown job")
+		{
+			response, err := adminRequest(http.MethodGet, server.URL+"/admin/validate/ffff", mockAdminToken)
+			if err != nil || response.StatusCode != http.StatusNotFound {
+				t.Fatalf("\t\tShould get response 404 Not Found, got: %v, %v", response, err)
+			}
+			t.Log("\t\tShould get response 404 Not Found")
+		}
+		t.Log("\tGiven request without valid admin token")
+		{
+			for _, token := range []string{"", "wrong"} {
+				for _, method := range []string{http.MethodPost, http.MethodGet, http.MethodDelete} {
+					url := server.URL + "/admin/validate"
+					if method != http.MethodPost {
+						url += "/" + mockJob.ID
+					}
+					response, err := adminRequest(method, url, token)
+					if err != nil || response.StatusCode != http.StatusUnauthorized {
+						t.Fatalf("\t\tShould get response 401 Unauthorized for %s, got: %v, %v", method, response, err)
+					}
+				}
+			}
+			t.Log("\t\tShould get response 401 Unauthorized")
+		}
+		t.Log("\tGiven API without admin token")
+		{
+			var blockchain contracts.BlockchainOperator = BlockchainMock{}
+			server := httptest.NewServer(Create(&blockchain, ""))
+			defer server.Close()
+			response, err := adminRequest(http.MethodPost, server.URL+"/admin/validate", "")
+			if err != nil || response.StatusCode != http.StatusNotFound {
+				t.Fatalf("\t\tShould get response 404 Not Found, got: %v, %v", response, err)
+			}
+			t.Log("\t\tShould disable admin endpoints")
+		}
+	}
+}
+
+func TestGetInclusionProof(t *testing.T) {
+	t.Log("GetInclusionProof")
+	{
+		server := createApi()
+		defer server.Close()
+		t.Log("\tGiven a need to test endpoint /proof/:height")
+		{
+			t.Log("\tWhen called with existing height")
+			{
+				response, err := http.Get(server.URL + "/proof/1")
+				if err != nil || response.StatusCode != 200 {
+					t.Fatal("\t\tShould get response 200 OK, got: ", response, err)
+				}
+				t.Log("\t\tShould get response 200 OK")
+				if contentType := response.Header.Get("Content-Type"); contentType != "application/json" {
+					t.Fatal("\t\tShould return JSON content type, got: ", contentType)
+				}
+				t.Log("\t\tShould return JSON content type")
+				var proof InclusionProofDto
+				if err := json.NewDecoder(response.Body).Decode(&proof); err != nil {
+					t.Fatalf("\t\tShould decode response body, got err: %v", err)
+				}
+				if proof.Height != 1 || proof.BlockHash != mockProof.BlockHash || proof.Root != mockProof.Root ||
+					proof.Size != 2 || len(proof.Path) != 1 || len(proof.Peaks) != 1 {
+					t.Fatalf("\t\tShould return the proof, got: %v", proof)
+				}
+				t.Log("\t\tShould return the proof")
+			}
+			t.Log("\tWhen called with wrong height")
+			{
+				response, err := http.Get(server.URL + "/proof/666")
+				if err != nil || response.StatusCode != 404 {
+					t.Fatal("\t\tShould return not found status code, got: ", response, err)
+				}
+				t.Log("\t\tShould return not found status code")
+			}
+		}
+	}
+}
+
+func TestTreeHeads(t *testing.T) {
+	t.Log("TreeHeads")
+	{
+		server := createApi()
+		defer server.Close()
+		t.Log("\tGiven a need to test endpoint /treehead")
+		{
+			response, err := http.Get(server.URL + "/treehead")
+			if err != nil || response.StatusCode != 200 {
+				t.Fatal("\t\tShould get response 200 OK, got: ", response, err)
+			}
+			var head TreeHeadDto
+			if err := json.NewDecoder(response.Body).Decode(&head); err != nil || head != mapTreeHeadToDto(mockTreeHeads[1]) {
+				t.Fatal("\t\tShould return the latest tree head, got: ", head, err)
+			}
+			t.Log("\t\tShould return the latest tree head")
+		}
+		t.Log("\tGiven a need to test endpoint /treeheads")
+		{
+			response, err := http.Get(server.URL + "/treeheads")
+			if err != nil || response.StatusCode != 200 {
+				t.Fatal("\t\tShould get response 200 OK, got: ", response, err)
+			}
+			var heads []TreeHeadDto
+			if err := json.NewDecoder(response.Body).Decode(&heads); err != nil || len(heads) != 2 || heads[0].Height != 0 {
+				t.Fatal("\t\tShould return all tree heads, got: ", heads, err)
+			}
+			t.Log("\t\tShould return all tree heads")
+		}
+		t.Log("\tGiven a need to test endpoint /treeheads/consistency")
+		{
+			t.Log("\tWhen called with published heights")
+			{
+				response, err := http.Get(server.URL + "/treeheads/consistency?from=0&to=1")
+				if err != nil || response.StatusCode != 200 {
+					t.Fatal("\t\tShould get response 200 OK, got: ", response, err)
+				}
+				var consistency TreeHeadConsistencyDto
+				if err := json.NewDecoder(response.Body).Decode(&consistency); err != nil ||
+					consistency.OldHeight != 0 || consistency.NewHeight != 1 || consistency.NewRoot != mockTreeHeads[1].Root || len(consistency.Paths) != 1 ||
+					len(consistency.OldPeaks) != 1 || len(consistency.NewPeaks) != 1 || len(consistency.NewHeadPath) != 1 {
+					t.Fatal("\t\tShould return the proof, got: ", consistency, err)
+				}
+				t.Log("\t\tShould return the proof")
+			}
+			t.Log("\tWhen called with heights above the chain")
+			{
+				response, err := http.Get(server.URL + "/treeheads/consistency?from=0&to=5")
+				if err != nil || response.StatusCode != 404 {
+					t.Fatal("\t\tShould return not found status code, got: ", response, err)
+				}
+				t.Log("\t\tShould return not found status code")
+			}
+			t.Log("\tWhen called without heights")
+			{
+				response, err := http.Get(server.URL + "/treeheads/consistency?from=0")
+				if err != nil || response.StatusCode != 400 {
+					t.Fatal("\t\tShould return bad request status code, got: ", response, err)
+				}
+				t.Log("\t\tShould return bad request status code")
+			}
+		}
+	}
+}
+
+func TestGetBlocksSince(t *testing.T) {
+	t.Log("GetBlocksSince")
+	{
+		server := createApi()
+		defer server.Close()
+		t.Log("\tGiven a need to test endpoint /blocks/since/:height")
+		{
+			t.Log("\tWhen called with height within the chain")
+			{
+				response, err := http.Get(server.URL + "/blocks/since/2")
+				if err != nil || response.StatusCode != 200 {
+					t.Fatal("\t\tShould get response 200 OK, got: ", response, err)
+				}
+				var blocks BlockRangeDto
+				if err := json.NewDecoder(response.Body).Decode(&blocks); err != nil || blocks.ChainHeight != 4 || len(blocks.Blocks) != 2 {
+					t.Fatal("\t\tShould return blocks from the height, got: ", blocks, err)
+				}
+				var first struct{ Hash string }
+				if err := json.Unmarshal(blocks.Blocks[0], &first); err != nil || first.Hash != mockBlocks[2].Hash {
+					t.Fatal("\t\tShould return encoded blocks, got: ", string(blocks.Blocks[0]), err)
+				}
+				t.Log("\t\tShould return blocks from the height")
+			}
+			t.Log("\tWhen called with height above the chain")
+			{
+				response, err := http.Get(server.URL + "/blocks/since/5")
+				if err != nil || response.StatusCode != 404 {
+					t.Fatal("\t\tShould return not found status code, got: ", response, err)
+				}
+				t.Log("\t\tShould return not found status code")
+			}
+		}
+	}
+}
+
+func TestCreateFollower(t *testing.T) {
+	t.Log("CreateFollower")
+	{
+		var blockchain contracts.BlockchainOperator = BlockchainMock{}
+		server := httptest.NewServer(CreateFollower(&blockchain, "http://leader.example:8000/", mockAdminToken))
+		defer server.Close()
+		client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		t.Log("\tGiven writes sent to the follower")
+		{
+			for _, path := range []string{"/submitstar", "/requestValidation"} {
+				response, err := client.Post(server.URL+path, "application/json", strings.NewReader("{}"))
+				if err != nil || response.StatusCode != http.StatusTemporaryRedirect {
+					t.Fatal("\t\tShould redirect to the leader, got: ", response, err)
+				}
+				if location := response.Header.Get("Location"); location != "http://leader.example:8000"+path {
+					t.Fatal("\t\tShould redirect to the same endpoint of the leader, got: ", location)
+				}
+			}
+			t.Log("\t\tShould redirect to the same endpoint of the leader")
+		}
+		t.Log("\tGiven reads sent to the follower")
+		{
+			response, err := client.Get(server.URL + "/block/0")
+			if err != nil || response.StatusCode != 200 {
+				t.Fatal("\t\tShould serve reads, got: ", response, err)
+			}
+			t.Log("\t\tShould serve reads")
+			response, err = adminRequest(http.MethodPost, server.URL+"/admin/validate", "")
+			if err != nil || response.StatusCode != http.StatusUnauthorized {
+				t.Fatal("\t\tShould guard admin endpoints, got: ", response, err)
 			}
+			t.Log("\t\tShould guard admin endpoints")
 		}
 	}
 }
@@ -0,0 +1,154 @@
+package bitcoin
+
+import (
+	"errors"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/txscript"
+	"log"
+	"strings"
+)
+
+// AddressType enumerates address kinds supported by VerifyMessage
+type AddressType int
+
+const (
+	// P2PKH is legacy base58check address starting with 1
+	P2PKH AddressType = iota
+	// P2SHP2WPKH is P2WPKH wrapped in P2SH, base58check address starting with 3
+	P2SHP2WPKH
+	// P2WPKH is native SegWit v0 bech32 address starting with bc1q
+	P2WPKH
+	// P2TR is Taproot SegWit v1 bech32m address starting with bc1p
+	P2TR
+)
+
+const (
+	// P2SHVersion is the base58check version byte of mainnet P2SH addresses
+	P2SHVersion byte = 0x05
+	// Bech32HRP is human readable part of mainnet SegWit addresses
+	Bech32HRP = "bc"
+)
+
+// knownHRPs lists SegWit human readable parts of mainnet, testnet and regtest.
+// Only mainnet is supported, the rest is recognized to report proper error.
+var knownHRPs = []string{Bech32HRP, "tb", "bcrt"}
+
+var (
+	InvalidAddrEncodingErr = errors.New("Address encoding is invalid")
+	InvalidAddrChecksumErr = errors.New("Address checksum is invalid")
+)
+
+// Address struct is a decoded address.
+// Program holds 20 bytes public key hash (P2PKH, P2WPKH),
+// 20 bytes script hash (P2SHP2WPKH) or 32 bytes x-only output key (P2TR).
+type Address struct {
+	Type    AddressType
+	Program []byte
+}
+
+// DecodeAddress fn decodes mainnet base58check or bech32(m) address.
+// It returns InvalidAddrChecksumErr when the checksum does not match,
+// InvalidAddrEncodingErr when the address can not be decoded at all and
+// UnsupportedAddrErr for well formed addresses of unsupported kind.
+func DecodeAddress(addr string) (Address, error) {
+	lower := strings.ToLower(addr)
+	for _, hrp := range knownHRPs {
+		if strings.HasPrefix(lower, hrp+"1") {
+			return decodeSegwit(addr)
+		}
+	}
+	payload, version, err := base58.CheckDecode(addr)
+	if err == base58.ErrChecksum {
+		return Address{}, InvalidAddrChecksumErr
+	}
+	if err != nil {
+		return Address{}, InvalidAddrEncodingErr
+	}
+	if len(payload) != 20 {
+		return Address{}, InvalidAddrEncodingErr
+	}
+	switch version {
+	case P2PKHVersion:
+		return Address{P2PKH, payload}, nil
+	case P2SHVersion:
+		return Address{P2SHP2WPKH, payload}, nil
+	default:
+		return Address{}, UnsupportedAddrErr
+	}
+}
+
+func decodeSegwit(addr string) (Address, error) {
+	hrp, data, bechVersion, err := bech32.DecodeGeneric(addr)
+	if _, ok := err.(bech32.ErrInvalidChecksum); ok {
+		return Address{}, InvalidAddrChecksumErr
+	}
+	if err != nil || len(data) < 1 {
+		return Address{}, InvalidAddrEncodingErr
+	}
+	if hrp != Bech32HRP {
+		return Address{}, UnsupportedAddrErr
+	}
+	witnessVersion := data[0]
+	program, err := bech32.ConvertBits(data[1:], 5, 8, false)
+	if err != nil || len(program) < 2 || len(program) > 40 {
+		return Address{}, InvalidAddrEncodingErr
+	}
+	// BIP350: v0 uses bech32 checksum, v1 and newer use bech32m
+	if (witnessVersion == 0) != (bechVersion == bech32.Version0) {
+		return Address{}, InvalidAddrChecksumErr
+	}
+	switch {
+	case witnessVersion == 0 && len(program) == 20:
+		return Address{P2WPKH, program}, nil
+	case witnessVersion == 0 && len(program) != 32:
+		return Address{}, InvalidAddrEncodingErr
+	case witnessVersion == 1 && len(program) == 32:
+		return Address{P2TR, program}, nil
+	default:
+		return Address{}, UnsupportedAddrErr
+	}
+}
+
+// P2SHP2WPKHAddress fn returns the P2SH wrapped P2WPKH address of the key
+func P2SHP2WPKHAddress(pubKey *btcec.PublicKey) string {
+	return base58.CheckEncode(btcutil.Hash160(p2wpkhScript(pubKey)), P2SHVersion)
+}
+
+// P2WPKHAddress fn returns native SegWit v0 address of the key
+func P2WPKHAddress(pubKey *btcec.PublicKey) string {
+	return encodeSegwit(0, btcutil.Hash160(pubKey.SerializeCompressed()))
+}
+
+// P2TRAddress fn returns Taproot address of the key.
+// Internal key is tweaked according to BIP86 (no script path).
+func P2TRAddress(internalKey *btcec.PublicKey) string {
+	outputKey := txscript.ComputeTaprootKeyNoScript(internalKey)
+	return encodeSegwit(1, schnorr.SerializePubKey(outputKey))
+}
+
+func encodeSegwit(witnessVersion byte, program []byte) string {
+	converted, err := bech32.ConvertBits(program, 8, 5, true)
+	if err != nil {
+		log.Panic("ERR: encodeSegwit failed to convert program bits", err)
+	}
+	data := append([]byte{witnessVersion}, converted...)
+	var addr string
+	if witnessVersion == 0 {
+		addr, err = bech32.Encode(Bech32HRP, data)
+	} else {
+		addr, err = bech32.EncodeM(Bech32HRP, data)
+	}
+	if err != nil {
+		log.Panic("ERR: encodeSegwit failed to encode address", err)
+	}
+	return addr
+}
+
+// p2wpkhScript fn returns witness program script: OP_0 <20 bytes key hash>
+func p2wpkhScript(pubKey *btcec.PublicKey) []byte {
+	return append([]byte{0x00, 0x14}, btcutil.Hash160(pubKey.SerializeCompressed())...)
+}
@@ -0,0 +1,146 @@
+package bitcoin
+
+import (
+	"bytes"
+	"errors"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BIP322Tag is the tag of tagged hash used to commit to the message
+const BIP322Tag = "BIP0322-signed-message"
+
+var UnsupportedWitnessErr = errors.New("Only single schnorr signature (key path spend) witness is supported")
+
+// BIP322MessageHash fn returns tagged hash of the message as defined in BIP322
+func BIP322MessageHash(msg string) []byte {
+	return chainhash.TaggedHash([]byte(BIP322Tag), []byte(msg))[:]
+}
+
+// verifyBIP322 fn verifies BIP322 "simple" signature of P2TR address.
+// Simple signature is the serialized witness stack of the virtual to_sign
+// transaction spending the virtual to_spend transaction.
+func verifyBIP322(addr Address, msg string, rawSig []byte) error {
+	witness, err := parseWitness(rawSig)
+	if err != nil {
+		return MalformedSigErr
+	}
+	if len(witness) != 1 {
+		return UnsupportedWitnessErr
+	}
+	sigBytes := witness[0]
+	hashType := txscript.SigHashDefault
+	switch len(sigBytes) {
+	case schnorr.SignatureSize:
+	case schnorr.SignatureSize + 1:
+		hashType = txscript.SigHashType(sigBytes[schnorr.SignatureSize])
+		if hashType == txscript.SigHashDefault {
+			return MalformedSigErr
+		}
+		sigBytes = sigBytes[:schnorr.SignatureSize]
+	default:
+		return UnsupportedWitnessErr
+	}
+	sig, err := schnorr.ParseSignature(sigBytes)
+	if err != nil {
+		return MalformedSigErr
+	}
+	outputKey, err := schnorr.ParsePubKey(addr.Program)
+	if err != nil {
+		return InvalidAddrEncodingErr
+	}
+	pkScript := p2trScript(addr.Program)
+	toSign := bip322ToSign(pkScript, msg)
+	toSign.TxIn[0].Witness = witness
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	sigHash, err := txscript.CalcTaprootSignatureHash(
+		txscript.NewTxSigHashes(toSign, fetcher), hashType, toSign, 0, fetcher,
+	)
+	if err != nil {
+		return MalformedSigErr
+	}
+	if !sig.Verify(sigHash, outputKey) {
+		return AddrMismatchErr
+	}
+	return nil
+}
+
+// SignMessageBIP322 fn creates BIP322 "simple" signature of the message
+// for the P2TR address of the key, see P2TRAddress.
+func SignMessageBIP322(key *btcec.PrivateKey, msg string) (string, error) {
+	outputKey := txscript.ComputeTaprootKeyNoScript(key.PubKey())
+	pkScript := p2trScript(schnorr.SerializePubKey(outputKey))
+	toSign := bip322ToSign(pkScript, msg)
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	sig, err := txscript.RawTxInTaprootSignature(
+		toSign, txscript.NewTxSigHashes(toSign, fetcher), 0, 0, pkScript,
+		[]byte{}, txscript.SigHashDefault, key,
+	)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := writeWitness(&buf, wire.TxWitness{sig}); err != nil {
+		return "", err
+	}
+	return encodeSig(buf.Bytes()), nil
+}
+
+// bip322ToSign fn builds the virtual to_sign transaction without witness
+func bip322ToSign(pkScript []byte, msg string) *wire.MsgTx {
+	scriptSig := append([]byte{txscript.OP_0, txscript.OP_DATA_32}, BIP322MessageHash(msg)...)
+	toSpend := wire.NewMsgTx(0)
+	toSpend.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 0xffffffff},
+		SignatureScript:  scriptSig,
+		Sequence:         0,
+	})
+	toSpend.AddTxOut(wire.NewTxOut(0, pkScript))
+
+	toSign := wire.NewMsgTx(0)
+	toSign.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: toSpend.TxHash(), Index: 0},
+		Sequence:         0,
+	})
+	toSign.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+	return toSign
+}
+
+// p2trScript fn returns witness program script: OP_1 <32 bytes output key>
+func p2trScript(outputKey []byte) []byte {
+	return append([]byte{txscript.OP_1, txscript.OP_DATA_32}, outputKey...)
+}
+
+func parseWitness(raw []byte) (wire.TxWitness, error) {
+	r := bytes.NewReader(raw)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil || count > uint64(len(raw)) {
+		return nil, MalformedSigErr
+	}
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		witness[i], err = wire.ReadVarBytes(r, 0, uint32(len(raw)), "witness item")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Len() != 0 {
+		return nil, MalformedSigErr
+	}
+	return witness, nil
+}
+
+func writeWitness(buf *bytes.Buffer, witness wire.TxWitness) error {
+	if err := wire.WriteVarInt(buf, 0, uint64(len(witness))); err != nil {
+		return err
+	}
+	for _, item := range witness {
+		if err := wire.WriteVarBytes(buf, 0, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
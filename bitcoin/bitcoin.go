@@ -0,0 +1,163 @@
+// bitcoin package provides Bitcoin signed message verification.
+// It supports the compact signature format produced by wallets such as
+// Electrum or Bitcoin Core (signmessage RPC), BIP137 signatures of SegWit
+// addresses and BIP322 "simple" signatures of Taproot addresses.
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// MessageMagic is prepended to every message before hashing
+	MessageMagic = "Bitcoin Signed Message:\n"
+	// P2PKHVersion is the base58check version byte of mainnet P2PKH addresses
+	P2PKHVersion byte = 0x00
+	// CompactSigLen is the length of decoded compact signature
+	CompactSigLen = 65
+)
+
+// BIP137 header byte ranges, the lowest value of every range.
+// Header = range base + recovery id (0-3).
+const (
+	headerP2PKHUncompressed byte = 27
+	headerP2PKHCompressed   byte = 31
+	headerP2SHP2WPKH        byte = 35
+	headerP2WPKH            byte = 39
+	headerMax               byte = 42
+)
+
+var (
+	MalformedSigErr    = errors.New("Signature is not a valid base64 encoded signature")
+	UnsupportedAddrErr = errors.New("Address is not a supported Bitcoin address")
+	AddrMismatchErr    = errors.New("Signature does not belong to the address")
+)
+
+// MagicHash fn returns double SHA256 of the message prefixed with
+// the Bitcoin message magic, both serialized as varint length strings.
+func MagicHash(msg string) []byte {
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, MessageMagic)
+	wire.WriteVarString(&buf, 0, msg)
+	first := sha256.Sum256(buf.Bytes())
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// VerifyMessage fn checks whether base64 encoded signature sig
+// was produced over msg by the owner of address addr.
+// P2PKH, P2SH-P2WPKH and P2WPKH addresses require compact signature with
+// BIP137 header (Electrum style compressed P2PKH header is accepted for
+// SegWit addresses as well). P2TR addresses require BIP322 simple signature.
+// It returns nil when the signature is valid.
+func VerifyMessage(addr string, msg string, sig string) error {
+	address, err := DecodeAddress(addr)
+	if err != nil {
+		return err
+	}
+	rawSig, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return MalformedSigErr
+	}
+	if address.Type == P2TR {
+		return verifyBIP322(address, msg, rawSig)
+	}
+	if len(rawSig) != CompactSigLen {
+		return MalformedSigErr
+	}
+	header := rawSig[0]
+	if header < headerP2PKHUncompressed || header > headerMax {
+		return MalformedSigErr
+	}
+	if !headerMatches(header, address.Type) {
+		return AddrMismatchErr
+	}
+	// RecoverCompact understands only P2PKH headers, SegWit ones always
+	// imply compressed key so they are mapped onto compressed P2PKH range
+	compact := make([]byte, CompactSigLen)
+	copy(compact, rawSig)
+	if header >= headerP2SHP2WPKH {
+		compact[0] = headerP2PKHCompressed + (header-headerP2PKHUncompressed)%4
+	}
+	pubKey, compressed, err := ecdsa.RecoverCompact(compact, MagicHash(msg))
+	if err != nil {
+		return errors.New(fmt.Sprintf("Could not recover public key: %s", err))
+	}
+	var expected []byte
+	switch address.Type {
+	case P2PKH:
+		expected = btcutil.Hash160(serializePubKey(pubKey, compressed))
+	case P2SHP2WPKH:
+		expected = btcutil.Hash160(p2wpkhScript(pubKey))
+	case P2WPKH:
+		expected = btcutil.Hash160(pubKey.SerializeCompressed())
+	}
+	if !bytes.Equal(expected, address.Program) {
+		return AddrMismatchErr
+	}
+	return nil
+}
+
+// SignMessage fn signs msg with the private key and returns base64 encoded
+// compact signature with P2PKH header, the same format Electrum produces.
+func SignMessage(key *btcec.PrivateKey, msg string, compressed bool) string {
+	sig := ecdsa.SignCompact(key, MagicHash(msg), compressed)
+	return encodeSig(sig)
+}
+
+// SignMessageBIP137 fn signs msg with the private key and sets BIP137 header
+// matching the address type. Key is always treated as compressed.
+// P2TR is not supported, see SignMessageBIP322.
+func SignMessageBIP137(key *btcec.PrivateKey, msg string, addrType AddressType) (string, error) {
+	sig := ecdsa.SignCompact(key, MagicHash(msg), true)
+	recoveryId := (sig[0] - headerP2PKHUncompressed) % 4
+	switch addrType {
+	case P2PKH:
+	case P2SHP2WPKH:
+		sig[0] = headerP2SHP2WPKH + recoveryId
+	case P2WPKH:
+		sig[0] = headerP2WPKH + recoveryId
+	default:
+		return "", UnsupportedAddrErr
+	}
+	return encodeSig(sig), nil
+}
+
+// P2PKHAddress fn returns the base58check P2PKH address of the public key
+func P2PKHAddress(pubKey *btcec.PublicKey, compressed bool) string {
+	return base58.CheckEncode(btcutil.Hash160(serializePubKey(pubKey, compressed)), P2PKHVersion)
+}
+
+// headerMatches fn checks whether BIP137 header may be used with address type
+func headerMatches(header byte, addrType AddressType) bool {
+	switch {
+	case header < headerP2PKHCompressed:
+		return addrType == P2PKH
+	case header < headerP2SHP2WPKH:
+		return true
+	case header < headerP2WPKH:
+		return addrType == P2SHP2WPKH
+	default:
+		return addrType == P2WPKH
+	}
+}
+
+func serializePubKey(pubKey *btcec.PublicKey, compressed bool) []byte {
+	if compressed {
+		return pubKey.SerializeCompressed()
+	}
+	return pubKey.SerializeUncompressed()
+}
+
+func encodeSig(sig []byte) string {
+	return base64.StdEncoding.EncodeToString(sig)
+}
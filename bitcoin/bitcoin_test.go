@@ -0,0 +1,253 @@
+package bitcoin
+
+import (
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"strings"
+	"testing"
+)
+
+// Signature created by Electrum wallet, the same one test.sh submits
+var (
+	electrumAddr = "1CAvNmCrxSRympnSoVxYKLuXdDthyB74xu"
+	electrumMsg  = "1CAvNmCrxSRympnSoVxYKLuXdDthyB74xu:1590921500:starRegistry"
+	electrumSig  = "H8MtnshsXv4Aw1VVGZKAyhKLyya9ebYyMnLgTW13B7aAILqQNiaHox28vsLok39Zf36msVEFWQoAj7stPSJ6yIQ="
+)
+
+// Taproot test vector from BIP322
+var (
+	bip322WIF  = "L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k"
+	bip322Addr = "bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3"
+	bip322Msg  = "Hello World"
+	bip322Sig  = "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ=="
+)
+
+func TestVerifyMessage(t *testing.T) {
+	t.Log("VerifyMessage")
+	{
+		t.Log("\tGiven signature created by external wallet")
+		{
+			if err := VerifyMessage(electrumAddr, electrumMsg, electrumSig); err != nil {
+				t.Fatal("\t\tShould accept the signature, got err: ", err)
+			}
+			t.Log("\t\tShould accept the signature")
+		}
+		t.Log("\tGiven the same signature and changed message")
+		{
+			err := VerifyMessage(electrumAddr, electrumMsg+"!", electrumSig)
+			if err != AddrMismatchErr {
+				t.Fatal("\t\tShould return AddrMismatchErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the signature")
+		}
+		t.Log("\tGiven signature which is not base64")
+		{
+			err := VerifyMessage(electrumAddr, electrumMsg, "sig")
+			if err != MalformedSigErr {
+				t.Fatal("\t\tShould return MalformedSigErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the signature")
+		}
+		t.Log("\tGiven address with broken checksum")
+		{
+			err := VerifyMessage(electrumAddr[:len(electrumAddr)-1]+"v", electrumMsg, electrumSig)
+			if err != InvalidAddrChecksumErr {
+				t.Fatal("\t\tShould return InvalidAddrChecksumErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the address")
+		}
+	}
+}
+
+func TestSignMessage(t *testing.T) {
+	t.Log("SignMessage")
+	{
+		key, _ := btcec.PrivKeyFromBytes([]byte("starchain test key #1 - 32 bytes"))
+		msg := "Sign me"
+		for _, compressed := range []bool{true, false} {
+			t.Log("\tGiven compressed key: ", compressed)
+			{
+				addr := P2PKHAddress(key.PubKey(), compressed)
+				sig := SignMessage(key, msg, compressed)
+				if err := VerifyMessage(addr, msg, sig); err != nil {
+					t.Fatal("\t\tShould create verifiable signature, got err: ", err)
+				}
+				t.Log("\t\tShould create verifiable signature")
+				other := P2PKHAddress(key.PubKey(), !compressed)
+				if err := VerifyMessage(other, msg, sig); err != AddrMismatchErr {
+					t.Fatal("\t\tShould not match address of the other key encoding, got: ", err)
+				}
+				t.Log("\t\tShould not match address of the other key encoding")
+			}
+		}
+	}
+}
+
+func TestVerifyMessageSegwit(t *testing.T) {
+	t.Log("VerifyMessage SegWit")
+	{
+		key, _ := btcec.PrivKeyFromBytes([]byte("starchain test key #1 - 32 bytes"))
+		other, _ := btcec.PrivKeyFromBytes([]byte("starchain test key #2 - 32 bytes"))
+		msg := "Sign me"
+		cases := []struct {
+			name     string
+			addrType AddressType
+			addr     string
+		}{
+			{"P2SH-P2WPKH", P2SHP2WPKH, P2SHP2WPKHAddress(key.PubKey())},
+			{"P2WPKH", P2WPKH, P2WPKHAddress(key.PubKey())},
+		}
+		for _, c := range cases {
+			t.Log("\tGiven BIP137 signature of", c.name, "address", c.addr)
+			{
+				sig, err := SignMessageBIP137(key, msg, c.addrType)
+				if err != nil {
+					t.Fatal("\t\tShould sign the message, got err: ", err)
+				}
+				if err := VerifyMessage(c.addr, msg, sig); err != nil {
+					t.Fatal("\t\tShould accept the signature, got err: ", err)
+				}
+				t.Log("\t\tShould accept the signature")
+				otherSig, _ := SignMessageBIP137(other, msg, c.addrType)
+				if err := VerifyMessage(c.addr, msg, otherSig); err != AddrMismatchErr {
+					t.Fatal("\t\tShould reject signature of other key, got: ", err)
+				}
+				t.Log("\t\tShould reject signature of other key")
+			}
+			t.Log("\tGiven Electrum style signature of", c.name, "address", c.addr)
+			{
+				sig := SignMessage(key, msg, true)
+				if err := VerifyMessage(c.addr, msg, sig); err != nil {
+					t.Fatal("\t\tShould accept the signature, got err: ", err)
+				}
+				t.Log("\t\tShould accept the signature")
+			}
+			t.Log("\tGiven P2PKH uncompressed header and", c.name, "address", c.addr)
+			{
+				sig := SignMessage(key, msg, false)
+				if err := VerifyMessage(c.addr, msg, sig); err != AddrMismatchErr {
+					t.Fatal("\t\tShould reject the signature, got: ", err)
+				}
+				t.Log("\t\tShould reject the signature")
+			}
+		}
+		t.Log("\tGiven P2SH-P2WPKH header and P2WPKH address")
+		{
+			sig, _ := SignMessageBIP137(key, msg, P2SHP2WPKH)
+			if err := VerifyMessage(P2WPKHAddress(key.PubKey()), msg, sig); err != AddrMismatchErr {
+				t.Fatal("\t\tShould reject the signature, got: ", err)
+			}
+			t.Log("\t\tShould reject the signature")
+		}
+	}
+}
+
+func TestVerifyMessageTaproot(t *testing.T) {
+	t.Log("VerifyMessage Taproot")
+	{
+		t.Log("\tGiven BIP322 test vector")
+		{
+			if err := VerifyMessage(bip322Addr, bip322Msg, bip322Sig); err != nil {
+				t.Fatal("\t\tShould accept the signature, got err: ", err)
+			}
+			t.Log("\t\tShould accept the signature")
+			if err := VerifyMessage(bip322Addr, "Hello World!", bip322Sig); err != AddrMismatchErr {
+				t.Fatal("\t\tShould reject signature of other message, got: ", err)
+			}
+			t.Log("\t\tShould reject signature of other message")
+		}
+		t.Log("\tGiven BIP322 test vector key")
+		{
+			wif, err := btcutil.DecodeWIF(bip322WIF)
+			if err != nil {
+				t.Fatal("\t\tShould decode WIF, got err: ", err)
+			}
+			if addr := P2TRAddress(wif.PrivKey.PubKey()); addr != bip322Addr {
+				t.Fatal("\t\tShould derive test vector address, got: ", addr)
+			}
+			t.Log("\t\tShould derive test vector address")
+			sig, err := SignMessageBIP322(wif.PrivKey, bip322Msg)
+			if err != nil {
+				t.Fatal("\t\tShould sign the message, got err: ", err)
+			}
+			if err := VerifyMessage(bip322Addr, bip322Msg, sig); err != nil {
+				t.Fatal("\t\tShould create verifiable signature, got err: ", err)
+			}
+			t.Log("\t\tShould create verifiable signature")
+		}
+		t.Log("\tGiven compact signature and Taproot address")
+		{
+			if err := VerifyMessage(bip322Addr, bip322Msg, electrumSig); err != MalformedSigErr {
+				t.Fatal("\t\tShould reject the signature, got: ", err)
+			}
+			t.Log("\t\tShould reject the signature")
+		}
+	}
+}
+
+func TestDecodeAddress(t *testing.T) {
+	t.Log("DecodeAddress")
+	{
+		cases := []struct {
+			addr     string
+			addrType AddressType
+			err      error
+		}{
+			{electrumAddr, P2PKH, nil},
+			{"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", P2SHP2WPKH, nil},
+			{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", P2WPKH, nil},
+			{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", P2WPKH, nil},
+			{bip322Addr, P2TR, nil},
+			// Broken checksums
+			{"1CAvNmCrxSRympnSoVxYKLuXdDthyB74xv", 0, InvalidAddrChecksumErr},
+			{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", 0, InvalidAddrChecksumErr},
+			// v0 program encoded with bech32m checksum (BIP350)
+			{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", 0, InvalidAddrChecksumErr},
+			// Characters outside of the alphabets
+			{"1CAvNmCrxSRympnSoVxYKLuXdDthyB74x0", 0, InvalidAddrEncodingErr},
+			{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3tb", 0, InvalidAddrEncodingErr},
+			{"", 0, InvalidAddrEncodingErr},
+			// Testnet
+			{"tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", 0, UnsupportedAddrErr},
+			// P2WSH
+			{"bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3", 0, UnsupportedAddrErr},
+		}
+		for _, c := range cases {
+			t.Log("\tGiven address", c.addr)
+			{
+				addr, err := DecodeAddress(c.addr)
+				if err != c.err {
+					t.Fatalf("\t\tShould return err: %v, got: %v", c.err, err)
+				}
+				if err == nil && addr.Type != c.addrType {
+					t.Fatalf("\t\tShould return type: %v, got: %v", c.addrType, addr.Type)
+				}
+				t.Log("\t\tShould decode address correctly")
+			}
+		}
+	}
+}
+
+func TestCanonicalAddress(t *testing.T) {
+	t.Log("CanonicalAddress")
+	{
+		cases := []struct {
+			addr      string
+			canonical string
+		}{
+			{electrumAddr, electrumAddr},
+			{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+			{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+			{strings.ToUpper(bip322Addr), bip322Addr},
+		}
+		for _, c := range cases {
+			t.Log("\tGiven address", c.addr)
+			{
+				if actual, err := (Verifier{}).CanonicalAddress(c.addr); err != nil || actual != c.canonical {
+					t.Fatalf("\t\tShould return %s, got: %s %v", c.canonical, actual, err)
+				}
+				t.Log("\t\tShould return canonical address")
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package bitcoin
+
+import (
+	"strings"
+)
+
+// Scheme is the name of Bitcoin address scheme
+const Scheme = "bitcoin"
+
+// Verifier struct implements contracts.Verifier for Bitcoin addresses
+type Verifier struct{}
+
+func (v Verifier) Scheme() string {
+	return Scheme
+}
+
+// Supports method accepts addresses starting with known bech32 prefixes
+// and base58 addresses starting with 1 (P2PKH) or 3 (P2SH).
+func (v Verifier) Supports(addr string) bool {
+	lower := strings.ToLower(addr)
+	for _, hrp := range knownHRPs {
+		if strings.HasPrefix(lower, hrp+"1") {
+			return true
+		}
+	}
+	return strings.HasPrefix(addr, "1") || strings.HasPrefix(addr, "3")
+}
+
+func (v Verifier) ValidateAddress(addr string) error {
+	_, err := DecodeAddress(addr)
+	return err
+}
+
+func (v Verifier) Verify(addr string, msg string, sig string) error {
+	return VerifyMessage(addr, msg, sig)
+}
+
+// CanonicalAddress method returns bech32 addresses in lower case,
+// base58 addresses are case sensitive and returned unchanged
+func (v Verifier) CanonicalAddress(addr string) (string, error) {
+	decoded, err := DecodeAddress(addr)
+	if err != nil {
+		return "", err
+	}
+	if decoded.Type == P2WPKH || decoded.Type == P2TR {
+		return strings.ToLower(addr), nil
+	}
+	return addr, nil
+}
@@ -2,7 +2,9 @@ package block
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -15,15 +17,43 @@ import (
 // It consists of timestamp (ts), height, address of the owner's wallet,
 // previous block hash, data encoded as []byte of hex values,
 // and SHA256 hash of the block.
+// Star blocks also keep the challenge message and owner's signature of it,
+// so the ownership can be verified again later.
+// The hash is signed by the node which produced the block, signature
+// and ID of the producer key are not part of the hash.
+// hashVersion tells which hashing scheme the hash was calculated with.
+// Mined blocks also keep the proof-of-work difficulty and nonce.
 type Block struct {
-	ts       int64
-	height   int
-	owner    string
-	prevHash *[sha256.Size]byte
-	data     []byte
-	hash     [sha256.Size]byte
+	ts          int64
+	height      int
+	owner       string
+	prevHash    *[sha256.Size]byte
+	data        []byte
+	msg         string
+	msgSig      string
+	hashVersion byte
+	difficulty  uint64
+	nonce       uint64
+	hash        [sha256.Size]byte
+	signature   []byte
+	keyID       string
 }
 
+// Hashing schemes of blocks
+const (
+	// HashV1 hashes fields joined with "|", kept to validate existing blocks
+	HashV1 byte = 1
+	// HashV2 hashes length-prefixed fields, so the preimage is unambiguous
+	HashV2 byte = 2
+	// HashV3 is HashV2 followed by difficulty and nonce of mined blocks
+	HashV3 byte = 3
+	// HashVersion is the scheme new blocks are hashed with
+	HashVersion = HashV2
+)
+
+// KeyIDSize is the number of bytes of public key hash used as the key ID
+const KeyIDSize = 8
+
 var (
 	WrongTimeStampErr error = errors.New("Timestamp must be bigger than 0")
 	NegativeHeightErr error = errors.New("Height must be greater than or equal 0")
@@ -33,6 +63,13 @@ var (
 // It panics when timestamp is less than or equal 0.
 // It panics when height is negative.
 func New(ts int64, height int, owner string, prevHash *[sha256.Size]byte, data []byte) *Block {
+	return NewStar(ts, height, owner, prevHash, data, "", "")
+}
+
+// NewStar fn creates a new Block together with the ownership proof:
+// the message and owner's signature of it. Both are part of the hash.
+// It panics the same way as New.
+func NewStar(ts int64, height int, owner string, prevHash *[sha256.Size]byte, data []byte, msg string, msgSig string) *Block {
 	if ts <= 0 {
 		log.Panic(WrongTimeStampErr, ts)
 	}
@@ -54,14 +91,32 @@ func New(ts int64, height int, owner string, prevHash *[sha256.Size]byte, data [
 		hex.Encode(dataHex, data)
 		block.data = dataHex
 	}
+	block.msg = msg
+	block.msgSig = msgSig
+	block.hashVersion = HashVersion
 	hash := block.CalculateHash()
 	block.hash = hash
 	return &block
 }
 
 // CalculateHash method calculates the sha256 hash of the block properties
-// except the hash field and returns that value.
+// except the hash field using the hashing scheme of the block
+// and returns that value. Unknown scheme results in zero hash.
 func (b *Block) CalculateHash() [sha256.Size]byte {
+	switch b.hashVersion {
+	case HashV1:
+		return b.calculateHashV1()
+	case HashV2, HashV3:
+		return b.calculateHashV2()
+	}
+	return [sha256.Size]byte{}
+}
+
+// calculateHashV1 method hashes the fields joined with "|".
+// Message and its signature are hashed only when present, so blocks
+// without ownership proof keep their hashes.
+// Fields containing "|" make the preimage ambiguous, hence HashV2.
+func (b *Block) calculateHashV1() [sha256.Size]byte {
 	data := ""
 	if b.data != nil {
 		data = fmt.Sprintf("%s", b.data)
@@ -71,9 +126,45 @@ func (b *Block) CalculateHash() [sha256.Size]byte {
 		prevH = utils.HashToStr(*b.prevHash)
 	}
 	blockFields := fmt.Sprintf("|%d|%d|%s|%s|%s|", b.ts, b.height, b.owner, prevH, data)
+	if b.msg != "" || b.msgSig != "" {
+		blockFields += fmt.Sprintf("%s|%s|", b.msg, b.msgSig)
+	}
 	return sha256.Sum256([]byte(blockFields))
 }
 
+// calculateHashV2 method hashes the scheme version, timestamp and height
+// as big endian integers followed by owner, previous hash, hex data,
+// message and its signature, each prefixed with its length (uint64 BE).
+// Missing previous hash is an empty field.
+// HashV3 appends difficulty and nonce as big endian integers.
+func (b *Block) calculateHashV2() [sha256.Size]byte {
+	hasher := sha256.New()
+	var fixed [8]byte
+	hasher.Write([]byte{b.hashVersion})
+	binary.BigEndian.PutUint64(fixed[:], uint64(b.ts))
+	hasher.Write(fixed[:])
+	binary.BigEndian.PutUint64(fixed[:], uint64(b.height))
+	hasher.Write(fixed[:])
+	var prevH []byte
+	if b.prevHash != nil {
+		prevH = b.prevHash[:]
+	}
+	for _, field := range [][]byte{[]byte(b.owner), prevH, b.data, []byte(b.msg), []byte(b.msgSig)} {
+		binary.BigEndian.PutUint64(fixed[:], uint64(len(field)))
+		hasher.Write(fixed[:])
+		hasher.Write(field)
+	}
+	if b.hashVersion == HashV3 {
+		binary.BigEndian.PutUint64(fixed[:], b.difficulty)
+		hasher.Write(fixed[:])
+		binary.BigEndian.PutUint64(fixed[:], b.nonce)
+		hasher.Write(fixed[:])
+	}
+	var hash [sha256.Size]byte
+	copy(hash[:], hasher.Sum(nil))
+	return hash
+}
+
 // DecodeData method returns data stored inside a block decoded from hex
 func (b *Block) DecodeData() []byte {
 	decoded := make([]byte, hex.DecodedLen(len(b.data)))
@@ -96,15 +187,28 @@ func (b *Block) GetOwner() string {
 	return b.owner
 }
 
+// GetMessage method returns the challenge message signed by the owner
+func (b *Block) GetMessage() string {
+	return b.msg
+}
+
+// GetMessageSignature method returns owner's signature of the message
+func (b *Block) GetMessageSignature() string {
+	return b.msgSig
+}
+
 // GetHeight method returns height of the block
 func (b *Block) GetHeight() int {
 	return b.height
 }
 
-// GetPrevHash method returns prevHash field value
+// GetPrevHash method returns prevHash field value,
+// zero hash when the block has no previous block hash
 func (b *Block) GetPrevHash() [sha256.Size]byte {
 	var hash [sha256.Size]byte
-	copy(hash[:], b.prevHash[:])
+	if b.prevHash != nil {
+		copy(hash[:], b.prevHash[:])
+	}
 	return hash
 }
 
@@ -120,9 +224,61 @@ func (b *Block) GetTimestamp() int64 {
 	return b.ts
 }
 
+// GetHashVersion method returns the hashing scheme of the block
+func (b *Block) GetHashVersion() byte {
+	return b.hashVersion
+}
+
 // Validate method checks whether block was tampered with.
 // It does so by calculating the hash of the block without hash field and
 // comparing the result with the hash stored in that block.
+// Blocks of every known hashing scheme are accepted.
 func (b *Block) Validate() bool {
+	if !KnownHashVersion(b.hashVersion) {
+		return false
+	}
 	return b.hash == b.CalculateHash()
 }
+
+// KnownHashVersion fn reports whether blocks hashed with the version
+// can be validated
+func KnownHashVersion(version byte) bool {
+	return version >= HashV1 && version <= HashV3
+}
+
+// KeyID fn returns ID of the producer key: hex encoded prefix of its SHA256
+func KeyID(pubKey ed25519.PublicKey) string {
+	hash := sha256.Sum256(pubKey)
+	return hex.EncodeToString(hash[:KeyIDSize])
+}
+
+// Sign method signs the block hash with the producer key
+// and stores the signature together with the key ID.
+func (b *Block) Sign(key ed25519.PrivateKey) {
+	b.signature = ed25519.Sign(key, b.hash[:])
+	b.keyID = KeyID(key.Public().(ed25519.PublicKey))
+}
+
+// GetSignature method returns a copy of the producer signature,
+// nil when the block is not signed
+func (b *Block) GetSignature() []byte {
+	if b.signature == nil {
+		return nil
+	}
+	return append([]byte{}, b.signature...)
+}
+
+// GetKeyID method returns ID of the key which signed the block
+func (b *Block) GetKeyID() string {
+	return b.keyID
+}
+
+// VerifySignature method checks whether the stored hash was signed
+// with the private key of pubKey.
+// It does not check whether the stored hash matches the block, see Validate.
+func (b *Block) VerifySignature(pubKey ed25519.PublicKey) bool {
+	if len(b.signature) != ed25519.SignatureSize || b.keyID != KeyID(pubKey) {
+		return false
+	}
+	return ed25519.Verify(pubKey, b.hash[:], b.signature)
+}
@@ -1,8 +1,12 @@
 package block
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
@@ -16,6 +20,11 @@ var (
 	owner string            = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
 )
 
+var (
+	key      = ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+	otherKey = ed25519.NewKeyFromSeed([]byte("starchain other key - 32 bytes!!"))
+)
+
 func TestNew(t *testing.T) {
 	t.Log("TestNew")
 	{
@@ -64,6 +73,31 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewStar(t *testing.T) {
+	t.Log("TestNewStar")
+	{
+		msg := "starchain.local wants you to prove ownership of the address:\n" + owner
+		sig := "H8MtnshsXv4Aw1VVGZKAyhKLyya9ebYyMnLgTW13B7aA"
+		t.Log("\tGiven a block with ownership proof")
+		{
+			block := NewStar(ts, h, owner, &prevH, data, msg, sig)
+			if block.GetMessage() != msg || block.GetMessageSignature() != sig {
+				t.Fatal("\t\tShould store message and signature, got: ", block.GetMessage(), block.GetMessageSignature())
+			}
+			t.Log("\t\tShould store message and signature")
+			if block.GetHash() == New(ts, h, owner, &prevH, data).GetHash() {
+				t.Fatal("\t\tShould include the proof in the hash")
+			}
+			t.Log("\t\tShould include the proof in the hash")
+			block.msgSig = "tampered"
+			if block.Validate() {
+				t.Fatal("\t\tShould detect tampered signature")
+			}
+			t.Log("\t\tShould detect tampered signature")
+		}
+	}
+}
+
 func TestNewNilPrevH(t *testing.T) {
 	t.Log("TestNew")
 	{
@@ -170,11 +204,44 @@ func TestGetHash(t *testing.T) {
 		{
 			block := New(ts, h, owner, &prevH, data)
 			actual := block.GetHash()
+			expected := "8af51b5e9b21876ac93ccff986a397cd4e915b13e161f328a2829b8cf7eb4cc1"
+			if block.GetHashVersion() != HashV2 || fmt.Sprintf("%x", actual) != expected {
+				t.Fatalf("\t\tShould return correct hash:\n%s, got:\n%x", expected, actual)
+			}
+			t.Logf("\t\tShould return the same hash")
+		}
+		t.Log("\tGiven a block hashed with HashV1")
+		{
+			block := New(ts, h, owner, &prevH, data)
+			block.hashVersion = HashV1
+			actual := block.CalculateHash()
 			expected := "874622f4398bad94091484dc8e6d0ff0bfb4b673297d4121dfe23dcb6decefde"
 			if fmt.Sprintf("%x", actual) != expected {
 				t.Fatalf("\t\tShould return correct hash:\n%s, got:\n%x", expected, actual)
 			}
-			t.Logf("\t\tShould return the same hash")
+			block.hash = actual
+			if !block.Validate() {
+				t.Fatal("\t\tShould validate HashV1 block")
+			}
+			t.Logf("\t\tShould keep HashV1 hashes valid")
+			block.hashVersion = HashVersion + 1
+			if block.Validate() {
+				t.Fatal("\t\tShould not validate unknown hash version")
+			}
+			t.Logf("\t\tShould not validate unknown hash version")
+		}
+		t.Log("\tGiven blocks with the same HashV1 preimage")
+		{
+			first := NewStar(ts, h, owner, &prevH, data, "m|s", "")
+			second := NewStar(ts, h, owner, &prevH, data, "m", "s|")
+			if first.GetHash() == second.GetHash() {
+				t.Fatal("\t\tShould return different hashes")
+			}
+			first.hashVersion, second.hashVersion = HashV1, HashV1
+			if first.CalculateHash() != second.CalculateHash() {
+				t.Fatal("\t\tShould collide with HashV1")
+			}
+			t.Logf("\t\tShould return different hashes")
 		}
 	}
 }
@@ -298,3 +365,201 @@ func TestValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestSign(t *testing.T) {
+	t.Log("TestSign")
+	{
+		pubKey := key.Public().(ed25519.PublicKey)
+		t.Log("\tGiven unsigned block")
+		{
+			block := New(ts, h, owner, &prevH, data)
+			if block.GetSignature() != nil || block.GetKeyID() != "" {
+				t.Fatal("\t\tShould have no signature, got: ", block.GetSignature())
+			}
+			if block.VerifySignature(pubKey) {
+				t.Fatal("\t\tShould not verify")
+			}
+			t.Log("\t\tShould not verify")
+		}
+		t.Log("\tGiven signed block")
+		{
+			block := New(ts, h, owner, &prevH, data)
+			hash := block.GetHash()
+			block.Sign(key)
+			if block.GetHash() != hash {
+				t.Fatal("\t\tShould not change the hash")
+			}
+			if block.GetKeyID() != KeyID(pubKey) || len(block.GetKeyID()) != 2*KeyIDSize {
+				t.Fatal("\t\tShould store the key ID, got: ", block.GetKeyID())
+			}
+			if !block.VerifySignature(pubKey) {
+				t.Fatal("\t\tShould verify with the producer key")
+			}
+			t.Log("\t\tShould verify with the producer key")
+			if block.VerifySignature(otherKey.Public().(ed25519.PublicKey)) {
+				t.Fatal("\t\tShould not verify with other key")
+			}
+			t.Log("\t\tShould not verify with other key")
+			sig := block.GetSignature()
+			sig[0] ^= 0xff
+			if !bytes.Equal(sig[1:], block.GetSignature()[1:]) || sig[0] == block.GetSignature()[0] {
+				t.Fatal("\t\tShould return a copy of the signature")
+			}
+			block.hash[0] ^= 0xff
+			if block.VerifySignature(pubKey) {
+				t.Fatal("\t\tShould not verify tampered hash")
+			}
+			t.Log("\t\tShould not verify tampered hash")
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	t.Log("TestEncoding")
+	{
+		original := NewStar(ts, h, owner, &prevH, data, "message", "signature")
+		original.Sign(key)
+		genesis := New(ts, 0, "", nil, nil)
+		equal := func(a *Block, b *Block) bool {
+			return a.GetHash() == b.GetHash() && a.GetHashVersion() == b.GetHashVersion() && a.GetTimestamp() == b.GetTimestamp() &&
+				a.GetHeight() == b.GetHeight() && a.GetOwner() == b.GetOwner() &&
+				(a.prevHash == b.prevHash || a.prevHash != nil && b.prevHash != nil && *a.prevHash == *b.prevHash) &&
+				bytes.Equal(a.GetData(), b.GetData()) && a.GetMessage() == b.GetMessage() &&
+				a.GetMessageSignature() == b.GetMessageSignature() &&
+				bytes.Equal(a.GetSignature(), b.GetSignature()) && a.GetKeyID() == b.GetKeyID()
+		}
+		t.Log("\tGiven signed star block and unsigned genesis block")
+		{
+			for _, b := range []*Block{original, genesis} {
+				encoded, err := b.MarshalBinary()
+				if err != nil || encoded[0] != EncodingVersion {
+					t.Fatal("\t\tShould encode with version byte, got err: ", err)
+				}
+				var decoded Block
+				if err := decoded.UnmarshalBinary(encoded); err != nil || !equal(b, &decoded) || !decoded.Validate() {
+					t.Fatal("\t\tShould round-trip binary form, got err: ", err)
+				}
+				encoded, err = json.Marshal(b)
+				if err != nil {
+					t.Fatal("\t\tShould encode JSON, got err: ", err)
+				}
+				decoded = Block{}
+				if err := json.Unmarshal(encoded, &decoded); err != nil || !equal(b, &decoded) || !decoded.Validate() {
+					t.Fatal("\t\tShould round-trip JSON form, got err: ", err)
+				}
+			}
+			if !original.VerifySignature(key.Public().(ed25519.PublicKey)) {
+				t.Fatal("\t\tShould keep the producer signature")
+			}
+			t.Log("\t\tShould round-trip all fields")
+		}
+		t.Log("\tGiven stored hash which does not match the fields")
+		{
+			tampered := *original
+			tampered.hash[0] ^= 0xff
+			encoded, _ := tampered.MarshalBinary()
+			var decoded Block
+			if err := decoded.UnmarshalBinary(encoded); err != nil || decoded.GetHash() != tampered.GetHash() || decoded.Validate() {
+				t.Fatal("\t\tShould keep the stored hash, got err: ", err)
+			}
+			t.Log("\t\tShould keep the stored hash")
+		}
+		t.Log("\tGiven malformed encodings")
+		{
+			encoded, _ := original.MarshalBinary()
+			unsupported := append([]byte{EncodingVersion + 1}, encoded[1:]...)
+			var decoded Block
+			if err := decoded.UnmarshalBinary(unsupported); err != UnsupportedVersionErr {
+				t.Fatal("\t\tShould return UnsupportedVersionErr, got: ", err)
+			}
+			for _, malformed := range [][]byte{nil, encoded[:len(encoded)-1], append(encoded, 0)} {
+				if err := decoded.UnmarshalBinary(malformed); err != MalformedBlockErr {
+					t.Fatal("\t\tShould return MalformedBlockErr, got: ", err)
+				}
+			}
+			if err := json.Unmarshal([]byte(`{"version":3}`), &decoded); err != UnsupportedVersionErr {
+				t.Fatal("\t\tShould return UnsupportedVersionErr for JSON, got: ", err)
+			}
+			if err := json.Unmarshal([]byte(`{"version":1,"hash":"xyz"}`), &decoded); err != MalformedBlockErr {
+				t.Fatal("\t\tShould return MalformedBlockErr for JSON, got: ", err)
+			}
+			t.Log("\t\tShould return errors")
+		}
+		t.Log("\tGiven block encoded before hash versions")
+		{
+			v1 := New(ts, h, owner, &prevH, data)
+			v1.hashVersion = HashV1
+			v1.hash = v1.CalculateHash()
+			encoded, _ := v1.MarshalBinary()
+			legacy := append([]byte{encodingV1}, encoded[2:]...)
+			var decoded Block
+			if err := decoded.UnmarshalBinary(legacy); err != nil || !equal(v1, &decoded) ||
+				decoded.GetHashVersion() != HashV1 || !decoded.Validate() {
+				t.Fatal("\t\tShould decode it as HashV1 block, got err: ", err)
+			}
+			legacyJSON := fmt.Sprintf(`{"version":1,"time":%d,"height":%d,"owner":%q,"previousBlockHash":"%x","body":"%s","hash":"%x"}`,
+				ts, h, owner, prevH, v1.GetData(), v1.GetHash())
+			decoded = Block{}
+			if err := json.Unmarshal([]byte(legacyJSON), &decoded); err != nil || !equal(v1, &decoded) || !decoded.Validate() {
+				t.Fatal("\t\tShould decode JSON as HashV1 block, got err: ", err)
+			}
+			t.Log("\t\tShould decode it as HashV1 block")
+		}
+	}
+}
+
+func TestMine(t *testing.T) {
+	t.Log("Mine")
+	{
+		t.Log("\tGiven block mined with difficulty 256")
+		{
+			b := New(ts, h, owner, &prevH, data)
+			original := b.GetHash()
+			if b.MeetsDifficulty() {
+				t.Fatal("\t\tShould not accept block which was not mined")
+			}
+			if err := b.Mine(context.Background(), 256); err != nil {
+				t.Fatal("\t\tShould find the nonce, got err: ", err)
+			}
+			hash := b.GetHash()
+			if !b.Validate() || !b.MeetsDifficulty() || b.GetHashVersion() != HashV3 || b.GetDifficulty() != 256 || hash == original || hash[0] != 0 {
+				t.Fatal("\t\tShould find hash meeting the difficulty, got: ", b.GetHashVersion(), b.GetNonce(), hash)
+			}
+			t.Log("\t\tShould find hash meeting the difficulty")
+			binary, _ := b.MarshalBinary()
+			encoded, _ := json.Marshal(b)
+			var fromBinary, fromJSON Block
+			if err := fromBinary.UnmarshalBinary(binary); err != nil || fromBinary.GetHash() != hash || !fromBinary.Validate() || fromBinary.GetNonce() != b.GetNonce() {
+				t.Fatal("\t\tShould keep the nonce in binary encoding, got err: ", err)
+			}
+			if err := json.Unmarshal(encoded, &fromJSON); err != nil || !fromJSON.Validate() || !fromJSON.MeetsDifficulty() {
+				t.Fatal("\t\tShould keep the nonce in JSON encoding, got err: ", err)
+			}
+			t.Log("\t\tShould keep the nonce in encodings")
+			b.nonce++
+			if b.Validate() {
+				t.Fatal("\t\tShould hash the nonce")
+			}
+			b.nonce--
+			b.difficulty = 1 << 40
+			if b.Validate() || b.MeetsDifficulty() {
+				t.Fatal("\t\tShould hash the difficulty")
+			}
+			t.Log("\t\tShould hash the nonce and the difficulty")
+		}
+		t.Log("\tGiven cancelled mining")
+		{
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			b := New(ts, h, owner, &prevH, data)
+			if err := b.Mine(ctx, 1<<40); err != context.Canceled {
+				t.Fatal("\t\tShould stop mining, got err: ", err)
+			}
+			t.Log("\t\tShould stop mining")
+			if err := b.Mine(context.Background(), 0); err != ZeroDifficultyErr {
+				t.Fatal("\t\tShould return ZeroDifficultyErr, got err: ", err)
+			}
+			t.Log("\t\tShould return ZeroDifficultyErr")
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package block
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EncodingVersion is the version of the binary and JSON block layout.
+// Binary layout of version 2, integers are big endian, variable length
+// fields are prefixed with their length as uvarint:
+//
+//	version (1 byte) | hashVersion (1) | ts (8) | height (8) | owner
+//	| prevHash flag (1) | prevHash (32, when flag is 1) | data | msg
+//	| msgSig | difficulty (8) | nonce (8) | hash (32) | signature | keyID
+//
+// Difficulty and nonce are present only in HashV3 blocks.
+// Version 1 lacks hashVersion, its blocks are hashed with HashV1.
+// It is still decoded, so blocks stored before remain readable.
+const EncodingVersion byte = 2
+
+// encodingV1 is the layout before hash versions were recorded
+const encodingV1 byte = 1
+
+// maxFieldSize limits length of a single variable length field
+const maxFieldSize = 16 << 20
+
+var (
+	UnsupportedVersionErr = errors.New("Block encoding version is not supported")
+	MalformedBlockErr     = errors.New("Encoded block is malformed")
+)
+
+// blockJSON struct is the JSON form of the block
+type blockJSON struct {
+	Version           byte    `json:"version"`
+	HashVersion       byte    `json:"hashVersion,omitempty"`
+	Time              int64   `json:"time"`
+	Height            int     `json:"height"`
+	Owner             string  `json:"owner"`
+	PreviousBlockHash *string `json:"previousBlockHash"`
+	Body              string  `json:"body"`
+	Message           string  `json:"message,omitempty"`
+	MessageSignature  string  `json:"messageSignature,omitempty"`
+	Difficulty        uint64  `json:"difficulty,omitempty"`
+	Nonce             uint64  `json:"nonce,omitempty"`
+	Hash              string  `json:"hash"`
+	Signature         string  `json:"signature,omitempty"`
+	KeyID             string  `json:"keyId,omitempty"`
+}
+
+// MarshalBinary method encodes all fields of the block, including
+// the stored hash and producer signature
+func (b *Block) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(EncodingVersion)
+	buf.WriteByte(b.hashVersion)
+	var fixed [16]byte
+	binary.BigEndian.PutUint64(fixed[0:8], uint64(b.ts))
+	binary.BigEndian.PutUint64(fixed[8:16], uint64(b.height))
+	buf.Write(fixed[:])
+	writeField(&buf, []byte(b.owner))
+	if b.prevHash == nil {
+		buf.WriteByte(0)
+	} else {
+		buf.WriteByte(1)
+		buf.Write(b.prevHash[:])
+	}
+	writeField(&buf, b.data)
+	writeField(&buf, []byte(b.msg))
+	writeField(&buf, []byte(b.msgSig))
+	if b.hashVersion == HashV3 {
+		binary.BigEndian.PutUint64(fixed[0:8], b.difficulty)
+		binary.BigEndian.PutUint64(fixed[8:16], b.nonce)
+		buf.Write(fixed[:])
+	}
+	buf.Write(b.hash[:])
+	writeField(&buf, b.signature)
+	writeField(&buf, []byte(b.keyID))
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary method decodes the block encoded by MarshalBinary.
+// The block is not validated, use Validate for that.
+func (b *Block) UnmarshalBinary(encoded []byte) error {
+	reader := bytes.NewReader(encoded)
+	version, err := reader.ReadByte()
+	if err != nil {
+		return MalformedBlockErr
+	}
+	var (
+		decoded Block
+		fixed   [16]byte
+		flag    byte
+	)
+	switch version {
+	case encodingV1:
+		decoded.hashVersion = HashV1
+	case EncodingVersion:
+		if decoded.hashVersion, err = reader.ReadByte(); err != nil {
+			return MalformedBlockErr
+		}
+	default:
+		return UnsupportedVersionErr
+	}
+	if _, err := io.ReadFull(reader, fixed[:]); err != nil {
+		return MalformedBlockErr
+	}
+	decoded.ts = int64(binary.BigEndian.Uint64(fixed[0:8]))
+	decoded.height = int(binary.BigEndian.Uint64(fixed[8:16]))
+	owner, err := readField(reader)
+	if err != nil {
+		return err
+	}
+	decoded.owner = string(owner)
+	if flag, err = reader.ReadByte(); err != nil || flag > 1 {
+		return MalformedBlockErr
+	}
+	if flag == 1 {
+		decoded.prevHash = new([sha256.Size]byte)
+		if _, err := io.ReadFull(reader, decoded.prevHash[:]); err != nil {
+			return MalformedBlockErr
+		}
+	}
+	if decoded.data, err = readField(reader); err != nil {
+		return err
+	}
+	msg, err := readField(reader)
+	if err != nil {
+		return err
+	}
+	decoded.msg = string(msg)
+	msgSig, err := readField(reader)
+	if err != nil {
+		return err
+	}
+	decoded.msgSig = string(msgSig)
+	if decoded.hashVersion == HashV3 {
+		if _, err := io.ReadFull(reader, fixed[:]); err != nil {
+			return MalformedBlockErr
+		}
+		decoded.difficulty = binary.BigEndian.Uint64(fixed[0:8])
+		decoded.nonce = binary.BigEndian.Uint64(fixed[8:16])
+	}
+	if _, err := io.ReadFull(reader, decoded.hash[:]); err != nil {
+		return MalformedBlockErr
+	}
+	if decoded.signature, err = readField(reader); err != nil {
+		return err
+	}
+	keyID, err := readField(reader)
+	if err != nil {
+		return err
+	}
+	decoded.keyID = string(keyID)
+	if reader.Len() != 0 {
+		return MalformedBlockErr
+	}
+	*b = decoded
+	return nil
+}
+
+// MarshalJSON method encodes all fields of the block as JSON,
+// binary fields are hex encoded
+func (b *Block) MarshalJSON() ([]byte, error) {
+	var prevHash *string
+	if b.prevHash != nil {
+		encoded := hex.EncodeToString(b.prevHash[:])
+		prevHash = &encoded
+	}
+	return json.Marshal(blockJSON{
+		Version:           EncodingVersion,
+		HashVersion:       b.hashVersion,
+		Time:              b.ts,
+		Height:            b.height,
+		Owner:             b.owner,
+		PreviousBlockHash: prevHash,
+		Body:              string(b.data),
+		Message:           b.msg,
+		MessageSignature:  b.msgSig,
+		Difficulty:        b.difficulty,
+		Nonce:             b.nonce,
+		Hash:              hex.EncodeToString(b.hash[:]),
+		Signature:         hex.EncodeToString(b.signature),
+		KeyID:             b.keyID,
+	})
+}
+
+// UnmarshalJSON method decodes the block encoded by MarshalJSON
+func (b *Block) UnmarshalJSON(encoded []byte) error {
+	var (
+		value   blockJSON
+		decoded Block
+	)
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return errors.New(fmt.Sprintf("%s: %s", MalformedBlockErr, err))
+	}
+	switch value.Version {
+	case encodingV1:
+		decoded.hashVersion = HashV1
+	case EncodingVersion:
+		decoded.hashVersion = value.HashVersion
+	default:
+		return UnsupportedVersionErr
+	}
+	decoded.ts = value.Time
+	decoded.height = value.Height
+	decoded.owner = value.Owner
+	if value.PreviousBlockHash != nil {
+		decoded.prevHash = new([sha256.Size]byte)
+		if err := decodeHash(*value.PreviousBlockHash, decoded.prevHash); err != nil {
+			return err
+		}
+	}
+	if value.Body != "" {
+		if _, err := hex.DecodeString(value.Body); err != nil {
+			return MalformedBlockErr
+		}
+		decoded.data = []byte(value.Body)
+	}
+	decoded.msg = value.Message
+	decoded.msgSig = value.MessageSignature
+	if decoded.hashVersion == HashV3 {
+		decoded.difficulty = value.Difficulty
+		decoded.nonce = value.Nonce
+	}
+	if err := decodeHash(value.Hash, &decoded.hash); err != nil {
+		return err
+	}
+	if value.Signature != "" {
+		signature, err := hex.DecodeString(value.Signature)
+		if err != nil {
+			return MalformedBlockErr
+		}
+		decoded.signature = signature
+	}
+	decoded.keyID = value.KeyID
+	*b = decoded
+	return nil
+}
+
+func writeField(buf *bytes.Buffer, field []byte) {
+	var length [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(length[:], uint64(len(field)))
+	buf.Write(length[:n])
+	buf.Write(field)
+}
+
+// readField fn reads length prefixed field, empty field is returned as nil
+func readField(reader *bytes.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil || length > maxFieldSize || length > uint64(reader.Len()) {
+		return nil, MalformedBlockErr
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	field := make([]byte, length)
+	if _, err := io.ReadFull(reader, field); err != nil {
+		return nil, MalformedBlockErr
+	}
+	return field, nil
+}
+
+func decodeHash(s string, hash *[sha256.Size]byte) error {
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != sha256.Size {
+		return MalformedBlockErr
+	}
+	copy(hash[:], raw)
+	return nil
+}
@@ -0,0 +1,80 @@
+package block
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// miningCheckInterval is the number of nonces tried between checks
+// whether mining was cancelled
+const miningCheckInterval = 1 << 12
+
+var (
+	ZeroDifficultyErr = errors.New("Difficulty must be greater than 0")
+	NonceSpaceErr     = errors.New("No nonce meets the difficulty")
+)
+
+// maxTarget is the largest hash value, target of difficulty 1
+var maxTarget = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 8*sha256.Size), big.NewInt(1))
+
+// Target fn returns the largest hash meeting the difficulty,
+// a block takes difficulty hashes on average to mine
+func Target(difficulty uint64) *big.Int {
+	if difficulty == 0 {
+		difficulty = 1
+	}
+	return new(big.Int).Div(maxTarget, new(big.Int).SetUint64(difficulty))
+}
+
+// Mine method searches for the nonce which makes the block hash meet
+// the difficulty and switches the block to HashV3. It returns ctx error
+// when ctx is done before the nonce is found. Mining drops the producer
+// signature, the block has to be signed afterwards.
+func (b *Block) Mine(ctx context.Context, difficulty uint64) error {
+	if difficulty == 0 {
+		return ZeroDifficultyErr
+	}
+	target := Target(difficulty)
+	b.hashVersion = HashV3
+	b.difficulty = difficulty
+	b.signature = nil
+	b.keyID = ""
+	var value big.Int
+	for nonce := uint64(0); ; nonce++ {
+		if nonce%miningCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		b.nonce = nonce
+		b.hash = b.CalculateHash()
+		if value.SetBytes(b.hash[:]).Cmp(target) <= 0 {
+			return nil
+		}
+		if nonce == ^uint64(0) {
+			return NonceSpaceErr
+		}
+	}
+}
+
+// MeetsDifficulty method reports whether the stored hash meets the
+// difficulty recorded in the block. Blocks which were not mined do not.
+func (b *Block) MeetsDifficulty() bool {
+	if b.hashVersion != HashV3 || b.difficulty == 0 {
+		return false
+	}
+	return new(big.Int).SetBytes(b.hash[:]).Cmp(Target(b.difficulty)) <= 0
+}
+
+// GetDifficulty method returns proof-of-work difficulty of the block,
+// 0 when the block was not mined
+func (b *Block) GetDifficulty() uint64 {
+	return b.difficulty
+}
+
+// GetNonce method returns proof-of-work nonce of the block
+func (b *Block) GetNonce() uint64 {
+	return b.nonce
+}
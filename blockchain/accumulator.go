@@ -0,0 +1,45 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/starchain/mmr"
+)
+
+// InclusionProof struct proves the block with BlockHash is at Height
+// of the chain committed to by Root, see mmr.Verify
+type InclusionProof struct {
+	Height    int
+	BlockHash [sha256.Size]byte
+	Root      [sha256.Size]byte
+	Proof     mmr.Proof
+}
+
+// GetAccumulatorRoot method returns root of the Merkle Mountain Range
+// over hashes of all blocks
+func (b *Blockchain) GetAccumulatorRoot() [sha256.Size]byte {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.accumulator.Root()
+}
+
+// GetInclusionProof method returns proof of the block at the height
+// against the current accumulator root
+func (b *Blockchain) GetInclusionProof(height int) (InclusionProof, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if height < 0 || height >= len(b.chain) {
+		return InclusionProof{}, errors.New(fmt.Sprintf("Invalid height: %v", height))
+	}
+	proof, err := b.accumulator.Proof(uint64(height))
+	if err != nil {
+		return InclusionProof{}, err
+	}
+	return InclusionProof{
+		Height:    height,
+		BlockHash: b.chain[height].GetHash(),
+		Root:      b.accumulator.Root(),
+		Proof:     proof,
+	}, nil
+}
@@ -3,13 +3,26 @@
 package blockchain
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/starchain/bitcoin"
 	"github.com/starchain/block"
 	"github.com/starchain/contracts"
-	"regexp"
+	"github.com/starchain/didkey"
+	"github.com/starchain/ethereum"
+	"github.com/starchain/mmr"
+	"github.com/starchain/multisig"
+	"io"
+	"log"
+	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -17,11 +30,116 @@ import (
 // Blockchain struct consists of the slice of Blocks (the chain).
 // It allows basic operations such as adding block, checking height,
 // checking blockchain integrity, fetching owner's blocks,
-// getting block by id
+// getting block by id.
+// Ownership proofs are checked by verifiers registered per address scheme.
+// Messages to sign are single-use challenges issued by the blockchain.
+// Every block is signed by the node key, ValidateChain accepts only
+// signatures of trusted producers.
 type Blockchain struct {
-	chain []*block.Block
-	mutex sync.RWMutex
-	clock contracts.Clock
+	chain       []*block.Block
+	byHash      map[[sha256.Size]byte]*block.Block
+	byOwner     map[string][]int
+	accumulator *mmr.MMR
+	mutex       sync.RWMutex
+	clock       contracts.Clock
+	verifiers   []contracts.Verifier
+	challenges  map[string]*issuedChallenge
+	// nonces of challenges in issue order, expired ones are pruned
+	// from the front
+	challengeQueue []string
+	// number of unused challenges by address
+	addrChallenges map[string]int
+	random         io.Reader
+	config         Config
+	trustedKeys    map[string]ed25519.PublicKey
+	store          Store
+	// mining is done when mining was stopped, new blocks are not mined then
+	mining     context.Context
+	stopMining context.CancelFunc
+	// closed is done when the blockchain is closed, to stop
+	// tree head publication
+	closed     context.Context
+	markClosed context.CancelFunc
+	// published tree heads, guarded by treeHeadsMutex
+	treeHeads      []TreeHead
+	treeHeadsMutex sync.Mutex
+	// checkpoints of validated chain, guarded by checkpointMutex
+	checkpoints     []Checkpoint
+	checkpointMutex sync.Mutex
+	// validation jobs by ID in start order, guarded by jobsMutex
+	jobs      map[string]*validationJob
+	jobOrder  []string
+	jobsMutex sync.Mutex
+}
+
+// Config struct contains Blockchain settings passed to New.
+// Zero values are replaced with defaults, see DefaultConfig, except
+// MaxClockSkew and MaxClockDrift, where 0 means no tolerance of clocks
+// running fast, and TreeHeadInterval, where 0 disables tree heads.
+// Start from DefaultConfig to get the default tolerances.
+type Config struct {
+	// ChallengeTTL is the number of seconds a challenge stays valid
+	ChallengeTTL int64
+	// MaxChallenges is the number of outstanding challenges, requests
+	// beyond it fail with TooManyChallengesErr until older ones expire
+	MaxChallenges int
+	// MaxAddressChallenges is the number of unused challenges of a single
+	// address, requests beyond it fail with TooManyAddressChallengesErr
+	// until the address uses them or they expire
+	MaxAddressChallenges int
+	// MaxClockSkew is the number of seconds a message timestamp may be
+	// ahead of the blockchain clock, for clients with clocks running fast.
+	// It is not defaulted, 0 rejects every timestamp from the future,
+	// negative values are treated as 0.
+	MaxClockSkew int64
+	// MaxClockDrift is the number of seconds a block timestamp may be
+	// ahead of the node clock before ValidateChain reports it.
+	// It is not defaulted, 0 reports every block from the future,
+	// negative values are treated as 0.
+	MaxClockDrift int64
+	// Domain is the name of the service put in every challenge
+	Domain string
+	// ChainID identifies the chain, challenges of other chains are rejected
+	ChainID string
+	// LegacyChallenges switches issuing to addr:ts:starRegistry messages
+	// and accepts them on submission, for clients not upgraded yet
+	LegacyChallenges bool
+	// SigningKey is the Ed25519 key the node signs new blocks with,
+	// a random one is generated by New when it is not set
+	SigningKey ed25519.PrivateKey
+	// TrustedKeys are public keys of other producers whose blocks are valid,
+	// the public key of SigningKey is always trusted
+	TrustedKeys []ed25519.PublicKey
+	// Genesis defines block 0 of the network, when it is not set
+	// the genesis block is created with the current time
+	Genesis *Genesis
+	// PoW enables mining of new blocks, when it is not set
+	// blocks are created instantly
+	PoW *PoWConfig
+	// TreeHeadInterval is the number of seconds between signed tree heads
+	// published by Open, 0 disables publication
+	TreeHeadInterval int64
+}
+
+// Challenge struct is the ownership verification message together with
+// the time range in which it can be used to submit a star.
+type Challenge struct {
+	// Address the challenge was issued to, in its canonical spelling
+	Address      string
+	Message      string
+	IssuedAt     int64
+	ExpiresAt    int64
+	ValidFor     int64
+	MaxClockSkew int64
+}
+
+// issuedChallenge struct describes ownership verification message issued
+// to the address. It is kept until it expires, so reuse can be detected.
+type issuedChallenge struct {
+	addr     string
+	msg      string
+	issuedAt int64
+	used     bool
 }
 
 // StarRequest struct contains all data requiered to create a new star
@@ -37,28 +155,325 @@ type BlockchainClock struct{}
 
 const FIVE_MIN int64 = 5 * 60
 
+// DefaultMaxClockSkew is the default tolerance of timestamps from the future
+const DefaultMaxClockSkew int64 = 30
+
+// DefaultMaxClockDrift is the default tolerance of block timestamps
+// ahead of the node clock
+const DefaultMaxClockDrift int64 = FIVE_MIN
+
+// DefaultMaxChallenges is the default number of outstanding challenges
+const DefaultMaxChallenges = 100000
+
+// DefaultMaxAddressChallenges is the default number of unused challenges
+// of a single address
+const DefaultMaxAddressChallenges = 10
+
+// NonceSize is the number of random bytes in every challenge
+const NonceSize = 16
+
+const (
+	// DefaultDomain is the domain put in challenges when none is configured
+	DefaultDomain = "starchain.local"
+	// DefaultChainID is the chain ID put in challenges when none is configured
+	DefaultChainID = "starchain-1"
+)
+
 func (b BlockchainClock) GetTime() int64 {
 	return time.Now().Unix()
 }
 
 var (
-	EmptyAddrErr       = errors.New("Address is empty")
-	EmptyMsgErr        = errors.New("Message is empty")
-	EmptySigErr        = errors.New("Signature is empty")
-	WrongTSErr         = errors.New("Message is not within allowed time range")
-	MsgSigMistmatchErr = errors.New("Message does not match the signature")
+	EmptyAddrErr        = errors.New("Address is empty")
+	EmptyMsgErr         = errors.New("Message is empty")
+	EmptySigErr         = errors.New("Signature is empty")
+	WrongTSErr          = errors.New("Message is not within allowed time range")
+	MsgSigMistmatchErr  = errors.New("Message does not match the signature")
+	UnknownSchemeErr    = errors.New("Address does not belong to any registered scheme")
+	UnknownChallengeErr = errors.New("Message was not issued by this blockchain")
+	UsedChallengeErr    = errors.New("Message was already used to submit a star")
+	InvalidStoreErr     = errors.New("Stored chain is invalid")
+)
+
+// Challenge limit errors are shared with contracts, so the API can
+// tell them apart behind BlockchainProxy
+var (
+	TooManyChallengesErr        = contracts.TooManyChallengesErr
+	TooManyAddressChallengesErr = contracts.TooManyAddressChallengesErr
 )
 
-// Factory function returning new Blockchain
-func New(clock contracts.Clock) *Blockchain {
+// DefaultVerifiers fn returns verifiers registered by New:
+// Bitcoin message signing, Ethereum personal_sign and Ed25519 did:key
+func DefaultVerifiers() []contracts.Verifier {
+	return []contracts.Verifier{
+		bitcoin.Verifier{},
+		ethereum.Verifier{},
+		didkey.Verifier{},
+	}
+}
+
+// DefaultConfig fn returns configuration with five minutes challenge TTL,
+// DefaultMaxChallenges, DefaultMaxAddressChallenges, DefaultMaxClockSkew,
+// DefaultDomain and DefaultChainID
+func DefaultConfig() Config {
+	return Config{
+		ChallengeTTL:         FIVE_MIN,
+		MaxChallenges:        DefaultMaxChallenges,
+		MaxAddressChallenges: DefaultMaxAddressChallenges,
+		MaxClockSkew:         DefaultMaxClockSkew,
+		MaxClockDrift:        DefaultMaxClockDrift,
+		Domain:               DefaultDomain,
+		ChainID:              DefaultChainID,
+	}
+}
+
+// withDefaults method returns the config with unset values filled in,
+// MaxClockSkew, MaxClockDrift and TreeHeadInterval are only clamped to 0
+func (c Config) withDefaults() Config {
+	defaults := DefaultConfig()
+	if c.ChallengeTTL <= 0 {
+		c.ChallengeTTL = defaults.ChallengeTTL
+	}
+	if c.MaxChallenges <= 0 {
+		c.MaxChallenges = defaults.MaxChallenges
+	}
+	if c.MaxAddressChallenges <= 0 {
+		c.MaxAddressChallenges = defaults.MaxAddressChallenges
+	}
+	if c.MaxClockSkew < 0 {
+		c.MaxClockSkew = 0
+	}
+	if c.MaxClockDrift < 0 {
+		c.MaxClockDrift = 0
+	}
+	if c.Domain == "" {
+		c.Domain = defaults.Domain
+	}
+	if c.ChainID == "" && c.Genesis != nil {
+		c.ChainID = c.Genesis.ChainID
+	}
+	if c.ChainID == "" {
+		c.ChainID = defaults.ChainID
+	}
+	if c.TreeHeadInterval < 0 {
+		c.TreeHeadInterval = 0
+	}
+	if c.PoW != nil {
+		pow := c.PoW.withDefaults()
+		c.PoW = &pow
+	}
+	return c
+}
+
+// trustedKeys method returns keys of trusted producers by key ID:
+// TrustedKeys, the public key of SigningKey when it is set
+// and the producer key of Genesis
+func (c Config) trustedKeys() map[string]ed25519.PublicKey {
+	keys := make(map[string]ed25519.PublicKey)
+	for _, pubKey := range c.TrustedKeys {
+		keys[block.KeyID(pubKey)] = pubKey
+	}
+	if c.SigningKey != nil {
+		producerKey := c.SigningKey.Public().(ed25519.PublicKey)
+		keys[block.KeyID(producerKey)] = producerKey
+	}
+	if c.Genesis != nil {
+		if genesisKey, _ := c.Genesis.producerKey(); genesisKey != nil {
+			keys[block.KeyID(genesisKey)] = genesisKey
+		}
+	}
+	return keys
+}
+
+// Factory function returning new Blockchain kept in memory
+func New(clock contracts.Clock, config Config) *Blockchain {
+	blockchain, err := Open(clock, config, NewMemoryStore())
+	if err != nil {
+		log.Panic(err)
+	}
+	return blockchain
+}
+
+// Open fn returns Blockchain persisted in the store.
+// Empty store gets the genesis block, blocks of non-empty store are
+// loaded and validated, invalid chain is reported as InvalidStoreErr.
+// Stored genesis block which does not match config.Genesis is reported
+// as GenesisMismatchErr.
+func Open(clock contracts.Clock, config Config, store Store) (*Blockchain, error) {
 	var (
 		blockchain Blockchain
 	)
-	owner := ""
-	data := []byte("Genesis Gopher Block")
+	blockchain.store = store
 	blockchain.clock = clock
-	blockchain.AddBlock(owner, data)
-	return &blockchain
+	blockchain.config = config.withDefaults()
+	blockchain.challenges = make(map[string]*issuedChallenge)
+	blockchain.addrChallenges = make(map[string]int)
+	blockchain.byHash = make(map[[sha256.Size]byte]*block.Block)
+	blockchain.byOwner = make(map[string][]int)
+	blockchain.accumulator = mmr.New()
+	blockchain.jobs = make(map[string]*validationJob)
+	blockchain.random = rand.Reader
+	blockchain.mining, blockchain.stopMining = context.WithCancel(context.Background())
+	blockchain.closed, blockchain.markClosed = context.WithCancel(context.Background())
+	if blockchain.config.SigningKey == nil {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Panic("Could not generate signing key: ", err)
+		}
+		blockchain.config.SigningKey = key
+	}
+	blockchain.trustedKeys = blockchain.config.trustedKeys()
+	if genesis := blockchain.config.Genesis; genesis != nil {
+		if err := genesis.Validate(); err != nil {
+			return nil, err
+		}
+		if genesis.ChainID != blockchain.config.ChainID {
+			return nil, GenesisChainIDErr
+		}
+	}
+	for _, v := range DefaultVerifiers() {
+		blockchain.RegisterVerifier(v)
+	}
+	// Co-owned stars are verified by the verifiers of owner addresses
+	blockchain.RegisterVerifier(multisig.NewVerifier(blockchain.VerifierFor))
+	blocks, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		genesis, err := blockchain.genesisBlock()
+		if err != nil {
+			return nil, err
+		}
+		blockchain.mutex.Lock()
+		defer blockchain.mutex.Unlock()
+		if err := blockchain.appendBlock(genesis); err != nil {
+			return nil, err
+		}
+		if err := blockchain.loadTreeHeads(); err != nil {
+			return nil, err
+		}
+		blockchain.startTreeHeads()
+		return &blockchain, nil
+	}
+	if genesis := blockchain.config.Genesis; genesis != nil && blocks[0].GetHash() != genesis.Block().GetHash() {
+		return nil, errors.New(fmt.Sprintf("%s: stored %x, configured %x", GenesisMismatchErr, blocks[0].GetHash(), genesis.Block().GetHash()))
+	}
+	blockchain.chain = blocks
+	blockchain.reindex()
+	if errs := blockchain.ValidateChain(); len(errs) > 0 {
+		return nil, errors.New(fmt.Sprintf("%s: %s", InvalidStoreErr, errs[0]))
+	}
+	if err := blockchain.loadTreeHeads(); err != nil {
+		return nil, err
+	}
+	blockchain.startTreeHeads()
+	return &blockchain, nil
+}
+
+// genesisBlock method returns genesis block signed by the node.
+// Without genesis configuration it is created with the current time.
+func (b *Blockchain) genesisBlock() (*block.Block, error) {
+	var genesis *block.Block
+	if b.config.Genesis == nil {
+		var prevHash [sha256.Size]byte
+		genesis = block.New(b.clock.GetTime(), 0, "", &prevHash, []byte("Genesis Gopher Block"))
+	} else {
+		genesisKey, _ := b.config.Genesis.producerKey()
+		if genesisKey != nil && !bytes.Equal(genesisKey, b.GetProducerKey()) {
+			return nil, GenesisProducerErr
+		}
+		genesis = b.config.Genesis.Block()
+	}
+	genesis.Sign(b.config.SigningKey)
+	return genesis, nil
+}
+
+// StopMining method cancels mining of pending blocks, their submissions
+// return MiningCancelledErr. Blocks which do not have to be mined are
+// still added, so the store stays usable until Close.
+func (b *Blockchain) StopMining() {
+	b.stopMining()
+}
+
+// Close method stops mining and tree head publication
+// and closes the store of the blockchain
+func (b *Blockchain) Close() error {
+	b.stopMining()
+	b.markClosed()
+	return b.store.Close()
+}
+
+// RegisterVerifier method adds the verifier of a new address scheme.
+// Verifiers are consulted in registration order, the first one which
+// supports the address is used. Registering the same scheme twice
+// replaces the previous verifier.
+func (b *Blockchain) RegisterVerifier(v contracts.Verifier) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for i, registered := range b.verifiers {
+		if registered.Scheme() == v.Scheme() {
+			b.verifiers[i] = v
+			return
+		}
+	}
+	b.verifiers = append(b.verifiers, v)
+}
+
+// VerifierFor method returns the verifier of the address scheme
+func (b *Blockchain) VerifierFor(addr string) (contracts.Verifier, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.verifierFor(addr)
+}
+
+// verifierFor method is VerifierFor for callers holding the lock
+func (b *Blockchain) verifierFor(addr string) (contracts.Verifier, error) {
+	for _, v := range b.verifiers {
+		if v.Supports(addr) {
+			return v, nil
+		}
+	}
+	return nil, UnknownSchemeErr
+}
+
+// CanonicalAddress method returns the address in the canonical spelling
+// of its scheme, e.g. EIP-55 checksummed Ethereum addresses and lower
+// case bech32 addresses, so every spelling of an address owns the same
+// stars. Owners of a policy are canonicalized and sorted again.
+// Addresses which are malformed or of unknown scheme are returned unchanged.
+func (b *Blockchain) CanonicalAddress(addr string) string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.canonicalAddress(addr)
+}
+
+// canonicalAddress method is CanonicalAddress for callers holding the lock
+func (b *Blockchain) canonicalAddress(addr string) string {
+	if multisig.IsPolicy(addr) {
+		policy, err := multisig.Parse(addr)
+		if err != nil {
+			return addr
+		}
+		owners := make([]string, len(policy.Owners))
+		for i, owner := range policy.Owners {
+			owners[i] = b.canonicalAddress(owner)
+		}
+		if canonical, err := multisig.New(policy.M, owners); err == nil {
+			return canonical.String()
+		}
+		return addr
+	}
+	verifier, err := b.verifierFor(addr)
+	if err != nil {
+		return addr
+	}
+	if c, ok := verifier.(contracts.Canonicalizer); ok {
+		if canonical, err := c.CanonicalAddress(addr); err == nil {
+			return canonical
+		}
+	}
+	return addr
 }
 
 func (b *Blockchain) GetChainHeight() int {
@@ -68,48 +483,283 @@ func (b *Blockchain) GetChainHeight() int {
 	return height
 }
 
-func (b *Blockchain) RequestMessageOwnershipVerification(addr string) (string, error) {
+// GetProducerKey method returns public key the node signs blocks with
+func (b *Blockchain) GetProducerKey() ed25519.PublicKey {
+	return b.config.SigningKey.Public().(ed25519.PublicKey)
+}
+
+// GetConfig method returns effective configuration of the blockchain
+func (b *Blockchain) GetConfig() Config {
+	return b.config
+}
+
+// RequestMessageOwnershipVerification method issues a new challenge
+// for the address. The message contains random nonce and can be used
+// to submit exactly one star within Config.ChallengeTTL.
+// When star is given, only a star with these coordinates can be submitted.
+func (b *Blockchain) RequestMessageOwnershipVerification(addr string, star *StarCoordinates) (Challenge, error) {
 	if addr == "" {
-		return "", EmptyAddrErr
+		return Challenge{}, EmptyAddrErr
 	}
-	b.mutex.RLock()
+	if !isToken(addr) {
+		return Challenge{}, malformed("invalid address")
+	}
+	addr = b.CanonicalAddress(addr)
+	if star != nil {
+		if b.config.LegacyChallenges {
+			return Challenge{}, LegacyStarErr
+		}
+		if star.RA == "" || star.Dec == "" {
+			return Challenge{}, EmptyStarCoordErr
+		}
+		if strings.ContainsAny(star.RA+star.Dec, "\r\n") {
+			return Challenge{}, malformed("invalid star coordinates")
+		}
+	}
+	nonce := make([]byte, NonceSize)
+	if _, err := io.ReadFull(b.random, nonce); err != nil {
+		return Challenge{}, errors.New(fmt.Sprintf("Could not generate nonce: %s", err))
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
 	ts := b.clock.GetTime()
-	b.mutex.RUnlock()
-	return fmt.Sprintf("%s:%d:starRegistry", addr, ts), nil
+	b.pruneChallenges(ts)
+	if len(b.challenges) >= b.config.MaxChallenges {
+		return Challenge{}, TooManyChallengesErr
+	}
+	if b.addrChallenges[addr] >= b.config.MaxAddressChallenges {
+		return Challenge{}, TooManyAddressChallengesErr
+	}
+	m := ChallengeMessage{
+		Legacy:         b.config.LegacyChallenges,
+		Domain:         b.config.Domain,
+		Address:        addr,
+		ChainID:        b.config.ChainID,
+		Nonce:          hex.EncodeToString(nonce),
+		IssuedAt:       ts,
+		ExpirationTime: ts + b.config.ChallengeTTL,
+		Star:           star,
+	}
+	msg := m.String()
+	b.addChallenge(m.Nonce, &issuedChallenge{addr: addr, msg: msg, issuedAt: ts})
+	return Challenge{
+		Address:      addr,
+		Message:      msg,
+		IssuedAt:     ts,
+		ExpiresAt:    m.ExpirationTime,
+		ValidFor:     b.config.ChallengeTTL,
+		MaxClockSkew: b.config.MaxClockSkew,
+	}, nil
 }
 
-func (b *Blockchain) IsMessageOutdated(addr string, msg string) (bool, error) {
-	regex := regexp.MustCompile(fmt.Sprintf("%s:(\\d{10,}):starRegistry", addr))
-	if chunks := regex.FindStringSubmatch(msg); len(chunks) != 2 {
-		return false, errors.New(fmt.Sprintf("Message %s is mlaformed", msg))
-	} else if ts, err := strconv.ParseInt(chunks[1], 10, 64); err != nil {
-		return false, errors.New(fmt.Sprintf("Chunk %v is not a number", chunks[1]))
+// addChallenge method keeps the issued challenge until it expires.
+// It has to be called with the write lock held.
+func (b *Blockchain) addChallenge(nonce string, c *issuedChallenge) {
+	b.challenges[nonce] = c
+	b.challengeQueue = append(b.challengeQueue, nonce)
+	b.addrChallenges[c.addr]++
+}
+
+// releaseChallenge method stops counting the challenge against
+// the limit of its address once it is used or expires.
+// It has to be called with the write lock held.
+func (b *Blockchain) releaseChallenge(c *issuedChallenge) {
+	if c.used {
+		return
+	}
+	if b.addrChallenges[c.addr] <= 1 {
+		delete(b.addrChallenges, c.addr)
 	} else {
-		b.mutex.RLock()
-		now := b.clock.GetTime()
-		b.mutex.RUnlock()
-		duration := now - ts
-		if duration < 0 {
-			return true, WrongTSErr
+		b.addrChallenges[c.addr]--
+	}
+}
+
+// pruneChallenges method removes expired challenges from the front of
+// the queue, it stops at the first one still valid.
+// It has to be called with the write lock held.
+func (b *Blockchain) pruneChallenges(now int64) {
+	for len(b.challengeQueue) > 0 {
+		nonce := b.challengeQueue[0]
+		c, ok := b.challenges[nonce]
+		if ok && now-c.issuedAt < b.config.ChallengeTTL {
+			return
+		}
+		if ok {
+			b.releaseChallenge(c)
 		}
-		return duration >= FIVE_MIN, nil
+		delete(b.challenges, nonce)
+		b.challengeQueue = b.challengeQueue[1:]
 	}
 }
 
-func (b *Blockchain) AddBlock(owner string, starData []byte) *block.Block {
-	var prevHash [sha256.Size]byte
+// checkChallenge method returns error when the message was not issued
+// to the address or when it was already used.
+// It has to be called with the lock held.
+func (b *Blockchain) checkChallenge(addr string, m ChallengeMessage, msg string) error {
+	c, ok := b.challenges[m.Nonce]
+	if !ok || c.addr != addr || c.msg != msg {
+		return UnknownChallengeErr
+	}
+	if c.used {
+		return UsedChallengeErr
+	}
+	return nil
+}
+
+// consumeChallenge method marks the challenge as used, so it can not be
+// submitted again. It fails when another request consumed it first.
+func (b *Blockchain) consumeChallenge(addr string, m ChallengeMessage, msg string) error {
 	b.mutex.Lock()
-	ts := b.clock.GetTime()
-	height := len(b.chain)
-	if height > 0 {
-		prevHash = b.chain[height-1].GetHash()
+	defer b.mutex.Unlock()
+	if err := b.checkChallenge(addr, m, msg); err != nil {
+		return err
+	}
+	b.releaseChallenge(b.challenges[m.Nonce])
+	b.challenges[m.Nonce].used = true
+	return nil
+}
+
+// parseMessage method parses the challenge and checks that it was issued
+// to the address for this domain and chain.
+// Legacy messages are accepted only with Config.LegacyChallenges.
+func (b *Blockchain) parseMessage(addr string, msg string) (ChallengeMessage, error) {
+	m, err := ParseChallenge(msg, b.config.LegacyChallenges)
+	if err != nil {
+		return m, err
+	}
+	if m.Address != addr {
+		return m, malformed("address does not match")
+	}
+	if !m.Legacy && (m.Domain != b.config.Domain || m.ChainID != b.config.ChainID) {
+		return m, WrongDomainErr
+	}
+	return m, nil
+}
+
+// isOutdated method checks the message time range against the clock.
+// Structured messages expire at their expiration time, legacy ones
+// Config.ChallengeTTL after they were issued.
+func (b *Blockchain) isOutdated(m ChallengeMessage) (bool, error) {
+	b.mutex.RLock()
+	now := b.clock.GetTime()
+	b.mutex.RUnlock()
+	if m.IssuedAt-now > b.config.MaxClockSkew {
+		return true, WrongTSErr
+	}
+	if m.Legacy {
+		return now-m.IssuedAt >= b.config.ChallengeTTL, nil
+	}
+	return now >= m.ExpirationTime, nil
+}
+
+func (b *Blockchain) IsMessageOutdated(addr string, msg string) (bool, error) {
+	m, err := b.parseMessage(addr, msg)
+	if err != nil {
+		return false, err
+	}
+	return b.isOutdated(m)
+}
+
+// AddBlock method appends a new block without ownership proof.
+// It panics when the block can not be stored.
+func (b *Blockchain) AddBlock(owner string, starData []byte) *block.Block {
+	newBlock, err := b.addBlock(owner, starData, "", "")
+	if err != nil {
+		log.Panic("Could not store block: ", err)
 	}
-	newBlock := block.New(ts, height, owner, &prevHash, starData)
-	b.chain = append(b.chain, newBlock)
-	b.mutex.Unlock()
 	return newBlock
 }
 
+// addBlock method appends a new block signed by the node.
+// Star blocks carry the verified message and owner's signature.
+// The block is added to the chain only after the store persisted it.
+// With proof-of-work the block is mined without holding the lock, when
+// another block is added meanwhile it is mined again on top of that one.
+// Mining is stopped by Close, MiningCancelledErr is returned then.
+func (b *Blockchain) addBlock(owner string, starData []byte, msg string, msgSig string) (*block.Block, error) {
+	for {
+		var prevHash [sha256.Size]byte
+		b.mutex.Lock()
+		ts := b.clock.GetTime()
+		height := len(b.chain)
+		if height > 0 {
+			prevHash = b.chain[height-1].GetHash()
+		}
+		newBlock := block.NewStar(ts, height, owner, &prevHash, starData, msg, msgSig)
+		if b.config.PoW.mined(height) {
+			difficulty := b.config.PoW.difficulty(b.chain, height)
+			b.mutex.Unlock()
+			if err := newBlock.Mine(b.mining, difficulty); err != nil {
+				if b.mining.Err() != nil {
+					return nil, MiningCancelledErr
+				}
+				return nil, err
+			}
+			b.mutex.Lock()
+			if len(b.chain) != height {
+				b.mutex.Unlock()
+				continue
+			}
+		}
+		newBlock.Sign(b.config.SigningKey)
+		err := b.appendBlock(newBlock)
+		b.mutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return newBlock, nil
+	}
+}
+
+// appendBlock method persists the block and adds it to the chain
+// and indexes. Caller must hold the write lock.
+func (b *Blockchain) appendBlock(newBlock *block.Block) error {
+	if err := b.store.Append(newBlock); err != nil {
+		return err
+	}
+	b.chain = append(b.chain, newBlock)
+	b.index(newBlock)
+	return nil
+}
+
+// index method adds the block to hash and owner indexes
+// and its hash to the accumulator.
+// Owners are indexed in the canonical spelling, so blocks stored before
+// owners were canonicalized are found too. Stars of a multisig policy are
+// indexed under the policy address and every co-owner. Genesis block has
+// no owner and is not indexed by owner. Caller must hold the write lock.
+func (b *Blockchain) index(newBlock *block.Block) {
+	b.accumulator.Append(newBlock.GetHash())
+	if _, ok := b.byHash[newBlock.GetHash()]; !ok {
+		b.byHash[newBlock.GetHash()] = newBlock
+	}
+	height := newBlock.GetHeight()
+	if height == 0 {
+		return
+	}
+	owner := b.canonicalAddress(newBlock.GetOwner())
+	b.byOwner[owner] = append(b.byOwner[owner], height)
+	if !multisig.IsPolicy(owner) {
+		return
+	}
+	if policy, err := multisig.Parse(owner); err == nil {
+		for _, coOwner := range policy.Owners {
+			b.byOwner[coOwner] = append(b.byOwner[coOwner], height)
+		}
+	}
+}
+
+// reindex method rebuilds indexes and the accumulator
+// from the chain loaded from the store
+func (b *Blockchain) reindex() {
+	b.accumulator = mmr.New()
+	b.byHash = make(map[[sha256.Size]byte]*block.Block, len(b.chain))
+	b.byOwner = make(map[string][]int)
+	for _, block := range b.chain {
+		b.index(block)
+	}
+}
+
 func (b *Blockchain) SubmitStar(req StarRequest) (*block.Block, error) {
 	if req.Addr == "" {
 		return nil, EmptyAddrErr
@@ -120,82 +770,316 @@ func (b *Blockchain) SubmitStar(req StarRequest) (*block.Block, error) {
 	if req.Sig == "" {
 		return nil, EmptySigErr
 	}
-	isOutdated, err := b.IsMessageOutdated(req.Addr, req.Msg)
+	req.Addr = b.CanonicalAddress(req.Addr)
+	m, err := b.parseMessage(req.Addr, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	isOutdated, err := b.isOutdated(m)
 	if err != nil {
 		return nil, err
 	}
 	if isOutdated {
 		return nil, WrongTSErr
 	}
-	if !VerifyMessage(req) {
-		return nil, MsgSigMistmatchErr
+	b.mutex.RLock()
+	err = b.checkChallenge(req.Addr, m, req.Msg)
+	b.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if m.Star != nil && !matchesStar(*m.Star, req.StarData) {
+		return nil, StarMismatchErr
+	}
+	if err := b.VerifyMessage(req); err != nil {
+		return nil, err
+	}
+	if err := b.consumeChallenge(req.Addr, m, req.Msg); err != nil {
+		return nil, err
 	}
-	return b.AddBlock(req.Addr, req.StarData), nil
+	return b.addBlock(req.Addr, req.StarData, req.Msg, req.Sig)
 }
 
-func VerifyMessage(req StarRequest) bool {
-	// TODO verify msg based on the signature
-	return true
+// VerifyMessage method checks whether the signature of the request was
+// created over the message by the owner of the request address.
+// It dispatches on the address shape to the registered verifier.
+// Malformed address is reported with the verifier specific error,
+// any signature failure is reported as MsgSigMistmatchErr.
+func (b *Blockchain) VerifyMessage(req StarRequest) error {
+	verifier, err := b.VerifierFor(req.Addr)
+	if err != nil {
+		return err
+	}
+	if err := verifier.ValidateAddress(req.Addr); err != nil {
+		return err
+	}
+	if err := verifier.Verify(req.Addr, req.Msg, req.Sig); err != nil {
+		return MsgSigMistmatchErr
+	}
+	return nil
 }
 
+// GetBlockByHash method looks the block up in the hash index
 func (b *Blockchain) GetBlockByHash(hash [sha256.Size]byte) (*block.Block, error) {
 	b.mutex.RLock()
 	defer b.mutex.RUnlock()
-	for _, b := range b.chain {
-		if b.GetHash() == hash {
-			return b, nil
-		}
+	if block, ok := b.byHash[hash]; ok {
+		return block, nil
 	}
 	return nil, errors.New(fmt.Sprintf("Block %x not found", hash))
 }
 
+// GetBlockByHeight method returns the block at the height,
+// which is its position in the chain
 func (b *Blockchain) GetBlockByHeight(height int) (*block.Block, error) {
 	b.mutex.RLock()
 	defer b.mutex.RUnlock()
 	if height < 0 || height >= len(b.chain) {
 		return nil, errors.New(fmt.Sprintf("Invalid height: %v", height))
 	}
-	for _, block := range b.chain {
-		if block.GetHeight() == height {
-			return block, nil
-		}
-	}
-	return nil, errors.New(fmt.Sprintf("Block at pos %v not found", height))
+	return b.chain[height], nil
 }
 
 // GetStarsByWalletAddress method should return data for stars
-// belonging to givend address
+// belonging to givend address, including stars it co-owns
+// through a multisig policy. Stars are looked up in the owner index
+// by the canonical address, so any spelling of the address can be given.
 func (b *Blockchain) GetStarsByWalletAddress(addr string) []string {
 	b.mutex.RLock()
 	defer b.mutex.RUnlock()
-	stars := make([]string, 0)
-	// Ommit genesis block - it has no owner
-	for _, block := range b.chain[1:] {
-		if block.GetOwner() == addr {
-			stars = append(stars, string(block.DecodeData()))
-		}
+	heights := b.byOwner[b.canonicalAddress(addr)]
+	stars := make([]string, 0, len(heights))
+	for _, height := range heights {
+		stars = append(stars, string(b.chain[height].DecodeData()))
 	}
 	return stars
 }
 
+// verifyProducer fn checks whether the block was signed
+// by one of the trusted producer keys
+func verifyProducer(trustedKeys map[string]ed25519.PublicKey, block *block.Block) error {
+	hash := block.GetHash()
+	if block.GetSignature() == nil {
+		return errors.New(fmt.Sprintf("Block %x is not signed", hash))
+	}
+	pubKey, ok := trustedKeys[block.GetKeyID()]
+	if !ok {
+		return errors.New(fmt.Sprintf("Block %x is signed by untrusted key %s", hash, block.GetKeyID()))
+	}
+	if !block.VerifySignature(pubKey) {
+		return errors.New(fmt.Sprintf("Block %x signature is invalid", hash))
+	}
+	return nil
+}
+
+// verifyProof method checks the ownership proof stored in the star block:
+// the message has to be a challenge of the owner, bound to the star when
+// it carries coordinates, and signed by the owner.
+func (b *Blockchain) verifyProof(block *block.Block) error {
+	hash := block.GetHash()
+	if block.GetMessage() == "" || block.GetMessageSignature() == "" {
+		return errors.New(fmt.Sprintf("Block %x has no ownership proof", hash))
+	}
+	m, err := ParseChallenge(block.GetMessage(), true)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Block %x message is invalid: %s", hash, err))
+	}
+	if m.Address != block.GetOwner() {
+		return errors.New(fmt.Sprintf("Block %x message was issued to %s", hash, m.Address))
+	}
+	if m.Star != nil && !matchesStar(*m.Star, block.DecodeData()) {
+		return errors.New(fmt.Sprintf("Block %x: %s", hash, StarMismatchErr))
+	}
+	req := StarRequest{Addr: block.GetOwner(), Msg: block.GetMessage(), Sig: block.GetMessageSignature()}
+	if err := b.VerifyMessage(req); err != nil {
+		return errors.New(fmt.Sprintf("Block %x ownership proof is invalid: %s", hash, err))
+	}
+	return nil
+}
+
+// ValidationOptions struct selects optional ValidateChain checks
+type ValidationOptions struct {
+	// VerifyProofs re-verifies the ownership proof of every star block
+	VerifyProofs bool
+	// Full validates every block, ignoring checkpoints
+	Full bool
+	// Workers is the number of goroutines checking blocks, NumCPU when 0
+	Workers int
+	// Progress is called with the number of checked blocks and the number
+	// of blocks to check as validation advances, calls are serialized.
+	// The first call reports 0 checked blocks before any chunk is checked.
+	Progress func(checked int, total int)
+}
+
+// validationChunkSize is the number of blocks a worker checks at once
+const validationChunkSize = 256
+
+// ValidateChain method checks hashes, links, heights, timestamps and
+// producer signatures of blocks after the last checkpoint, returned
+// errors are *Finding, see ValidateChainWith
 func (b *Blockchain) ValidateChain() []error {
+	return b.ValidateChainWith(ValidationOptions{})
+}
+
+// ValidateChainWith method validates the chain with optional checks,
+// see ValidateChainContext
+func (b *Blockchain) ValidateChainWith(opts ValidationOptions) []error {
+	validationErrs, _ := b.ValidateChainContext(context.Background(), opts)
+	return validationErrs
+}
+
+// ValidateChainContext method validates the chain with optional checks.
+// Blocks are immutable, so it works on a snapshot of the chain, split
+// into chunks checked by a pool of opts.Workers goroutines.
+// Only blocks after the last checkpoint are validated unless opts.Full
+// is set, the head is recorded as a new checkpoint when no errors are found.
+// When ctx is done, validation stops and errors found so far are
+// returned together with ctx error, no checkpoint is recorded then.
+func (b *Blockchain) ValidateChainContext(ctx context.Context, opts ValidationOptions) ([]error, error) {
 	b.mutex.RLock()
-	defer b.mutex.RUnlock()
-	validationErrs := []error{}
-	for i, block := range b.chain {
-		hash := block.GetHash()
-		if !block.Validate() {
-			msg := fmt.Sprintf("Block %x is invalid!", hash)
-			validationErrs = append(validationErrs, errors.New(msg))
-		}
-		if i > 0 {
-			prevBlock := b.chain[i-1]
-			prevBlockHash := prevBlock.CalculateHash()
-			blockPrevHash := block.GetPrevHash()
-			if prevBlockHash != blockPrevHash {
-				msg := fmt.Sprintf("Block %x prevHash %x does not match calculated hash %x", hash, blockPrevHash, prevBlockHash)
-				validationErrs = append(validationErrs, errors.New(msg))
+	chain := b.chain
+	b.mutex.RUnlock()
+	start := b.startHeight(chain, opts)
+	now := b.clock.GetTime()
+	total := len(chain) - start
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	var (
+		results       = make([][]error, total)
+		chunks        = make(chan int)
+		wg            sync.WaitGroup
+		progressMutex sync.Mutex
+		checked       int
+		ctxErr        error
+	)
+	if opts.Progress != nil {
+		opts.Progress(0, total)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for from := range chunks {
+				to := from + validationChunkSize
+				if to > len(chain) {
+					to = len(chain)
+				}
+				for i := from; i < to; i++ {
+					results[i-start] = b.validateBlock(chain, i, now, opts)
+				}
+				if opts.Progress != nil {
+					progressMutex.Lock()
+					checked += to - from
+					opts.Progress(checked, total)
+					progressMutex.Unlock()
+				}
 			}
+		}()
+	}
+dispatch:
+	for from := start; from < len(chain); from += validationChunkSize {
+		select {
+		case chunks <- from:
+		case <-ctx.Done():
+			ctxErr = ctx.Err()
+			break dispatch
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	validationErrs := []error{}
+	for _, errs := range results {
+		validationErrs = append(validationErrs, errs...)
+	}
+	if ctxErr != nil {
+		return validationErrs, ctxErr
+	}
+	if len(validationErrs) == 0 && len(chain) > 0 {
+		b.recordCheckpoint(chain, opts)
+	}
+	return validationErrs, nil
+}
+
+// validateBlock method checks the block at height i of the chain,
+// now is the node time. Every problem is reported as separate *Finding.
+func (b *Blockchain) validateBlock(chain []*block.Block, i int, now int64, opts ValidationOptions) []error {
+	var validationErrs []error
+	current := chain[i]
+	hash := current.GetHash()
+	if finding := checkHash(current, i); finding != nil {
+		validationErrs = append(validationErrs, finding)
+	}
+	if current.GetHeight() != i {
+		validationErrs = append(validationErrs, &Finding{
+			Kind: FindingHeightGap, Height: i, Expected: strconv.Itoa(i), Actual: strconv.Itoa(current.GetHeight()),
+			Detail: "block height does not match its position in the chain",
+		})
+	}
+	if maxTime := now + b.config.MaxClockDrift; current.GetTimestamp() > maxTime {
+		validationErrs = append(validationErrs, &Finding{
+			Kind: FindingClockDrift, Height: i, Expected: fmt.Sprintf("<= %d", maxTime), Actual: strconv.FormatInt(current.GetTimestamp(), 10),
+			Detail: "block timestamp is too far ahead of the node clock",
+		})
+	}
+	if i == 0 {
+		if current.GetOwner() != "" {
+			validationErrs = append(validationErrs, &Finding{
+				Kind: FindingGenesisOwner, Height: i, Actual: current.GetOwner(),
+				Detail: "genesis block must not have an owner",
+			})
+		}
+		if prevHash := current.GetPrevHash(); prevHash != [sha256.Size]byte{} {
+			validationErrs = append(validationErrs, &Finding{
+				Kind: FindingGenesisPrevHash, Height: i, Expected: fmt.Sprintf("%x", [sha256.Size]byte{}), Actual: fmt.Sprintf("%x", prevHash),
+				Detail: "genesis block must not have a previous block",
+			})
+		}
+		if genesis := b.config.Genesis; genesis != nil && hash != genesis.Block().GetHash() {
+			validationErrs = append(validationErrs, &Finding{
+				Kind: FindingBadGenesis, Height: i, Expected: fmt.Sprintf("%x", genesis.Block().GetHash()), Actual: fmt.Sprintf("%x", hash),
+				Detail: "genesis block does not match the configured genesis",
+			})
+		}
+	} else {
+		prevBlock := chain[i-1]
+		if finding := checkPrevHash(prevBlock, current, i); finding != nil {
+			validationErrs = append(validationErrs, finding)
+		}
+		if current.GetOwner() == "" {
+			validationErrs = append(validationErrs, &Finding{
+				Kind: FindingEmptyOwner, Height: i,
+				Detail: "star block has no owner",
+			})
+		}
+		if current.GetTimestamp() < prevBlock.GetTimestamp() {
+			validationErrs = append(validationErrs, &Finding{
+				Kind: FindingTimestampRegression, Height: i, Expected: fmt.Sprintf(">= %d", prevBlock.GetTimestamp()), Actual: strconv.FormatInt(current.GetTimestamp(), 10),
+				Detail: "block is older than the previous block",
+			})
+		}
+	}
+	if b.config.PoW.mined(i) {
+		if expected := b.config.PoW.difficulty(chain, i); current.GetDifficulty() != expected {
+			validationErrs = append(validationErrs, &Finding{
+				Kind: FindingBadDifficulty, Height: i, Expected: strconv.FormatUint(expected, 10), Actual: strconv.FormatUint(current.GetDifficulty(), 10),
+				Detail: "block is not mined with the difficulty of its height",
+			})
+		}
+		if !current.MeetsDifficulty() {
+			validationErrs = append(validationErrs, &Finding{
+				Kind: FindingInsufficientWork, Height: i, Expected: fmt.Sprintf("<= %064x", block.Target(current.GetDifficulty())), Actual: fmt.Sprintf("%x", hash),
+				Detail: "block hash does not meet its difficulty",
+			})
+		}
+	}
+	if err := verifyProducer(b.trustedKeys, current); err != nil {
+		validationErrs = append(validationErrs, newFinding(FindingBadProducer, i, err))
+	}
+	if opts.VerifyProofs && i > 0 {
+		if err := b.verifyProof(current); err != nil {
+			validationErrs = append(validationErrs, newFinding(FindingBadProof, i, err))
 		}
 	}
 	return validationErrs
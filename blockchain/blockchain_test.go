@@ -1,13 +1,67 @@
 package blockchain
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/starchain/bitcoin"
 	"github.com/starchain/block"
+	"github.com/starchain/didkey"
+	"github.com/starchain/ethereum"
+	"github.com/starchain/mmr"
+	"github.com/starchain/multisig"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
 
+var (
+	testKey, _  = btcec.PrivKeyFromBytes([]byte("starchain test key #1 - 32 bytes"))
+	otherKey, _ = btcec.PrivKeyFromBytes([]byte("starchain test key #2 - 32 bytes"))
+	testAddr    = bitcoin.P2PKHAddress(testKey.PubKey(), true)
+	otherAddr   = bitcoin.P2PKHAddress(otherKey.PubKey(), true)
+)
+
+func sign(key *btcec.PrivateKey, msg string) string {
+	return bitcoin.SignMessage(key, msg, true)
+}
+
+// challengeMsg fn returns structured challenge of the address issued at ts
+// with default domain, chain ID and TTL. The nonce is derived from both.
+func challengeMsg(addr string, ts int64) string {
+	nonce := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", addr, ts)))
+	return ChallengeMessage{
+		Domain:         DefaultDomain,
+		Address:        addr,
+		ChainID:        DefaultChainID,
+		Nonce:          hex.EncodeToString(nonce[:NonceSize]),
+		IssuedAt:       ts,
+		ExpirationTime: ts + FIVE_MIN,
+	}.String()
+}
+
+// issue fn registers the message of the request as if it was issued
+// by RequestMessageOwnershipVerification
+func issue(b *Blockchain, req StarRequest) {
+	m, err := ParseChallenge(req.Msg, true)
+	if err != nil {
+		log.Panic(err)
+	}
+	b.addChallenge(m.Nonce, &issuedChallenge{addr: req.Addr, msg: req.Msg, issuedAt: b.clock.GetTime()})
+}
+
 type BlockchainClockMock struct{}
 
 func (b BlockchainClockMock) GetTime() int64 {
@@ -20,7 +74,7 @@ func TestNew(t *testing.T) {
 		t.Log("\tWhen called")
 		{
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
 			if blockchain == nil {
 				t.Fatalf("\t\tShould return new Blockchain, got:\nnil")
 			}
@@ -47,7 +101,7 @@ func TestGetChainHeight(t *testing.T) {
 		t.Log("\tGiven fresh blockchain")
 		{
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
 			height := blockchain.GetChainHeight()
 			if height != 1 {
 				t.Fatalf("\t\tShould return 1, got: %v", height)
@@ -63,29 +117,46 @@ func TestRequestMessageOwnershipVerification(t *testing.T) {
 		t.Log("\tGiven correct wallet address")
 		{
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
-			var addr = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
-			msg, err := blockchain.RequestMessageOwnershipVerification(addr)
+			blockchain := New(clock, DefaultConfig())
+			blockchain.random = bytes.NewReader(bytes.Repeat([]byte{0xab}, NonceSize))
+			var addr = testAddr
+			challenge, err := blockchain.RequestMessageOwnershipVerification(addr, nil)
 			if err != nil {
 				t.Fatal("\t\tShould return nil error, got: ", err)
 			}
-			expected := fmt.Sprintf("%s:%d:starRegistry", addr, 1592156792)
-			if expected != msg {
-				t.Fatal("\t\tShould return correct message, got: ", msg)
+			expected := "starchain.local wants you to prove ownership of the address:\n" +
+				addr + "\n" +
+				"\n" +
+				"Register a star in the Starchain registry.\n" +
+				"\n" +
+				"Version: 1\n" +
+				"Chain ID: starchain-1\n" +
+				"Nonce: " + strings.Repeat("ab", NonceSize) + "\n" +
+				"Issued At: 2020-06-14T17:46:32Z\n" +
+				"Expiration Time: 2020-06-14T17:51:32Z"
+			if expected != challenge.Message {
+				t.Fatal("\t\tShould return correct message, got: ", challenge.Message)
 			}
 			t.Log("\t\tShould return correct message")
+			if challenge.IssuedAt != 1592156792 ||
+				challenge.ExpiresAt != 1592156792+FIVE_MIN ||
+				challenge.ValidFor != FIVE_MIN ||
+				challenge.MaxClockSkew != DefaultMaxClockSkew {
+				t.Fatal("\t\tShould return effective time range, got: ", challenge)
+			}
+			t.Log("\t\tShould return effective time range")
 		}
 		t.Log("\tGiven empty wallet address")
 		{
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
 			var addr = ""
-			msg, err := blockchain.RequestMessageOwnershipVerification(addr)
+			challenge, err := blockchain.RequestMessageOwnershipVerification(addr, nil)
 			if err != EmptyAddrErr {
 				t.Fatal("\t\tShould return error, got: ", err)
 			}
-			if "" != msg {
-				t.Fatal("\t\tShould return empty message, got: ", msg)
+			if "" != challenge.Message {
+				t.Fatal("\t\tShould return empty message, got: ", challenge.Message)
 			}
 			t.Log("\t\tShould return empty error")
 		}
@@ -96,16 +167,17 @@ func TestSubmitStar(t *testing.T) {
 	t.Log("SubmitStar")
 	{
 		var (
-			addr = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
-			msg  = fmt.Sprintf("%s:%d:starRegistry", addr, 1592156792-3*60)
+			addr = testAddr
+			msg  = challengeMsg(addr, 1592156792-3*60)
 			star = []byte("My star")
-			sig  = "sig"
+			sig  = sign(testKey, msg)
 			req  = StarRequest{addr, msg, star, sig}
 		)
 		t.Log("\tGiven correct params")
 		{
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
+			issue(blockchain, req)
 			block, err := blockchain.SubmitStar(req)
 			if err != nil {
 				t.Fatal("\t\tShould return block without errors, got err: ", err)
@@ -135,18 +207,308 @@ func TestSubmitStar(t *testing.T) {
 			}
 			t.Log("\t\tShould return correct block and add it to the blockchain")
 		}
+		t.Log("\tGiven signature created by a different key")
+		{
+			clock := BlockchainClockMock{}
+			blockchain := New(clock, DefaultConfig())
+			issue(blockchain, req)
+			badReq := StarRequest{addr, msg, star, sign(otherKey, msg)}
+			block, err := blockchain.SubmitStar(badReq)
+			if err != MsgSigMistmatchErr {
+				t.Fatal("\t\tShould return MsgSigMistmatchErr, got: ", err)
+			}
+			if block != nil {
+				t.Fatal("\t\tShould not return block, got: ", block)
+			}
+			if bHeight := len(blockchain.chain); bHeight != 1 {
+				t.Fatal("\t\tShould not add block to the chain, got length: ", bHeight)
+			}
+			t.Log("\t\tShould reject the star")
+		}
+	}
+}
+
+// MutableClockMock allows tests to move the time forward
+type MutableClockMock struct {
+	ts int64
+}
+
+func (c *MutableClockMock) GetTime() int64 {
+	return c.ts
+}
+
+func TestChallenges(t *testing.T) {
+	t.Log("Challenges")
+	{
+		star := []byte("My star")
+		t.Log("\tGiven a challenge issued by the blockchain")
+		{
+			clock := &MutableClockMock{BlockchainClockMock{}.GetTime()}
+			blockchain := New(clock, DefaultConfig())
+			challenge, _ := blockchain.RequestMessageOwnershipVerification(testAddr, nil)
+			msg := challenge.Message
+			req := StarRequest{testAddr, msg, star, sign(testKey, msg)}
+			t.Log("\t\tWhen signature is wrong")
+			{
+				badReq := StarRequest{testAddr, msg, star, sign(otherKey, msg)}
+				if _, err := blockchain.SubmitStar(badReq); err != MsgSigMistmatchErr {
+					t.Fatal("\t\t\tShould return MsgSigMistmatchErr, got: ", err)
+				}
+				t.Log("\t\t\tShould reject the star without consuming the challenge")
+			}
+			t.Log("\t\tWhen submitted for the first time")
+			{
+				if _, err := blockchain.SubmitStar(req); err != nil {
+					t.Fatal("\t\t\tShould accept the star, got err: ", err)
+				}
+				t.Log("\t\t\tShould accept the star")
+			}
+			t.Log("\t\tWhen submitted again")
+			{
+				if _, err := blockchain.SubmitStar(req); err != UsedChallengeErr {
+					t.Fatal("\t\t\tShould return UsedChallengeErr, got: ", err)
+				}
+				if height := blockchain.GetChainHeight(); height != 2 {
+					t.Fatal("\t\t\tShould add only one block, got height: ", height)
+				}
+				t.Log("\t\t\tShould reject the replayed star")
+			}
+			t.Log("\t\tWhen challenge expires")
+			{
+				clock.ts += FIVE_MIN
+				blockchain.RequestMessageOwnershipVerification(otherAddr, nil)
+				m, _ := ParseChallenge(msg, false)
+				if _, ok := blockchain.challenges[m.Nonce]; ok {
+					t.Fatal("\t\t\tShould remove expired challenge")
+				}
+				if _, err := blockchain.SubmitStar(req); err != WrongTSErr {
+					t.Fatal("\t\t\tShould return WrongTSErr, got: ", err)
+				}
+				t.Log("\t\t\tShould remove expired challenge")
+			}
+		}
+		t.Log("\tGiven the limit of outstanding challenges")
+		{
+			clock := &MutableClockMock{BlockchainClockMock{}.GetTime()}
+			config := DefaultConfig()
+			config.MaxChallenges = 2
+			blockchain := New(clock, config)
+			blockchain.RequestMessageOwnershipVerification(testAddr, nil)
+			clock.ts += 60
+			blockchain.RequestMessageOwnershipVerification(testAddr, nil)
+			if _, err := blockchain.RequestMessageOwnershipVerification(otherAddr, nil); err != TooManyChallengesErr {
+				t.Fatal("\t\tShould return TooManyChallengesErr, got: ", err)
+			}
+			t.Log("\t\tShould return TooManyChallengesErr")
+			clock.ts += FIVE_MIN - 60
+			if _, err := blockchain.RequestMessageOwnershipVerification(otherAddr, nil); err != nil {
+				t.Fatal("\t\tShould issue challenge once the oldest expires, got err: ", err)
+			}
+			if len(blockchain.challenges) != 2 || len(blockchain.challengeQueue) != 2 {
+				t.Fatal("\t\tShould prune only the expired challenge, got: ", len(blockchain.challenges), len(blockchain.challengeQueue))
+			}
+			t.Log("\t\tShould issue challenge once the oldest expires")
+		}
+		t.Log("\tGiven the limit of outstanding challenges of an address")
+		{
+			clock := &MutableClockMock{BlockchainClockMock{}.GetTime()}
+			config := DefaultConfig()
+			config.MaxAddressChallenges = 2
+			blockchain := New(clock, config)
+			first, _ := blockchain.RequestMessageOwnershipVerification(testAddr, nil)
+			clock.ts += 60
+			blockchain.RequestMessageOwnershipVerification(testAddr, nil)
+			if _, err := blockchain.RequestMessageOwnershipVerification(testAddr, nil); err != TooManyAddressChallengesErr {
+				t.Fatal("\t\tShould return TooManyAddressChallengesErr, got: ", err)
+			}
+			t.Log("\t\tShould return TooManyAddressChallengesErr")
+			if _, err := blockchain.RequestMessageOwnershipVerification(otherAddr, nil); err != nil {
+				t.Fatal("\t\tShould issue challenge to other address, got err: ", err)
+			}
+			t.Log("\t\tShould issue challenge to other address")
+			if _, err := blockchain.SubmitStar(StarRequest{testAddr, first.Message, star, sign(testKey, first.Message)}); err != nil {
+				t.Fatal("\t\tShould accept the star, got err: ", err)
+			}
+			if _, err := blockchain.RequestMessageOwnershipVerification(testAddr, nil); err != nil {
+				t.Fatal("\t\tShould issue challenge once one is used, got err: ", err)
+			}
+			t.Log("\t\tShould issue challenge once one is used")
+			if count := blockchain.addrChallenges[testAddr]; count != 2 {
+				t.Fatal("\t\tShould count only unused challenges, got: ", count)
+			}
+			t.Log("\t\tShould count only unused challenges")
+			clock.ts += FIVE_MIN
+			blockchain.RequestMessageOwnershipVerification(otherAddr, nil)
+			if count := blockchain.addrChallenges[testAddr]; count != 0 {
+				t.Fatal("\t\tShould stop counting expired challenges, got: ", count)
+			}
+			t.Log("\t\tShould stop counting expired challenges")
+		}
+		t.Log("\tGiven a message which was not issued by the blockchain")
+		{
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			msg := challengeMsg(testAddr, 1592156792-60)
+			req := StarRequest{testAddr, msg, star, sign(testKey, msg)}
+			if _, err := blockchain.SubmitStar(req); err != UnknownChallengeErr {
+				t.Fatal("\t\tShould return UnknownChallengeErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the star")
+		}
+		t.Log("\tGiven a challenge issued to other address")
+		{
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			challenge, _ := blockchain.RequestMessageOwnershipVerification(otherAddr, nil)
+			msg := challenge.Message
+			// Signed by the right key, but the message belongs to otherAddr
+			req := StarRequest{testAddr, msg, star, sign(testKey, msg)}
+			if _, err := blockchain.SubmitStar(req); err == nil {
+				t.Fatal("\t\tShould reject the star, got nil error")
+			}
+			t.Log("\t\tShould reject the star")
+		}
+	}
+}
+
+func TestSubmitStarAddressTypes(t *testing.T) {
+	t.Log("SubmitStar with SegWit and Taproot addresses")
+	{
+		star := []byte("My star")
+		ts := int64(1592156792 - 60)
+		p2wpkh := bitcoin.P2WPKHAddress(testKey.PubKey())
+		p2tr := bitcoin.P2TRAddress(testKey.PubKey())
+		t.Log("\tGiven P2WPKH address and BIP137 signature")
+		{
+			msg := challengeMsg(p2wpkh, ts)
+			sig, _ := bitcoin.SignMessageBIP137(testKey, msg, bitcoin.P2WPKH)
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			issue(blockchain, StarRequest{Addr: p2wpkh, Msg: msg})
+			if _, err := blockchain.SubmitStar(StarRequest{p2wpkh, msg, star, sig}); err != nil {
+				t.Fatal("\t\tShould accept the star, got err: ", err)
+			}
+			t.Log("\t\tShould accept the star")
+		}
+		t.Log("\tGiven P2TR address and BIP322 signature")
+		{
+			msg := challengeMsg(p2tr, ts)
+			sig, _ := bitcoin.SignMessageBIP322(testKey, msg)
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			issue(blockchain, StarRequest{Addr: p2tr, Msg: msg})
+			if _, err := blockchain.SubmitStar(StarRequest{p2tr, msg, star, sig}); err != nil {
+				t.Fatal("\t\tShould accept the star, got err: ", err)
+			}
+			t.Log("\t\tShould accept the star")
+		}
+		t.Log("\tGiven address with broken checksum")
+		{
+			addr := p2wpkh[:len(p2wpkh)-1] + "x"
+			if addr == p2wpkh {
+				addr = p2wpkh[:len(p2wpkh)-1] + "y"
+			}
+			msg := challengeMsg(addr, ts)
+			sig, _ := bitcoin.SignMessageBIP137(testKey, msg, bitcoin.P2WPKH)
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			issue(blockchain, StarRequest{Addr: addr, Msg: msg})
+			if _, err := blockchain.SubmitStar(StarRequest{addr, msg, star, sig}); err != bitcoin.InvalidAddrChecksumErr {
+				t.Fatal("\t\tShould return InvalidAddrChecksumErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the star")
+		}
+	}
+}
+
+// prefixVerifier accepts every signature equal to "ok" for addresses
+// starting with its prefix
+type prefixVerifier struct {
+	prefix string
+}
+
+func (v prefixVerifier) Scheme() string {
+	return "test:" + v.prefix
+}
+
+func (v prefixVerifier) Supports(addr string) bool {
+	return strings.HasPrefix(addr, v.prefix)
+}
+
+func (v prefixVerifier) ValidateAddress(addr string) error {
+	return nil
+}
+
+func (v prefixVerifier) Verify(addr string, msg string, sig string) error {
+	if sig != "ok" {
+		return errors.New("Not ok")
+	}
+	return nil
+}
+
+func TestVerifyMessage(t *testing.T) {
+	t.Log("VerifyMessage")
+	{
+		msg := "Sign me"
+		edKey := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+		did := didkey.EncodeDID(edKey.Public().(ed25519.PublicKey))
+		ethAddr := ethereum.PubKeyToAddress(testKey.PubKey())
+		cases := []struct {
+			scheme string
+			req    StarRequest
+		}{
+			{bitcoin.Scheme, StarRequest{testAddr, msg, nil, sign(testKey, msg)}},
+			{ethereum.Scheme, StarRequest{ethAddr, msg, nil, ethereum.SignMessage(testKey, msg)}},
+			{didkey.Scheme, StarRequest{did, msg, nil, didkey.SignMessage(edKey, msg)}},
+		}
+		blockchain := New(BlockchainClockMock{}, DefaultConfig())
+		for _, c := range cases {
+			t.Log("\tGiven", c.scheme, "address", c.req.Addr)
+			{
+				verifier, err := blockchain.VerifierFor(c.req.Addr)
+				if err != nil || verifier.Scheme() != c.scheme {
+					t.Fatal("\t\tShould dispatch to the scheme verifier, got: ", verifier, err)
+				}
+				t.Log("\t\tShould dispatch to the scheme verifier")
+				if err := blockchain.VerifyMessage(c.req); err != nil {
+					t.Fatal("\t\tShould accept the signature, got err: ", err)
+				}
+				t.Log("\t\tShould accept the signature")
+				c.req.Msg += "!"
+				if err := blockchain.VerifyMessage(c.req); err != MsgSigMistmatchErr {
+					t.Fatal("\t\tShould return MsgSigMistmatchErr for other message, got: ", err)
+				}
+				t.Log("\t\tShould reject signature of other message")
+			}
+		}
+		t.Log("\tGiven address of unknown scheme")
+		{
+			req := StarRequest{"xyz:abc", msg, nil, "ok"}
+			if err := blockchain.VerifyMessage(req); err != UnknownSchemeErr {
+				t.Fatal("\t\tShould return UnknownSchemeErr, got: ", err)
+			}
+			t.Log("\t\tShould return UnknownSchemeErr")
+			blockchain.RegisterVerifier(prefixVerifier{"xyz:"})
+			if err := blockchain.VerifyMessage(req); err != nil {
+				t.Fatal("\t\tShould accept the signature once verifier is registered, got err: ", err)
+			}
+			t.Log("\t\tShould accept the signature once verifier is registered")
+		}
+		t.Log("\tGiven malformed Ethereum address")
+		{
+			req := StarRequest{"0x1234", msg, nil, "0x00"}
+			if err := blockchain.VerifyMessage(req); err != ethereum.InvalidAddrErr {
+				t.Fatal("\t\tShould return verifier specific error, got: ", err)
+			}
+			t.Log("\t\tShould return verifier specific error")
+		}
 	}
 }
 
 func TestIsMessageOutdated(t *testing.T) {
 	t.Log("IsMessageOutdated")
 	{
-		var addr = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
+		var addr = testAddr
 		t.Log("\tGiven correct message")
 		{
-			msg := fmt.Sprintf("%s:%d:starRegistry", addr, 1592156792-3*60)
+			msg := challengeMsg(addr, 1592156792-3*60)
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
 			isOutdated, err := blockchain.IsMessageOutdated(addr, msg)
 			if err != nil {
 				t.Fatal("\t\tShould return false and nil err, got err: ", err)
@@ -158,9 +520,9 @@ func TestIsMessageOutdated(t *testing.T) {
 		}
 		t.Log("\tGiven outdated message")
 		{
-			msg := fmt.Sprintf("%s:%d:starRegistry", addr, 1592156792-5*60)
+			msg := challengeMsg(addr, 1592156792-5*60)
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
 			isOutdated, err := blockchain.IsMessageOutdated(addr, msg)
 			if !isOutdated {
 				t.Fatal("\t\tShould return true, got", isOutdated)
@@ -173,15 +535,48 @@ func TestIsMessageOutdated(t *testing.T) {
 		t.Log("\tGiven malformed message")
 		{
 			// Message from the future
-			msg := fmt.Sprintf("%s:%d:starRegistry", addr, 1592156792+5*60)
+			msg := challengeMsg(addr, 1592156792+5*60)
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
 			_, err := blockchain.IsMessageOutdated(addr, msg)
 			if err == nil {
 				t.Fatal("\t\tShould return err, got nil")
 			}
 			t.Log("\t\tShould return err: ", err)
 		}
+		t.Log("\tGiven legacy message, custom challenge TTL and clock skew")
+		{
+			config := Config{ChallengeTTL: 60, MaxClockSkew: 10, LegacyChallenges: true}
+			blockchain := New(BlockchainClockMock{}, config)
+			cases := []struct {
+				offset     int64
+				isOutdated bool
+				err        error
+			}{
+				{-30, false, nil},
+				{-60, true, nil},
+				{5, false, nil},
+				{10, false, nil},
+				{11, true, WrongTSErr},
+			}
+			for _, c := range cases {
+				msg := fmt.Sprintf("%s:%d:starRegistry", addr, 1592156792+c.offset)
+				isOutdated, err := blockchain.IsMessageOutdated(addr, msg)
+				if isOutdated != c.isOutdated || err != c.err {
+					t.Fatalf("\t\tShould return %v, %v for offset %d, got: %v, %v",
+						c.isOutdated, c.err, c.offset, isOutdated, err)
+				}
+			}
+			t.Log("\t\tShould respect configured time range")
+		}
+		t.Log("\tGiven zero config")
+		{
+			blockchain := New(BlockchainClockMock{}, Config{})
+			if blockchain.GetConfig().ChallengeTTL != FIVE_MIN {
+				t.Fatal("\t\tShould use default TTL, got: ", blockchain.GetConfig())
+			}
+			t.Log("\t\tShould use default TTL")
+		}
 	}
 }
 
@@ -192,7 +587,7 @@ func TestGetBlockByHash(t *testing.T) {
 		t.Log("\tGiven empty hash")
 		{
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
 			block, err := blockchain.GetBlockByHash(hash)
 
 			if block != nil {
@@ -208,7 +603,7 @@ func TestGetBlockByHash(t *testing.T) {
 	t.Log("\tGiven genesis block hash")
 	{
 		clock := BlockchainClockMock{}
-		blockchain := New(clock)
+		blockchain := New(clock, DefaultConfig())
 		hash := blockchain.chain[0].GetHash()
 		block, err := blockchain.GetBlockByHash(hash)
 
@@ -224,14 +619,15 @@ func TestGetBlockByHash(t *testing.T) {
 	t.Log("\tGiven new block hash")
 	{
 		var (
-			addr = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
-			msg  = fmt.Sprintf("%s:%d:starRegistry", addr, 1592156792-3*60)
+			addr = testAddr
+			msg  = challengeMsg(addr, 1592156792-3*60)
 			star = []byte("Brand new Star")
-			sig  = "sig"
+			sig  = sign(testKey, msg)
 			req  = StarRequest{addr, msg, star, sig}
 		)
 		clock := BlockchainClockMock{}
-		blockchain := New(clock)
+		blockchain := New(clock, DefaultConfig())
+		issue(blockchain, req)
 		blockchain.SubmitStar(req)
 		hash := blockchain.chain[1].GetHash()
 		block, err := blockchain.GetBlockByHash(hash)
@@ -253,7 +649,7 @@ func TestGetBlockByHeight(t *testing.T) {
 		t.Log("\tGiven height -1")
 		{
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
 			block, err := blockchain.GetBlockByHeight(-1)
 
 			if block != nil {
@@ -268,7 +664,7 @@ func TestGetBlockByHeight(t *testing.T) {
 		t.Log("\tGiven height bigger than chain len")
 		{
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
 			block, err := blockchain.GetBlockByHeight(1)
 
 			if block != nil {
@@ -284,7 +680,7 @@ func TestGetBlockByHeight(t *testing.T) {
 	t.Log("\tGiven height 0")
 	{
 		clock := BlockchainClockMock{}
-		blockchain := New(clock)
+		blockchain := New(clock, DefaultConfig())
 		block, err := blockchain.GetBlockByHeight(0)
 
 		if block == nil {
@@ -299,15 +695,16 @@ func TestGetBlockByHeight(t *testing.T) {
 	t.Log("\tGiven new block height")
 	{
 		var (
-			addr = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
-			msg  = fmt.Sprintf("%s:%d:starRegistry", addr, 1592156792-3*60)
+			addr = testAddr
+			msg  = challengeMsg(addr, 1592156792-3*60)
 			star = []byte("Brand new Star")
-			sig  = "sig"
+			sig  = sign(testKey, msg)
 			req  = StarRequest{addr, msg, star, sig}
 		)
 		height := 1
 		clock := BlockchainClockMock{}
-		blockchain := New(clock)
+		blockchain := New(clock, DefaultConfig())
+		issue(blockchain, req)
 		blockchain.SubmitStar(req)
 		block, err := blockchain.GetBlockByHeight(height)
 
@@ -328,7 +725,7 @@ func TestGetStarsByWalletAddress(t *testing.T) {
 		t.Log("\tGiven empty address")
 		{
 			clock := BlockchainClockMock{}
-			blockchain := New(clock)
+			blockchain := New(clock, DefaultConfig())
 			blocks := blockchain.GetStarsByWalletAddress("")
 
 			if len(blocks) != 0 {
@@ -341,14 +738,15 @@ func TestGetStarsByWalletAddress(t *testing.T) {
 			t.Log("\tWhen proper address passed as param")
 			{
 				var (
-					addr = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
-					msg  = fmt.Sprintf("%s:%d:starRegistry", addr, 1592156792-3*60)
+					addr = testAddr
+					msg  = challengeMsg(addr, 1592156792-3*60)
 					star = []byte("Brand new Star")
-					sig  = "sig"
+					sig  = sign(testKey, msg)
 					req  = StarRequest{addr, msg, star, sig}
 				)
 				clock := BlockchainClockMock{}
-				blockchain := New(clock)
+				blockchain := New(clock, DefaultConfig())
+				issue(blockchain, req)
 				blockchain.SubmitStar(req)
 				stars := blockchain.GetStarsByWalletAddress(addr)
 				if len(stars) != 1 {
@@ -366,18 +764,19 @@ func TestGetStarsByWalletAddress(t *testing.T) {
 			t.Log("\tWhen second owner's address is passed")
 			{
 				var (
-					addr1 = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
-					addr2 = "1FzpnkhbAteDkU2wXDtd8kKizQhqWcsrWx"
-					msg1  = fmt.Sprintf("%s:%d:starRegistry", addr1, 1592156792-3*60)
-					msg2  = fmt.Sprintf("%s:%d:starRegistry", addr2, 1592156792-2*60)
+					addr1 = testAddr
+					addr2 = otherAddr
+					msg1  = challengeMsg(addr1, 1592156792-3*60)
+					msg2  = challengeMsg(addr2, 1592156792-2*60)
 					star1 = []byte("Brand new Star 1")
 					star2 = []byte("Brand new Star 2")
-					sig   = "sig"
-					req1  = StarRequest{addr1, msg1, star1, sig}
-					req2  = StarRequest{addr2, msg2, star2, sig}
+					req1  = StarRequest{addr1, msg1, star1, sign(testKey, msg1)}
+					req2  = StarRequest{addr2, msg2, star2, sign(otherKey, msg2)}
 				)
 				clock := BlockchainClockMock{}
-				blockchain := New(clock)
+				blockchain := New(clock, DefaultConfig())
+				issue(blockchain, req1)
+				issue(blockchain, req2)
 				blockchain.SubmitStar(req1)
 				blockchain.SubmitStar(req2)
 				stars := blockchain.GetStarsByWalletAddress(addr2)
@@ -391,6 +790,62 @@ func TestGetStarsByWalletAddress(t *testing.T) {
 				t.Log("\t\tShould return proper blocks")
 			}
 		}
+		t.Log("\tGiven Ethereum address spelled in lower case")
+		{
+			ethAddr := ethereum.PubKeyToAddress(testKey.PubKey())
+			lower := strings.ToLower(ethAddr)
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			challenge, err := blockchain.RequestMessageOwnershipVerification(lower, nil)
+			if err != nil || challenge.Address != ethAddr || !strings.Contains(challenge.Message, "\n"+ethAddr+"\n") {
+				t.Fatal("\t\tShould issue challenge to the checksummed address, got: ", challenge, err)
+			}
+			t.Log("\t\tShould issue challenge to the checksummed address")
+			block, err := blockchain.SubmitStar(StarRequest{lower, challenge.Message, []byte("Star 1"), ethereum.SignMessage(testKey, challenge.Message)})
+			if err != nil || block.GetOwner() != ethAddr {
+				t.Fatal("\t\tShould store the star under the checksummed address, got: ", block, err)
+			}
+			t.Log("\t\tShould store the star under the checksummed address")
+			blockchain.AddBlock("0x"+strings.ToUpper(ethAddr[2:]), []byte("Star 2"))
+			for _, spelling := range []string{ethAddr, lower, "0X" + strings.ToUpper(ethAddr[2:])} {
+				if stars := blockchain.GetStarsByWalletAddress(spelling); len(stars) != 2 {
+					t.Fatal("\t\tShould find stars by any spelling, got: ", spelling, stars)
+				}
+			}
+			t.Log("\t\tShould find stars by any spelling")
+		}
+		t.Log("\tGiven bech32 address spelled in upper case")
+		{
+			p2wpkh := bitcoin.P2WPKHAddress(testKey.PubKey())
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			blockchain.AddBlock(strings.ToUpper(p2wpkh), []byte("Star"))
+			if stars := blockchain.GetStarsByWalletAddress(p2wpkh); len(stars) != 1 {
+				t.Fatal("\t\tShould find stars by lower case address, got: ", stars)
+			}
+			t.Log("\t\tShould find stars by lower case address")
+			if canonical := blockchain.CanonicalAddress(testAddr); canonical != testAddr {
+				t.Fatal("\t\tShould keep base58 address unchanged, got: ", canonical)
+			}
+			t.Log("\t\tShould keep base58 address unchanged")
+		}
+		t.Log("\tGiven policy with owners spelled in lower case")
+		{
+			ethAddr := ethereum.PubKeyToAddress(testKey.PubKey())
+			p2wpkh := bitcoin.P2WPKHAddress(testKey.PubKey())
+			policy, _ := multisig.New(1, []string{strings.ToLower(ethAddr), strings.ToUpper(p2wpkh)})
+			canonical, _ := multisig.New(1, []string{ethAddr, p2wpkh})
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			if actual := blockchain.CanonicalAddress(policy.String()); actual != canonical.String() {
+				t.Fatal("\t\tShould canonicalize and sort the owners, got: ", actual)
+			}
+			t.Log("\t\tShould canonicalize and sort the owners")
+			blockchain.AddBlock(policy.String(), []byte("Star"))
+			for _, addr := range []string{canonical.String(), ethAddr, p2wpkh} {
+				if stars := blockchain.GetStarsByWalletAddress(addr); len(stars) != 1 {
+					t.Fatal("\t\tShould find co-owned star by canonical owner, got: ", addr, stars)
+				}
+			}
+			t.Log("\t\tShould find co-owned star by canonical owner")
+		}
 	}
 }
 
@@ -402,7 +857,7 @@ func TestValidateChain(t *testing.T) {
 			t.Log("\tWhen hash is valid")
 			{
 				clock := BlockchainClockMock{}
-				blockchain := New(clock)
+				blockchain := New(clock, DefaultConfig())
 				errors := blockchain.ValidateChain()
 				if len(errors) > 0 {
 					t.Fatal("\t\tShould return no errors, got: ", errors)
@@ -415,14 +870,15 @@ func TestValidateChain(t *testing.T) {
 			t.Log("\tWhen second block prev hash is valid")
 			{
 				var (
-					addr1 = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
-					msg1  = fmt.Sprintf("%s:%d:starRegistry", addr1, 1592156792-3*60)
+					addr1 = testAddr
+					msg1  = challengeMsg(addr1, 1592156792-3*60)
 					star1 = []byte("Brand new Star 1")
-					sig   = "sig"
+					sig   = sign(testKey, msg1)
 					req1  = StarRequest{addr1, msg1, star1, sig}
 				)
 				clock := BlockchainClockMock{}
-				blockchain := New(clock)
+				blockchain := New(clock, DefaultConfig())
+				issue(blockchain, req1)
 				blockchain.SubmitStar(req1)
 				errors := blockchain.ValidateChain()
 				if len(errors) > 0 {
@@ -433,10 +889,10 @@ func TestValidateChain(t *testing.T) {
 			t.Log("\tWhen the first block is modified")
 			{
 				var (
-					addr1 = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
-					msg1  = fmt.Sprintf("%s:%d:starRegistry", addr1, 1592156792-3*60)
+					addr1 = testAddr
+					msg1  = challengeMsg(addr1, 1592156792-3*60)
 					star1 = []byte("Brand new Star 1")
-					sig   = "sig"
+					sig   = sign(testKey, msg1)
 					req1  = StarRequest{addr1, msg1, star1, sig}
 				)
 				var (
@@ -446,7 +902,8 @@ func TestValidateChain(t *testing.T) {
 					owner    string = ""
 				)
 				clock := BlockchainClockMock{}
-				blockchain := New(clock)
+				blockchain := New(clock, DefaultConfig())
+				issue(blockchain, req1)
 				blockchain.SubmitStar(req1)
 				blockchain.chain[0] = block.New(clock.GetTime()+1, h, owner, &prevHash, data)
 				errors := blockchain.ValidateChain()
@@ -456,5 +913,1639 @@ func TestValidateChain(t *testing.T) {
 				t.Log("\t\tShould return errors", errors)
 			}
 		}
+		t.Log("Given blocks of other producers")
+		{
+			producerKey := ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+			producer := New(BlockchainClockMock{}, Config{SigningKey: producerKey})
+			producer.AddBlock(testAddr, []byte("Produced Star"))
+			t.Log("\tWhen producer key is not trusted")
+			{
+				blockchain := New(BlockchainClockMock{}, DefaultConfig())
+				blockchain.chain = producer.chain
+				errors := blockchain.ValidateChain()
+				if len(errors) != 2 || !strings.Contains(errors[1].Error(), "untrusted key") {
+					t.Fatal("\t\tShould reject every block, got: ", errors)
+				}
+				t.Log("\t\tShould reject every block")
+			}
+			t.Log("\tWhen producer key is trusted")
+			{
+				config := Config{TrustedKeys: []ed25519.PublicKey{producer.GetProducerKey()}}
+				blockchain := New(BlockchainClockMock{}, config)
+				blockchain.chain = producer.chain
+				if errors := blockchain.ValidateChain(); len(errors) > 0 {
+					t.Fatal("\t\tShould return no errors, got: ", errors)
+				}
+				t.Log("\t\tShould return no errors")
+			}
+			t.Log("\tWhen block hash was recalculated without producer key")
+			{
+				blockchain := New(BlockchainClockMock{}, DefaultConfig())
+				prevHash := blockchain.chain[0].GetHash()
+				forged := block.New(BlockchainClockMock{}.GetTime(), 1, otherAddr, &prevHash, []byte("Forged"))
+				blockchain.chain = append(blockchain.chain, forged)
+				errors := blockchain.ValidateChain()
+				if len(errors) != 1 || !strings.Contains(errors[0].Error(), "not signed") {
+					t.Fatal("\t\tShould report unsigned block, got: ", errors)
+				}
+				forged.Sign(producerKey)
+				errors = blockchain.ValidateChain()
+				if len(errors) != 1 || !strings.Contains(errors[0].Error(), "untrusted key") {
+					t.Fatal("\t\tShould report untrusted key, got: ", errors)
+				}
+				t.Log("\t\tShould report the forged block")
+			}
+		}
+		t.Log("Given chain started before HashV2")
+		{
+			t.Log("\tWhen HashV1 blocks are followed by HashV2 block")
+			{
+				key := ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+				blockchain := New(BlockchainClockMock{}, Config{SigningKey: key, MaxClockDrift: DefaultMaxClockDrift})
+				var zero [sha256.Size]byte
+				genesis := hashV1Block(1592156792, 0, "", zero, []byte("Genesis Gopher Block"))
+				star := hashV1Block(1592156793, 1, testAddr, genesis.GetHash(), []byte("Old Star"))
+				prevHash := star.GetHash()
+				current := block.New(1592156794, 2, otherAddr, &prevHash, []byte("New Star"))
+				blockchain.chain = []*block.Block{genesis, star, current}
+				for _, b := range blockchain.chain {
+					b.Sign(key)
+				}
+				if errors := blockchain.ValidateChain(); len(errors) > 0 ||
+					star.GetHashVersion() != block.HashV1 || current.GetHashVersion() != block.HashV2 {
+					t.Fatal("\t\tShould return no errors, got: ", errors)
+				}
+				t.Log("\t\tShould return no errors")
+			}
+		}
+	}
+}
+
+// hashV1Block fn returns block hashed with HashV1, the way blocks
+// were decoded from storage written before hash versions were recorded
+func hashV1Block(ts int64, height int, owner string, prevHash [sha256.Size]byte, data []byte) *block.Block {
+	preimage := fmt.Sprintf("|%d|%d|%s|%x|%x|", ts, height, owner, prevHash, data)
+	encoded := fmt.Sprintf(`{"version":1,"time":%d,"height":%d,"owner":%q,"previousBlockHash":"%x","body":"%x","hash":"%x"}`,
+		ts, height, owner, prevHash, data, sha256.Sum256([]byte(preimage)))
+	var b block.Block
+	if err := json.Unmarshal([]byte(encoded), &b); err != nil {
+		log.Panic(err)
+	}
+	return &b
+}
+
+func TestValidateChainProofs(t *testing.T) {
+	t.Log("ValidateChainWith VerifyProofs")
+	{
+		opts := ValidationOptions{VerifyProofs: true}
+		star := []byte(`{"dec":"68° 52' 56.9","ra":"16h 29m 1.0s"}`)
+		t.Log("\tGiven submitted stars")
+		{
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			challenge, _ := blockchain.RequestMessageOwnershipVerification(testAddr, &StarCoordinates{"16h 29m 1.0s", "68° 52' 56.9"})
+			msg := challenge.Message
+			b, err := blockchain.SubmitStar(StarRequest{testAddr, msg, star, sign(testKey, msg)})
+			if err != nil {
+				t.Fatal("\t\tShould accept the star, got err: ", err)
+			}
+			if b.GetMessage() != msg || b.GetMessageSignature() != sign(testKey, msg) {
+				t.Fatal("\t\tShould store ownership proof in the block")
+			}
+			t.Log("\t\tShould store ownership proof in the block")
+			if errors := blockchain.ValidateChainWith(opts); len(errors) > 0 {
+				t.Fatal("\t\tShould return no errors, got: ", errors)
+			}
+			t.Log("\t\tShould return no errors")
+		}
+		t.Log("\tGiven block added without ownership proof")
+		{
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			blockchain.AddBlock(testAddr, star)
+			if errors := blockchain.ValidateChain(); len(errors) > 0 {
+				t.Fatal("\t\tShould pass default validation, got: ", errors)
+			}
+			errors := blockchain.ValidateChainWith(opts)
+			if len(errors) != 1 || !strings.Contains(errors[0].Error(), "no ownership proof") {
+				t.Fatal("\t\tShould report missing proof, got: ", errors)
+			}
+			t.Log("\t\tShould report missing proof")
+		}
+		t.Log("\tGiven block rebuilt by the producer with forged proof")
+		{
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			msg := challengeMsg(testAddr, 1592156792-60)
+			cases := []struct {
+				owner string
+				data  []byte
+				msg   string
+				sig   string
+			}{
+				{testAddr, star, msg, sign(otherKey, msg)},
+				{otherAddr, star, msg, sign(otherKey, msg)},
+				{testAddr, star, "not a challenge", sign(testKey, "not a challenge")},
+			}
+			for _, c := range cases {
+				prevHash := blockchain.chain[0].GetHash()
+				forged := block.NewStar(BlockchainClockMock{}.GetTime(), 1, c.owner, &prevHash, c.data, c.msg, c.sig)
+				forged.Sign(blockchain.config.SigningKey)
+				blockchain.chain = append(blockchain.chain[:1], forged)
+				if errors := blockchain.ValidateChain(); len(errors) > 0 {
+					t.Fatal("\t\tShould pass default validation, got: ", errors)
+				}
+				if errors := blockchain.ValidateChainWith(opts); len(errors) != 1 {
+					t.Fatalf("\t\tShould report forged proof of %s, got: %v", c.owner, errors)
+				}
+			}
+			t.Log("\t\tShould report forged proofs")
+		}
+	}
+}
+
+func TestSubmitStarMultisig(t *testing.T) {
+	t.Log("SubmitStar co-owned by multisig policy")
+	{
+		didKey := ed25519.NewKeyFromSeed([]byte("starchain test key #3 - 32 bytes"))
+		didAddr := didkey.EncodeDID(didKey.Public().(ed25519.PublicKey))
+		policy, _ := multisig.New(2, []string{testAddr, otherAddr, didAddr})
+		addr := policy.String()
+		star := []byte("Our star")
+		blockchain := New(BlockchainClockMock{}, DefaultConfig())
+		challenge, _ := blockchain.RequestMessageOwnershipVerification(addr, nil)
+		msg := challenge.Message
+		t.Log("\tGiven signature of one owner")
+		{
+			sig, _ := policy.EncodeSignatures(map[string]string{testAddr: sign(testKey, msg)})
+			if _, err := blockchain.SubmitStar(StarRequest{addr, msg, star, sig}); err != MsgSigMistmatchErr {
+				t.Fatal("\t\tShould return MsgSigMistmatchErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the star")
+		}
+		t.Log("\tGiven signatures of two owners")
+		{
+			sig, _ := policy.EncodeSignatures(map[string]string{
+				testAddr: sign(testKey, msg),
+				didAddr:  didkey.SignMessage(didKey, msg),
+			})
+			b, err := blockchain.SubmitStar(StarRequest{addr, msg, star, sig})
+			if err != nil {
+				t.Fatal("\t\tShould accept the star, got err: ", err)
+			}
+			if b.GetOwner() != addr {
+				t.Fatal("\t\tShould record the policy in the block, got: ", b.GetOwner())
+			}
+			t.Log("\t\tShould record the policy in the block")
+			for _, owner := range []string{testAddr, otherAddr, didAddr, addr} {
+				if stars := blockchain.GetStarsByWalletAddress(owner); len(stars) != 1 || stars[0] != string(star) {
+					t.Fatalf("\t\tShould return the star for %s, got: %v", owner, stars)
+				}
+			}
+			t.Log("\t\tShould return the star for every co-owner")
+			if errors := blockchain.ValidateChainWith(ValidationOptions{VerifyProofs: true}); len(errors) > 0 {
+				t.Fatal("\t\tShould verify stored proof, got: ", errors)
+			}
+			t.Log("\t\tShould verify stored proof")
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	t.Log("ParseChallenge")
+	{
+		star := &StarCoordinates{RA: "16h 29m 1.0s", Dec: "68° 52' 56.9"}
+		m := ChallengeMessage{
+			Domain:         DefaultDomain,
+			Address:        testAddr,
+			ChainID:        DefaultChainID,
+			Nonce:          strings.Repeat("ab", NonceSize),
+			IssuedAt:       1592156792,
+			ExpirationTime: 1592156792 + FIVE_MIN,
+			Star:           star,
+		}
+		t.Log("\tGiven structured message")
+		{
+			parsed, err := ParseChallenge(m.String(), false)
+			if err != nil {
+				t.Fatal("\t\tShould parse the message, got err: ", err)
+			}
+			if parsed.Star == nil || *parsed.Star != *star {
+				t.Fatal("\t\tShould parse star coordinates, got: ", parsed.Star)
+			}
+			parsed.Star = star
+			if parsed != m {
+				t.Fatalf("\t\tShould return the same message, got: %+v", parsed)
+			}
+			t.Log("\t\tShould return the same message")
+		}
+		t.Log("\tGiven tampered structured message")
+		{
+			msg := m.String()
+			cases := []string{
+				msg + "\n",
+				"evil.com\n" + msg,
+				strings.Replace(msg, "Version: 1", "Version: 2", 1),
+				strings.Replace(msg, "Nonce: ab", "Nonce: AB", 1),
+				strings.Replace(msg, "Issued At: 2020-06-14T17:46:32Z", "Issued At: 1592156792", 1),
+				strings.Replace(msg, "Expiration Time: 2020-06-14T17:51:32Z", "Expiration Time: 2020-06-14T17:46:32Z", 1),
+				strings.Replace(msg, testAddr, testAddr+" "+otherAddr, 1),
+				strings.Replace(msg, "Chain ID: ", "Chain Id: ", 1),
+				strings.Replace(msg, "\nStar Dec: 68° 52' 56.9", "", 1),
+			}
+			for _, c := range cases {
+				if _, err := ParseChallenge(c, true); !errors.Is(err, MalformedMsgErr) {
+					t.Fatalf("\t\tShould return MalformedMsgErr for %q, got: %v", c, err)
+				}
+			}
+			t.Log("\t\tShould return MalformedMsgErr")
+		}
+		t.Log("\tGiven legacy message")
+		{
+			msg := fmt.Sprintf("%s:%d:starRegistry", testAddr, 1592156792)
+			if _, err := ParseChallenge(msg, false); err != LegacyMsgErr {
+				t.Fatal("\t\tShould return LegacyMsgErr when not allowed, got: ", err)
+			}
+			parsed, err := ParseChallenge(msg+":"+strings.Repeat("ab", NonceSize), true)
+			if err != nil {
+				t.Fatal("\t\tShould parse the message when allowed, got err: ", err)
+			}
+			if !parsed.Legacy || parsed.Address != testAddr || parsed.IssuedAt != 1592156792 {
+				t.Fatalf("\t\tShould parse address and timestamp, got: %+v", parsed)
+			}
+			t.Log("\t\tShould parse the message only when allowed")
+		}
+	}
+}
+
+func TestChallengeBinding(t *testing.T) {
+	t.Log("Challenge binding")
+	{
+		star := &StarCoordinates{RA: "16h 29m 1.0s", Dec: "68° 52' 56.9"}
+		starData := []byte(`{"dec":"68° 52' 56.9","ra":"16h 29m 1.0s","story":"Found star"}`)
+		t.Log("\tGiven a challenge bound to star coordinates")
+		{
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			challenge, err := blockchain.RequestMessageOwnershipVerification(testAddr, star)
+			if err != nil {
+				t.Fatal("\t\tShould issue the challenge, got err: ", err)
+			}
+			msg := challenge.Message
+			other := []byte(`{"dec":"-26° 29' 24.9","ra":"16h 29m 1.0s","story":"Other star"}`)
+			if _, err := blockchain.SubmitStar(StarRequest{testAddr, msg, other, sign(testKey, msg)}); err != StarMismatchErr {
+				t.Fatal("\t\tShould return StarMismatchErr for other star, got: ", err)
+			}
+			t.Log("\t\tShould reject other star")
+			if _, err := blockchain.SubmitStar(StarRequest{testAddr, msg, starData, sign(testKey, msg)}); err != nil {
+				t.Fatal("\t\tShould accept the star, got err: ", err)
+			}
+			t.Log("\t\tShould accept the star")
+		}
+		t.Log("\tGiven incomplete star coordinates")
+		{
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			if _, err := blockchain.RequestMessageOwnershipVerification(testAddr, &StarCoordinates{RA: "16h"}); err != EmptyStarCoordErr {
+				t.Fatal("\t\tShould return EmptyStarCoordErr, got: ", err)
+			}
+			injected := &StarCoordinates{RA: "16h", Dec: "68°\nNonce: 00"}
+			if _, err := blockchain.RequestMessageOwnershipVerification(testAddr, injected); !errors.Is(err, MalformedMsgErr) {
+				t.Fatal("\t\tShould return MalformedMsgErr, got: ", err)
+			}
+			t.Log("\t\tShould refuse to issue the challenge")
+		}
+		t.Log("\tGiven a challenge of other domain")
+		{
+			issuer := New(BlockchainClockMock{}, Config{Domain: "evil.com"})
+			challenge, _ := issuer.RequestMessageOwnershipVerification(testAddr, nil)
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			msg := challenge.Message
+			if _, err := blockchain.SubmitStar(StarRequest{testAddr, msg, starData, sign(testKey, msg)}); err != WrongDomainErr {
+				t.Fatal("\t\tShould return WrongDomainErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the star")
+		}
+		t.Log("\tGiven address with regular expression metacharacters")
+		{
+			blockchain := New(BlockchainClockMock{}, DefaultConfig())
+			addr := "1.*"
+			msg := challengeMsg(testAddr, 1592156792)
+			if _, err := blockchain.IsMessageOutdated(addr, msg); !errors.Is(err, MalformedMsgErr) {
+				t.Fatal("\t\tShould not match message of other address, got: ", err)
+			}
+			t.Log("\t\tShould not match message of other address")
+		}
+		t.Log("\tGiven legacy challenges enabled")
+		{
+			blockchain := New(BlockchainClockMock{}, Config{LegacyChallenges: true})
+			blockchain.random = bytes.NewReader(bytes.Repeat([]byte{0xab}, NonceSize))
+			challenge, _ := blockchain.RequestMessageOwnershipVerification(testAddr, nil)
+			expected := fmt.Sprintf("%s:%d:starRegistry:%s", testAddr, 1592156792, strings.Repeat("ab", NonceSize))
+			if challenge.Message != expected {
+				t.Fatal("\t\tShould issue legacy message, got: ", challenge.Message)
+			}
+			msg := challenge.Message
+			if _, err := blockchain.SubmitStar(StarRequest{testAddr, msg, starData, sign(testKey, msg)}); err != nil {
+				t.Fatal("\t\tShould accept the star, got err: ", err)
+			}
+			if _, err := blockchain.RequestMessageOwnershipVerification(testAddr, star); err != LegacyStarErr {
+				t.Fatal("\t\tShould return LegacyStarErr, got: ", err)
+			}
+			t.Log("\t\tShould issue and accept legacy messages")
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	t.Log("FileStore")
+	{
+		dir, err := ioutil.TempDir("", "starchain-store")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "chain.dat")
+		config := DefaultConfig()
+		config.SigningKey = ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+		open := func() (*Blockchain, error) {
+			store, err := OpenFileStore(path)
+			if err != nil {
+				return nil, err
+			}
+			return Open(BlockchainClockMock{}, config, store)
+		}
+		var hashes [][sha256.Size]byte
+		t.Log("\tGiven a blockchain with stars")
+		{
+			blockchain, err := open()
+			if err != nil {
+				t.Fatal("\t\tShould create the store, got err: ", err)
+			}
+			challenge, _ := blockchain.RequestMessageOwnershipVerification(testAddr, nil)
+			msg := challenge.Message
+			if _, err := blockchain.SubmitStar(StarRequest{testAddr, msg, []byte("Stored star"), sign(testKey, msg)}); err != nil {
+				t.Fatal("\t\tShould accept the star, got err: ", err)
+			}
+			blockchain.AddBlock(otherAddr, nil)
+			for _, b := range blockchain.chain {
+				hashes = append(hashes, b.GetHash())
+			}
+			blockchain.Close()
+			t.Log("\t\tWhen reopened")
+			{
+				blockchain, err := open()
+				if err != nil {
+					t.Fatal("\t\t\tShould reload the chain, got err: ", err)
+				}
+				if blockchain.GetChainHeight() != len(hashes) {
+					t.Fatal("\t\t\tShould reload all blocks, got height: ", blockchain.GetChainHeight())
+				}
+				for i, b := range blockchain.chain {
+					if b.GetHash() != hashes[i] || !b.Validate() {
+						t.Fatalf("\t\t\tShould reload block %d faithfully", i)
+					}
+				}
+				if err := blockchain.verifyProof(blockchain.chain[1]); err != nil {
+					t.Fatal("\t\t\tShould keep ownership proof, got: ", err)
+				}
+				if stars := blockchain.GetStarsByWalletAddress(testAddr); len(stars) != 1 || stars[0] != "Stored star" {
+					t.Fatal("\t\t\tShould reload star data, got: ", stars)
+				}
+				if b, err := blockchain.GetBlockByHash(hashes[2]); err != nil || b.GetHeight() != 2 {
+					t.Fatal("\t\t\tShould index reloaded blocks, got err: ", err)
+				}
+				blockchain.Close()
+				t.Log("\t\t\tShould reload all blocks")
+			}
+		}
+		t.Log("\tGiven torn final record")
+		{
+			info, _ := os.Stat(path)
+			file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+			file.Write([]byte{0, 0, 0, 100, 1, 2, 3, 4, '{', '"'})
+			file.Close()
+			blockchain, err := open()
+			if err != nil {
+				t.Fatal("\t\tShould recover, got err: ", err)
+			}
+			if truncated, _ := os.Stat(path); truncated.Size() != info.Size() {
+				t.Fatal("\t\tShould truncate the torn record, got size: ", truncated.Size())
+			}
+			if blockchain.GetChainHeight() != len(hashes) {
+				t.Fatal("\t\tShould keep stored blocks, got height: ", blockchain.GetChainHeight())
+			}
+			blockchain.AddBlock(otherAddr, []byte("After recovery"))
+			blockchain.Close()
+			blockchain, err = open()
+			if err != nil || blockchain.GetChainHeight() != len(hashes)+1 {
+				t.Fatal("\t\tShould append after recovery, got: ", err)
+			}
+			blockchain.Close()
+			t.Log("\t\tShould truncate the torn record")
+		}
+		t.Log("\tGiven damaged record in the middle")
+		{
+			content, _ := ioutil.ReadFile(path)
+			damaged := append([]byte{}, content...)
+			damaged[recordHeaderSize+2] ^= 0xff
+			ioutil.WriteFile(path, damaged, 0644)
+			if _, err := open(); err == nil || !strings.HasPrefix(err.Error(), CorruptedStoreErr.Error()) {
+				t.Fatal("\t\tShould return CorruptedStoreErr, got: ", err)
+			}
+			t.Log("\t\tShould return CorruptedStoreErr")
+			ioutil.WriteFile(path, content, 0644)
+		}
+		t.Log("\tGiven damaged length of a record in the middle")
+		{
+			content, _ := ioutil.ReadFile(path)
+			offset := 0
+			for i := 0; i < 2; i++ {
+				offset += recordHeaderSize + int(binary.BigEndian.Uint32(content[offset:offset+4]))
+			}
+			for _, damage := range []byte{0x80, 0x01} {
+				damaged := append([]byte{}, content...)
+				damaged[offset] ^= damage
+				ioutil.WriteFile(path, damaged, 0644)
+				if _, err := open(); err == nil || !strings.HasPrefix(err.Error(), CorruptedStoreErr.Error()) || !strings.Contains(err.Error(), "starchain fsck") {
+					t.Fatalf("\t\tShould return CorruptedStoreErr for length damaged with %x, got: %v", damage, err)
+				}
+				if info, _ := os.Stat(path); info.Size() != int64(len(damaged)) {
+					t.Fatalf("\t\tShould not truncate the file for length damaged with %x, got size: %d", damage, info.Size())
+				}
+				if report, _ := CheckFile(path); len(report.Findings) == 0 || report.GoodBlocks != 2 {
+					t.Fatalf("\t\tShould leave the damage to fsck for length damaged with %x, got: %v", damage, report)
+				}
+			}
+			t.Log("\t\tShould return CorruptedStoreErr and keep the file")
+			ioutil.WriteFile(path, content, 0644)
+		}
+		t.Log("\tGiven blocks of untrusted producer")
+		{
+			config.SigningKey = nil
+			if _, err := open(); err == nil || !strings.HasPrefix(err.Error(), InvalidStoreErr.Error()) {
+				t.Fatal("\t\tShould return InvalidStoreErr, got: ", err)
+			}
+			t.Log("\t\tShould return InvalidStoreErr")
+		}
+		t.Log("\tGiven store written in the legacy JSON layout")
+		{
+			key := ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+			legacyPath := filepath.Join(dir, "legacy.dat")
+			var content []byte
+			for _, payload := range legacyRecords {
+				content = append(content, frameRecord([]byte(payload))...)
+			}
+			ioutil.WriteFile(legacyPath, content, 0644)
+			store, err := OpenFileStore(legacyPath)
+			if err != nil {
+				t.Fatal("\t\tShould open the store, got err: ", err)
+			}
+			legacyConfig := DefaultConfig()
+			legacyConfig.SigningKey = key
+			blockchain, err := Open(BlockchainClockMock{}, legacyConfig, store)
+			if err != nil {
+				t.Fatal("\t\tShould load legacy records, got err: ", err)
+			}
+			for height, hash := range []string{legacyGenesisHash, legacyStarHash} {
+				b, err := blockchain.GetBlockByHeight(height)
+				if err != nil || b.GetHashVersion() != block.HashV1 || fmt.Sprintf("%x", b.GetHash()) != hash || fmt.Sprintf("%x", b.CalculateHash()) != hash {
+					t.Fatal("\t\tShould decode legacy records as HashV1 blocks, got err: ", height, err)
+				}
+			}
+			if stars := blockchain.GetStarsByWalletAddress(otherAddr); len(stars) != 1 || stars[0] != "Legacy star" {
+				t.Fatal("\t\tShould decode legacy star data, got: ", stars)
+			}
+			t.Log("\t\tShould load legacy records")
+			blockchain.AddBlock(otherAddr, []byte("After legacy"))
+			blockchain.Close()
+			if report, err := CheckFile(legacyPath); err != nil || report.Blocks != 3 || len(report.Findings) != 0 {
+				t.Fatal("\t\tShould append binary records after legacy ones, got: ", report, err)
+			}
+			t.Log("\t\tShould append binary records after legacy ones")
+		}
+	}
+}
+
+// legacyRecords are payloads of the genesis and a star block of otherAddr
+// written by FileStore before the binary encoding, when records were the
+// block JSON. Blocks are signed by the producer key.
+var legacyRecords = []string{
+	`{"time":1592156792,"height":0,"owner":"","prevHash":"0000000000000000000000000000000000000000000000000000000000000000","data":"47656e65736973","hash":"03867f4a702600378bdd072d6b076474d48de5ed61ea5978e39886afd7fc4c1d","signature":"fe29997183145b385adebf463b93557688c6e70f12e7c0481fa67cb0f81f64431cff0a037a7af379cbfadffb8b0b8e8747058a2a0e3bea49c32b2279a1636f05","keyId":"d5ca42577f0ccabd"}`,
+	`{"time":1592156800,"height":1,"owner":"1EjSpmM3F8HXJRj5rV7HfVPeNDfXFrZGfi","prevHash":"03867f4a702600378bdd072d6b076474d48de5ed61ea5978e39886afd7fc4c1d","data":"4c65676163792073746172","hash":"8e32eae65da341fc78488d6ef95fb08532d5a9602629b377e3f3d71c584e309c","signature":"e6284e58a78acc814dfdda697a605bb5f008d8757aef7581804e433b610a5324271dd772ef0b54793259da551010ea5b3641042d7f285772808428b01af14f06","keyId":"d5ca42577f0ccabd"}`,
+}
+
+// HashV1 hashes of the blocks of legacyRecords
+const (
+	legacyGenesisHash = "03867f4a702600378bdd072d6b076474d48de5ed61ea5978e39886afd7fc4c1d"
+	legacyStarHash    = "8e32eae65da341fc78488d6ef95fb08532d5a9602629b377e3f3d71c584e309c"
+)
+
+// benchChains caches chains built for benchmarks by size
+var benchChains = make(map[int]*Blockchain)
+
+// benchChain fn returns blockchain of n blocks, every owner has 10 stars.
+// Blocks are not signed, signing a million blocks takes too long.
+func benchChain(n int) *Blockchain {
+	if blockchain, ok := benchChains[n]; ok {
+		return blockchain
+	}
+	blockchain := New(BlockchainClockMock{}, DefaultConfig())
+	blockchain.mutex.Lock()
+	for height := 1; height < n; height++ {
+		prevHash := blockchain.chain[height-1].GetHash()
+		owner := fmt.Sprintf("owner-%d", height/10)
+		b := block.New(BlockchainClockMock{}.GetTime(), height, owner, &prevHash, []byte(owner))
+		blockchain.chain = append(blockchain.chain, b)
+		blockchain.index(b)
+	}
+	blockchain.mutex.Unlock()
+	benchChains[n] = blockchain
+	return blockchain
+}
+
+var benchSizes = []int{1000, 1000000}
+
+func BenchmarkGetBlockByHash(b *testing.B) {
+	for _, n := range benchSizes {
+		blockchain := benchChain(n)
+		b.Run(fmt.Sprintf("blocks=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				hash := blockchain.chain[i%n].GetHash()
+				if _, err := blockchain.GetBlockByHash(hash); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetBlockByHeight(b *testing.B) {
+	for _, n := range benchSizes {
+		blockchain := benchChain(n)
+		b.Run(fmt.Sprintf("blocks=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := blockchain.GetBlockByHeight(i % n); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetStarsByWalletAddress(b *testing.B) {
+	for _, n := range benchSizes {
+		blockchain := benchChain(n)
+		b.Run(fmt.Sprintf("blocks=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				owner := fmt.Sprintf("owner-%d", (i%(n/10-1))+1)
+				if stars := blockchain.GetStarsByWalletAddress(owner); len(stars) != 10 {
+					b.Fatal("expected 10 stars, got: ", len(stars))
+				}
+			}
+		})
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	t.Log("Export and Import")
+	{
+		config := DefaultConfig()
+		config.SigningKey = ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+		source := New(BlockchainClockMock{}, config)
+		challenge, _ := source.RequestMessageOwnershipVerification(testAddr, nil)
+		msg := challenge.Message
+		if _, err := source.SubmitStar(StarRequest{testAddr, msg, []byte("Exported star"), sign(testKey, msg)}); err != nil {
+			t.Fatal(err)
+		}
+		source.AddBlock(otherAddr, []byte("Other star"))
+		source.AddBlock(otherAddr, []byte("Last star"))
+		for _, format := range []string{ExportNDJSON, ExportBinary} {
+			var exported bytes.Buffer
+			if err := source.Export(&exported, format); err != nil {
+				t.Fatal("\tShould export, got err: ", err)
+			}
+			t.Logf("\tGiven %s export", format)
+			{
+				imported, err := Import(bytes.NewReader(exported.Bytes()), BlockchainClockMock{}, config, NewMemoryStore())
+				if err != nil {
+					t.Fatal("\t\tShould import, got err: ", err)
+				}
+				if imported.GetChainHeight() != source.GetChainHeight() {
+					t.Fatal("\t\tShould import every block, got height: ", imported.GetChainHeight())
+				}
+				for i, b := range imported.chain {
+					if b.GetHash() != source.chain[i].GetHash() || !bytes.Equal(b.GetSignature(), source.chain[i].GetSignature()) {
+						t.Fatalf("\t\tShould import block %d faithfully", i)
+					}
+				}
+				if err := imported.verifyProof(imported.chain[1]); err != nil {
+					t.Fatal("\t\tShould keep ownership proof, got: ", err)
+				}
+				t.Log("\t\tShould import every block")
+				store := NewMemoryStore()
+				store.Append(source.chain[0])
+				if _, err := Import(bytes.NewReader(exported.Bytes()), BlockchainClockMock{}, config, store); err != NonEmptyStoreErr {
+					t.Fatal("\t\tShould return NonEmptyStoreErr, got: ", err)
+				}
+				t.Log("\t\tShould import only into empty store")
+				other := config
+				other.ChainID = "starchain-2"
+				if _, err := Import(bytes.NewReader(exported.Bytes()), BlockchainClockMock{}, other, NewMemoryStore()); err == nil ||
+					!strings.HasPrefix(err.Error(), InvalidExportErr.Error()) {
+					t.Fatal("\t\tShould reject other chain, got: ", err)
+				}
+				t.Log("\t\tShould reject other chain")
+				untrusted := config
+				untrusted.SigningKey = nil
+				store = NewMemoryStore()
+				if _, err := Import(bytes.NewReader(exported.Bytes()), BlockchainClockMock{}, untrusted, store); err == nil ||
+					!strings.HasPrefix(err.Error(), InvalidExportErr.Error()) {
+					t.Fatal("\t\tShould reject blocks of untrusted producer, got: ", err)
+				}
+				if blocks, _ := store.Load(); len(blocks) != 0 {
+					t.Fatal("\t\tShould not store blocks of untrusted producer, got: ", len(blocks))
+				}
+				t.Log("\t\tShould reject blocks of untrusted producer before storing them")
+			}
+		}
+		t.Log("\tGiven tampered NDJSON export")
+		{
+			var exported bytes.Buffer
+			source.Export(&exported, ExportNDJSON)
+			lines := strings.Split(exported.String(), "\n")
+			lines[3] = strings.Replace(lines[3], hex.EncodeToString([]byte("Other star")), hex.EncodeToString([]byte("Stolen star")), 1)
+			tampered := strings.Join(lines, "\n")
+			_, err := Import(strings.NewReader(tampered), BlockchainClockMock{}, config, NewMemoryStore())
+			if err == nil || !strings.Contains(err.Error(), "block at height 2:") {
+				t.Fatal("\t\tShould report the first bad height, got: ", err)
+			}
+			t.Log("\t\tShould report the first bad height")
+			lines = strings.Split(exported.String(), "\n")
+			lines[2], lines[3] = lines[3], lines[2]
+			_, err = Import(strings.NewReader(strings.Join(lines, "\n")), BlockchainClockMock{}, config, NewMemoryStore())
+			if err == nil || !strings.Contains(err.Error(), "block at height 1:") {
+				t.Fatal("\t\tShould report reordered blocks, got: ", err)
+			}
+			t.Log("\t\tShould report reordered blocks")
+			lines = strings.Split(exported.String(), "\n")
+			_, err = Import(strings.NewReader(strings.Join(lines[:4], "\n")), BlockchainClockMock{}, config, NewMemoryStore())
+			if err == nil || !strings.Contains(err.Error(), "header says") {
+				t.Fatal("\t\tShould report missing blocks, got: ", err)
+			}
+			t.Log("\t\tShould report missing blocks")
+		}
+		t.Log("\tGiven truncated binary export")
+		{
+			var exported bytes.Buffer
+			source.Export(&exported, ExportBinary)
+			truncated := exported.Bytes()[:exported.Len()-10]
+			_, err := Import(bytes.NewReader(truncated), BlockchainClockMock{}, config, NewMemoryStore())
+			if err == nil || !strings.Contains(err.Error(), "block at height 3:") {
+				t.Fatal("\t\tShould report the first bad height, got: ", err)
+			}
+			t.Log("\t\tShould report the first bad height")
+		}
+	}
+}
+
+func TestGenesis(t *testing.T) {
+	t.Log("Genesis")
+	{
+		producerKey := ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+		nodeKey := ed25519.NewKeyFromSeed([]byte("starchain other key - 32 bytes!!"))
+		genesis := Genesis{ChainID: "starchain-test", Timestamp: 1592150000, Data: "Test network"}
+		t.Log("\tGiven nodes with the same genesis")
+		{
+			first, err := Open(BlockchainClockMock{}, Config{SigningKey: producerKey, Genesis: &genesis}, NewMemoryStore())
+			if err != nil {
+				t.Fatal("\t\tShould create the chain, got err: ", err)
+			}
+			second, err := Open(BlockchainClock{}, Config{SigningKey: nodeKey, Genesis: &genesis}, NewMemoryStore())
+			if err != nil {
+				t.Fatal("\t\tShould create the chain, got err: ", err)
+			}
+			if first.chain[0].GetHash() != second.chain[0].GetHash() || first.chain[0].GetTimestamp() != genesis.Timestamp {
+				t.Fatal("\t\tShould derive the same genesis block")
+			}
+			if first.GetConfig().ChainID != genesis.ChainID {
+				t.Fatal("\t\tShould use chain ID of the genesis, got: ", first.GetConfig().ChainID)
+			}
+			t.Log("\t\tShould derive the same genesis block")
+		}
+		t.Log("\tGiven genesis with producer key")
+		{
+			withProducer := genesis
+			withProducer.ProducerKey = hex.EncodeToString(producerKey.Public().(ed25519.PublicKey))
+			if _, err := Open(BlockchainClockMock{}, Config{SigningKey: nodeKey, Genesis: &withProducer}, NewMemoryStore()); err != GenesisProducerErr {
+				t.Fatal("\t\tShould return GenesisProducerErr, got: ", err)
+			}
+			t.Log("\t\tShould refuse to create genesis on other nodes")
+			producer, err := Open(BlockchainClockMock{}, Config{SigningKey: producerKey, Genesis: &withProducer}, NewMemoryStore())
+			if err != nil {
+				t.Fatal("\t\tShould create the chain, got err: ", err)
+			}
+			store := NewMemoryStore()
+			store.Append(producer.chain[0])
+			if _, err := Open(BlockchainClockMock{}, Config{SigningKey: nodeKey, Genesis: &withProducer}, store); err != nil {
+				t.Fatal("\t\tShould trust genesis of the producer, got err: ", err)
+			}
+			t.Log("\t\tShould trust genesis of the producer")
+		}
+		t.Log("\tGiven store with other genesis")
+		{
+			store := NewMemoryStore()
+			if _, err := Open(BlockchainClockMock{}, Config{SigningKey: producerKey}, store); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Open(BlockchainClockMock{}, Config{SigningKey: producerKey, Genesis: &genesis}, store)
+			if err == nil || !strings.HasPrefix(err.Error(), GenesisMismatchErr.Error()) {
+				t.Fatal("\t\tShould refuse the store, got: ", err)
+			}
+			t.Log("\t\tShould refuse the store")
+		}
+		t.Log("\tGiven invalid genesis configuration")
+		{
+			config := Config{SigningKey: producerKey, ChainID: "starchain-other", Genesis: &genesis}
+			if _, err := Open(BlockchainClockMock{}, config, NewMemoryStore()); err != GenesisChainIDErr {
+				t.Fatal("\t\tShould return GenesisChainIDErr, got: ", err)
+			}
+			dir, err := ioutil.TempDir("", "starchain-genesis")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "genesis.json")
+			ioutil.WriteFile(path, []byte(`{"chainId":"starchain-test","timestamp":1592150000,"data":"Test network"}`), 0644)
+			if loaded, err := LoadGenesis(path); err != nil || loaded != genesis {
+				t.Fatal("\t\tShould load genesis, got: ", loaded, err)
+			}
+			for _, content := range []string{
+				`{"chainId":"starchain-test","data":"Test network"}`,
+				`{"timestamp":1592150000}`,
+				`{"chainId":"starchain-test","timestamp":1592150000,"producerKey":"abcd"}`,
+			} {
+				ioutil.WriteFile(path, []byte(content), 0644)
+				if _, err := LoadGenesis(path); err != InvalidGenesisErr {
+					t.Fatal("\t\tShould return InvalidGenesisErr, got: ", err)
+				}
+			}
+			t.Log("\t\tShould return errors")
+		}
+	}
+}
+
+func TestValidateChainCheckpoints(t *testing.T) {
+	t.Log("ValidateChain checkpoints")
+	{
+		config := Config{SigningKey: ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))}
+		blockchain := New(BlockchainClockMock{}, config)
+		blockchain.AddBlock(testAddr, []byte("Star 1"))
+		blockchain.AddBlock(testAddr, []byte("Star 2"))
+		t.Log("\tGiven valid chain")
+		{
+			if errors := blockchain.ValidateChain(); len(errors) > 0 {
+				t.Fatal("\t\tShould return no errors, got: ", errors)
+			}
+			checkpoints := blockchain.GetCheckpoints()
+			if len(checkpoints) != 1 || checkpoints[0].Height != 2 || checkpoints[0].Hash != blockchain.chain[2].GetHash() {
+				t.Fatal("\t\tShould record checkpoint at the head, got: ", checkpoints)
+			}
+			blockchain.ValidateChain()
+			if len(blockchain.GetCheckpoints()) != 1 {
+				t.Fatal("\t\tShould not record the same checkpoint twice")
+			}
+			t.Log("\t\tShould record checkpoint at the head")
+		}
+		t.Log("\tGiven block before the checkpoint modified")
+		{
+			original := blockchain.chain[1]
+			prevHash := blockchain.chain[0].GetHash()
+			blockchain.chain[1] = block.New(BlockchainClockMock{}.GetTime(), 1, otherAddr, &prevHash, []byte("Forged"))
+			if errors := blockchain.ValidateChain(); len(errors) > 0 {
+				t.Fatal("\t\tShould validate only blocks after the checkpoint, got: ", errors)
+			}
+			t.Log("\t\tShould validate only blocks after the checkpoint")
+			if errors := blockchain.ValidateChainWith(ValidationOptions{Full: true}); len(errors) == 0 {
+				t.Fatal("\t\tShould find the block with full validation")
+			}
+			t.Log("\t\tShould find the block with full validation")
+			blockchain.chain[1] = original
+		}
+		t.Log("\tGiven new blocks after the checkpoint")
+		{
+			blockchain.AddBlock(otherAddr, []byte("Star 3"))
+			if errors := blockchain.ValidateChain(); len(errors) > 0 {
+				t.Fatal("\t\tShould return no errors, got: ", errors)
+			}
+			checkpoints := blockchain.GetCheckpoints()
+			if len(checkpoints) != 2 || checkpoints[1].Height != 3 {
+				t.Fatal("\t\tShould record new checkpoint, got: ", checkpoints)
+			}
+			t.Log("\t\tShould record new checkpoint")
+			prevHash := blockchain.chain[2].GetHash()
+			blockchain.chain[3] = block.New(BlockchainClockMock{}.GetTime(), 3, otherAddr, &prevHash, []byte("Forged"))
+			if errors := blockchain.ValidateChain(); len(errors) == 0 {
+				t.Fatal("\t\tShould validate from scratch when checkpointed block changed")
+			}
+			t.Log("\t\tShould validate from scratch when checkpointed block changed")
+		}
+		t.Log("\tGiven checkpoint without ownership proofs")
+		{
+			if errors := blockchain.ValidateChainWith(ValidationOptions{VerifyProofs: true}); len(errors) == 0 {
+				t.Fatal("\t\tShould verify proofs of every block")
+			}
+			t.Log("\t\tShould verify proofs of every block")
+		}
+		t.Log("\tGiven more validations than checkpoints kept")
+		{
+			blockchain := New(BlockchainClockMock{}, config)
+			for i := 1; i <= 2*maxCheckpoints; i++ {
+				blockchain.AddBlock(testAddr, []byte(fmt.Sprintf("Star %d", i)))
+				blockchain.ValidateChain()
+			}
+			checkpoints := blockchain.GetCheckpoints()
+			if len(checkpoints) != maxCheckpoints || checkpoints[maxCheckpoints-1].Height != blockchain.GetChainHeight()-1 {
+				t.Fatal("\t\tShould keep only the last checkpoints, got: ", checkpoints)
+			}
+			t.Log("\t\tShould keep only the last checkpoints")
+		}
+	}
+}
+
+func TestValidateChainContext(t *testing.T) {
+	t.Log("ValidateChainContext")
+	{
+		config := Config{SigningKey: ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))}
+		blockchain := New(BlockchainClockMock{}, config)
+		for i := 1; i < 1000; i++ {
+			blockchain.AddBlock(testAddr, []byte(fmt.Sprintf("Star %d", i)))
+		}
+		for _, height := range []int{700, 300} {
+			prevHash := blockchain.chain[height-1].GetHash()
+			blockchain.chain[height] = block.New(BlockchainClockMock{}.GetTime(), height, otherAddr, &prevHash, []byte("Forged"))
+		}
+		t.Log("\tGiven chain validated by several workers")
+		{
+			var reports [][2]int
+			progress := func(checked int, total int) {
+				reports = append(reports, [2]int{checked, total})
+			}
+			errs, err := blockchain.ValidateChainContext(context.Background(), ValidationOptions{Workers: 4, Progress: progress})
+			serial, _ := blockchain.ValidateChainContext(context.Background(), ValidationOptions{Workers: 1})
+			if err != nil || len(errs) != 4 || len(errs) != len(serial) {
+				t.Fatal("\t\tShould find errors of both blocks, got: ", errs, err)
+			}
+			for i := range errs {
+				if errs[i].Error() != serial[i].Error() {
+					t.Fatal("\t\tShould report errors in height order, got: ", errs)
+				}
+			}
+			t.Log("\t\tShould report errors in height order")
+			if reports[0] != [2]int{0, 1000} {
+				t.Fatal("\t\tShould report the total before the first chunk, got: ", reports)
+			}
+			t.Log("\t\tShould report the total before the first chunk")
+			last := reports[len(reports)-1]
+			if len(reports) != 5 || last[0] != 1000 || last[1] != 1000 {
+				t.Fatal("\t\tShould report progress of every chunk, got: ", reports)
+			}
+			for i := 1; i < len(reports); i++ {
+				if reports[i][0] <= reports[i-1][0] {
+					t.Fatal("\t\tShould report growing progress, got: ", reports)
+				}
+			}
+			t.Log("\t\tShould report progress of every chunk")
+		}
+		t.Log("\tGiven cancelled context")
+		{
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			var reports [][2]int
+			progress := func(checked int, total int) {
+				reports = append(reports, [2]int{checked, total})
+			}
+			errs, err := blockchain.ValidateChainContext(ctx, ValidationOptions{Full: true, Progress: progress})
+			if err != context.Canceled || len(errs) != 0 {
+				t.Fatal("\t\tShould stop the validation, got: ", errs, err)
+			}
+			t.Log("\t\tShould stop the validation")
+			if len(reports) == 0 || reports[0] != [2]int{0, 1000} {
+				t.Fatal("\t\tShould report the total of cancelled validation, got: ", reports)
+			}
+			t.Log("\t\tShould report the total of cancelled validation")
+		}
+		t.Log("\tGiven validation job")
+		{
+			if _, err := blockchain.GetValidation("unknown"); err != UnknownJobErr {
+				t.Fatal("\t\tShould return UnknownJobErr, got: ", err)
+			}
+			job, err := blockchain.StartValidation(ValidationOptions{Full: true})
+			if err != nil || job.State != JobRunning {
+				t.Fatal("\t\tShould start the job, got: ", job, err)
+			}
+			deadline := time.Now().Add(10 * time.Second)
+			for job.State == JobRunning && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+				job, _ = blockchain.GetValidation(job.ID)
+			}
+			if job.State != JobDone || len(job.Errors) != 4 || job.Checked != 1000 || job.Total != 1000 {
+				t.Fatal("\t\tShould finish the job, got: ", job)
+			}
+			t.Log("\t\tShould finish the job")
+			job, err = blockchain.StartValidation(ValidationOptions{Full: true})
+			if err != nil {
+				t.Fatal("\t\tShould start another job, got: ", err)
+			}
+			if _, err := blockchain.StartValidation(ValidationOptions{Full: true}); err != JobRunningErr && err != nil {
+				t.Fatal("\t\tShould return JobRunningErr, got: ", err)
+			}
+			if _, err := blockchain.CancelValidation(job.ID); err != nil {
+				t.Fatal("\t\tShould cancel the job, got: ", err)
+			}
+			for job.State == JobRunning && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+				job, _ = blockchain.GetValidation(job.ID)
+			}
+			if job.State != JobCancelled && job.State != JobDone {
+				t.Fatal("\t\tShould stop the job, got: ", job)
+			}
+			t.Log("\t\tShould stop the job")
+		}
+	}
+}
+
+func TestValidateChainFindings(t *testing.T) {
+	t.Log("ValidateChain findings")
+	{
+		key := ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+		genesis := Genesis{ChainID: "starchain-test", Timestamp: 1592150000, Data: "Test network"}
+		clock := BlockchainClockMock{}
+		newChain := func() *Blockchain {
+			blockchain := New(clock, Config{SigningKey: key, Genesis: &genesis, MaxClockDrift: DefaultMaxClockDrift})
+			for i := 1; i < 4; i++ {
+				blockchain.AddBlock(testAddr, []byte(fmt.Sprintf("Star %d", i)))
+			}
+			return blockchain
+		}
+		forged := func(ts int64, height int, prevHash [sha256.Size]byte) *block.Block {
+			b := block.New(ts, height, testAddr, &prevHash, []byte("Forged"))
+			b.Sign(key)
+			return b
+		}
+		t.Log("\tGiven block with wrong height")
+		{
+			blockchain := newChain()
+			blockchain.chain[2] = forged(clock.GetTime(), 5, blockchain.chain[1].GetHash())
+			errs := blockchain.ValidateChainWith(ValidationOptions{Full: true})
+			if len(errs) != 2 {
+				t.Fatal("\t\tShould return 2 findings, got: ", errs)
+			}
+			gap, link := errs[0].(*Finding), errs[1].(*Finding)
+			if gap.Kind != FindingHeightGap || gap.Height != 2 || gap.Expected != "2" || gap.Actual != "5" {
+				t.Fatal("\t\tShould report the height gap, got: ", gap)
+			}
+			t.Log("\t\tShould report the height gap")
+			expected := fmt.Sprintf("%x", blockchain.chain[2].GetHash())
+			if link.Kind != FindingPrevHashMismatch || link.Height != 3 || link.Expected != expected || link.Actual == expected {
+				t.Fatal("\t\tShould report the broken link of the next block, got: ", link)
+			}
+			t.Log("\t\tShould report the broken link of the next block")
+		}
+		t.Log("\tGiven block older than the previous block")
+		{
+			blockchain := newChain()
+			blockchain.chain[3] = forged(clock.GetTime()-10, 3, blockchain.chain[2].GetHash())
+			errs := blockchain.ValidateChainWith(ValidationOptions{Full: true})
+			if len(errs) != 1 {
+				t.Fatal("\t\tShould return 1 finding, got: ", errs)
+			}
+			finding := errs[0].(*Finding)
+			if finding.Kind != FindingTimestampRegression || finding.Height != 3 || finding.Actual != strconv.FormatInt(clock.GetTime()-10, 10) {
+				t.Fatal("\t\tShould report the timestamp regression, got: ", finding)
+			}
+			t.Log("\t\tShould report the timestamp regression")
+		}
+		t.Log("\tGiven genesis block which is not the configured one")
+		{
+			blockchain := newChain()
+			expected := fmt.Sprintf("%x", blockchain.chain[0].GetHash())
+			other := block.New(genesis.Timestamp, 0, "", &[sha256.Size]byte{}, []byte("Other network"))
+			other.Sign(key)
+			blockchain.chain[0] = other
+			errs := blockchain.ValidateChainWith(ValidationOptions{Full: true})
+			if len(errs) != 2 {
+				t.Fatal("\t\tShould return 2 findings, got: ", errs)
+			}
+			finding := errs[0].(*Finding)
+			if finding.Kind != FindingBadGenesis || finding.Height != 0 || finding.Expected != expected || finding.Actual != fmt.Sprintf("%x", other.GetHash()) {
+				t.Fatal("\t\tShould report the bad genesis, got: ", finding)
+			}
+			if errs[1].(*Finding).Kind != FindingPrevHashMismatch {
+				t.Fatal("\t\tShould report the broken link of block 1, got: ", errs[1])
+			}
+			t.Log("\t\tShould report the bad genesis")
+		}
+		t.Log("\tGiven genesis block with owner and previous block")
+		{
+			blockchain := newChain()
+			prevHash := blockchain.chain[3].GetHash()
+			other := block.New(genesis.Timestamp, 0, testAddr, &prevHash, []byte(genesis.Data))
+			other.Sign(key)
+			blockchain.chain[0] = other
+			errs := blockchain.ValidateChainWith(ValidationOptions{Full: true})
+			kinds := []string{}
+			for _, err := range errs {
+				if err.(*Finding).Height == 0 {
+					kinds = append(kinds, err.(*Finding).Kind)
+				}
+			}
+			if strings.Join(kinds, ",") != "genesis-owner,genesis-prev-hash,bad-genesis" {
+				t.Fatal("\t\tShould report every violation separately, got: ", errs)
+			}
+			t.Log("\t\tShould report every violation separately")
+		}
+		t.Log("\tGiven star block without owner")
+		{
+			blockchain := newChain()
+			prevHash := blockchain.chain[2].GetHash()
+			b := block.New(clock.GetTime(), 3, "", &prevHash, []byte("Forged"))
+			b.Sign(key)
+			blockchain.chain[3] = b
+			errs := blockchain.ValidateChainWith(ValidationOptions{Full: true})
+			if len(errs) != 1 || errs[0].(*Finding).Kind != FindingEmptyOwner || errs[0].(*Finding).Height != 3 {
+				t.Fatal("\t\tShould report the empty owner, got: ", errs)
+			}
+			t.Log("\t\tShould report the empty owner")
+		}
+		t.Log("\tGiven block from the future")
+		{
+			blockchain := newChain()
+			blockchain.chain[3] = forged(clock.GetTime()+DefaultMaxClockDrift, 3, blockchain.chain[2].GetHash())
+			if errs := blockchain.ValidateChainWith(ValidationOptions{Full: true}); len(errs) != 0 {
+				t.Fatal("\t\tShould accept drift within MaxClockDrift, got: ", errs)
+			}
+			t.Log("\t\tShould accept drift within MaxClockDrift")
+			blockchain.chain[3] = forged(clock.GetTime()+DefaultMaxClockDrift+1, 3, blockchain.chain[2].GetHash())
+			errs := blockchain.ValidateChainWith(ValidationOptions{Full: true})
+			if len(errs) != 1 || errs[0].(*Finding).Kind != FindingClockDrift || errs[0].(*Finding).Height != 3 {
+				t.Fatal("\t\tShould report the clock drift, got: ", errs)
+			}
+			t.Log("\t\tShould report the clock drift")
+			for _, drift := range []int64{0, -1} {
+				blockchain.config = Config{SigningKey: key, Genesis: &genesis, MaxClockDrift: drift}.withDefaults()
+				blockchain.chain[3] = forged(clock.GetTime()+1, 3, blockchain.chain[2].GetHash())
+				errs := blockchain.ValidateChainWith(ValidationOptions{Full: true})
+				if len(errs) != 1 || errs[0].(*Finding).Kind != FindingClockDrift {
+					t.Fatal("\t\tShould report any drift when MaxClockDrift is not positive, got: ", drift, errs)
+				}
+				blockchain.chain[3] = forged(clock.GetTime(), 3, blockchain.chain[2].GetHash())
+				if errs := blockchain.ValidateChainWith(ValidationOptions{Full: true}); len(errs) != 0 {
+					t.Fatal("\t\tShould accept block at the node time, got: ", drift, errs)
+				}
+			}
+			t.Log("\t\tShould report any drift when MaxClockDrift is not positive")
+		}
+	}
+}
+
+func TestInclusionProof(t *testing.T) {
+	t.Log("InclusionProof")
+	{
+		config := Config{SigningKey: ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))}
+		store := NewMemoryStore()
+		blockchain, _ := Open(BlockchainClockMock{}, config, store)
+		roots := map[[sha256.Size]byte]bool{blockchain.GetAccumulatorRoot(): true}
+		for i := 1; i < 20; i++ {
+			blockchain.AddBlock(testAddr, []byte(fmt.Sprintf("Star %d", i)))
+			roots[blockchain.GetAccumulatorRoot()] = true
+		}
+		t.Log("\tGiven blocks added to the chain")
+		{
+			if len(roots) != 20 {
+				t.Fatal("\t\tShould update the root with every block")
+			}
+			t.Log("\t\tShould update the root with every block")
+			root := blockchain.GetAccumulatorRoot()
+			for height := 0; height < 20; height++ {
+				proof, err := blockchain.GetInclusionProof(height)
+				if err != nil || proof.Root != root || proof.BlockHash != blockchain.chain[height].GetHash() {
+					t.Fatal("\t\tShould return proof of the block, got: ", proof, err)
+				}
+				if err := mmr.Verify(root, blockchain.chain[height].GetHash(), proof.Proof); err != nil {
+					t.Fatalf("\t\tShould verify block %d, got err: %v", height, err)
+				}
+			}
+			t.Log("\t\tShould verify every block")
+			if _, err := blockchain.GetInclusionProof(20); err == nil {
+				t.Fatal("\t\tShould not return proof of missing block")
+			}
+			t.Log("\t\tShould not return proof of missing block")
+		}
+		t.Log("\tGiven chain loaded from the store")
+		{
+			reloaded, err := Open(BlockchainClockMock{}, config, store)
+			if err != nil || reloaded.GetAccumulatorRoot() != blockchain.GetAccumulatorRoot() {
+				t.Fatal("\t\tShould rebuild the same root, got err: ", err)
+			}
+			t.Log("\t\tShould rebuild the same root")
+		}
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	t.Log("CheckFile")
+	{
+		dir, err := ioutil.TempDir("", "starchain-fsck")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		config := Config{SigningKey: ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))}
+		source := New(BlockchainClockMock{}, config)
+		var payloads [][]byte
+		for i := 0; i < 6; i++ {
+			if i > 0 {
+				source.AddBlock(testAddr, []byte(fmt.Sprintf("Star %d", i)))
+			}
+			payload, _ := source.chain[i].MarshalBinary()
+			payloads = append(payloads, payload)
+		}
+		// write fn stores payloads as records, the payload at the height
+		// is changed by tamper, its record is framed before or after that
+		write := func(name string, height int, tamper func([]byte) []byte, reframe bool) string {
+			path := filepath.Join(dir, name)
+			var file []byte
+			for i, payload := range payloads {
+				record := frameRecord(payload)
+				if i == height {
+					payload = tamper(append([]byte{}, payload...))
+					if reframe {
+						record = frameRecord(payload)
+					} else {
+						record = append(record[:recordHeaderSize], payload...)
+					}
+				}
+				file = append(file, record...)
+			}
+			if err := ioutil.WriteFile(path, file, 0644); err != nil {
+				t.Fatal(err)
+			}
+			return path
+		}
+		renameStar := func(payload []byte) []byte {
+			return bytes.Replace(payload, []byte(hex.EncodeToString([]byte("Star 3"))), []byte(hex.EncodeToString([]byte("Star X"))), 1)
+		}
+		t.Log("\tGiven intact file")
+		{
+			path := write("intact.dat", -1, nil, false)
+			report, err := CheckFile(path)
+			info, _ := os.Stat(path)
+			if err != nil || len(report.Findings) != 0 || report.Blocks != 6 || report.GoodBlocks != 6 || report.GoodSize != info.Size() {
+				t.Fatal("\t\tShould report no findings, got: ", report, err)
+			}
+			t.Log("\t\tShould report no findings")
+		}
+		t.Log("\tGiven block changed with valid checksum")
+		{
+			path := write("tampered.dat", 3, renameStar, true)
+			report, err := CheckFile(path)
+			if err != nil || report.Blocks != 6 || len(report.Findings) != 2 {
+				t.Fatal("\t\tShould report 2 findings, got: ", report, err)
+			}
+			if report.Findings[0].Kind != FindingHashMismatch || report.Findings[0].Height != 3 ||
+				report.Findings[1].Kind != FindingPrevHashMismatch || report.Findings[1].Height != 4 {
+				t.Fatal("\t\tShould report the changed block and the broken link, got: ", report.Findings)
+			}
+			t.Log("\t\tShould report the changed block and the broken link")
+			size := int64(0)
+			for _, payload := range payloads[:3] {
+				size += recordHeaderSize + int64(len(payload))
+			}
+			if report.GoodBlocks != 3 || report.GoodSize != size {
+				t.Fatal("\t\tShould keep blocks before the first finding, got: ", report.GoodBlocks, report.GoodSize)
+			}
+			t.Log("\t\tShould keep blocks before the first finding")
+			original, _ := ioutil.ReadFile(path)
+			backupPath := path + ".bak"
+			if err := RepairFile(path, backupPath, report); err != nil {
+				t.Fatal("\t\tShould repair the file, got err: ", err)
+			}
+			backup, _ := ioutil.ReadFile(backupPath)
+			if !bytes.Equal(backup, original) {
+				t.Fatal("\t\tShould write the backup")
+			}
+			t.Log("\t\tShould write the backup")
+			store, err := OpenFileStore(path)
+			if err != nil {
+				t.Fatal("\t\tShould open repaired file, got err: ", err)
+			}
+			repaired, err := Open(BlockchainClockMock{}, config, store)
+			if err != nil || repaired.GetChainHeight() != 3 {
+				t.Fatal("\t\tShould load blocks before the first finding, got: ", err)
+			}
+			repaired.Close()
+			t.Log("\t\tShould load blocks before the first finding")
+			if err := RepairFile(path, backupPath, report); err != FileChangedErr {
+				t.Fatal("\t\tShould refuse repair of changed file, got err: ", err)
+			}
+			t.Log("\t\tShould refuse repair of changed file")
+		}
+		t.Log("\tGiven record with wrong checksum")
+		{
+			path := write("corrupted.dat", 2, func(payload []byte) []byte {
+				return bytes.Replace(payload, []byte(hex.EncodeToString([]byte("Star 2"))), []byte(hex.EncodeToString([]byte("Star X"))), 1)
+			}, false)
+			report, err := CheckFile(path)
+			if err != nil || report.Blocks != 2 || len(report.Findings) != 1 || report.GoodBlocks != 2 {
+				t.Fatal("\t\tShould stop at the corrupted record, got: ", report, err)
+			}
+			if report.Findings[0].Kind != FindingCorruptRecord || report.Findings[0].Height != 2 {
+				t.Fatal("\t\tShould report the corrupted record, got: ", report.Findings)
+			}
+			t.Log("\t\tShould report the corrupted record")
+			if err := RepairFile(path, path, report); err == nil {
+				t.Fatal("\t\tShould not overwrite existing backup")
+			}
+			t.Log("\t\tShould not overwrite existing backup")
+		}
+		t.Log("\tGiven damaged genesis block")
+		{
+			path := write("genesis.dat", 0, func(payload []byte) []byte { return payload[:10] }, false)
+			report, _ := CheckFile(path)
+			if report.GoodBlocks != 0 {
+				t.Fatal("\t\tShould keep no block, got: ", report)
+			}
+			if err := RepairFile(path, path+".bak", report); err != NothingToKeepErr {
+				t.Fatal("\t\tShould refuse the repair, got err: ", err)
+			}
+			t.Log("\t\tShould refuse the repair")
+		}
+	}
+}
+
+func TestProofOfWork(t *testing.T) {
+	t.Log("ProofOfWork")
+	{
+		key := ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+		pow := PoWConfig{InitialDifficulty: 16, RetargetInterval: 4, BlockInterval: 60}
+		difficulties := func(blockchain *Blockchain) []uint64 {
+			var result []uint64
+			for _, b := range blockchain.chain {
+				result = append(result, b.GetDifficulty())
+			}
+			return result
+		}
+		t.Log("\tGiven blocks mined faster than the block interval")
+		{
+			blockchain := New(BlockchainClockMock{}, Config{SigningKey: key, PoW: &pow})
+			for i := 1; i < 10; i++ {
+				blockchain.AddBlock(testAddr, []byte(fmt.Sprintf("Star %d", i)))
+			}
+			expected := []uint64{0, 16, 16, 16, 16, 16, 16, 16, 64, 64}
+			if fmt.Sprint(difficulties(blockchain)) != fmt.Sprint(expected) {
+				t.Fatal("\t\tShould raise the difficulty at the retarget, got: ", difficulties(blockchain))
+			}
+			t.Log("\t\tShould raise the difficulty at the retarget")
+			for _, b := range blockchain.chain[1:] {
+				if !b.MeetsDifficulty() {
+					t.Fatal("\t\tShould mine every block, got: ", b.GetHash())
+				}
+			}
+			if errs := blockchain.ValidateChainWith(ValidationOptions{Full: true}); len(errs) != 0 {
+				t.Fatal("\t\tShould validate the chain, got: ", errs)
+			}
+			t.Log("\t\tShould mine every block")
+			prevHash := blockchain.chain[4].GetHash()
+			instant := block.New(BlockchainClockMock{}.GetTime(), 5, testAddr, &prevHash, []byte("Instant"))
+			instant.Sign(key)
+			blockchain.chain[5] = instant
+			errs := blockchain.ValidateChainWith(ValidationOptions{Full: true})
+			kinds := []string{}
+			for _, err := range errs {
+				kinds = append(kinds, fmt.Sprintf("%s@%d", err.(*Finding).Kind, err.(*Finding).Height))
+			}
+			if strings.Join(kinds, ",") != "bad-difficulty@5,insufficient-work@5,prev-hash-mismatch@6" {
+				t.Fatal("\t\tShould report block which was not mined, got: ", errs)
+			}
+			t.Log("\t\tShould report block which was not mined")
+		}
+		t.Log("\tGiven blocks mined slower than the block interval")
+		{
+			clock := &MutableClockMock{ts: BlockchainClockMock{}.GetTime()}
+			blockchain := New(clock, Config{SigningKey: key, PoW: &pow})
+			for i := 1; i < 10; i++ {
+				clock.ts += 240
+				blockchain.AddBlock(testAddr, []byte(fmt.Sprintf("Star %d", i)))
+			}
+			if d := difficulties(blockchain); d[7] != 16 || d[8] != 4 || d[9] != 4 {
+				t.Fatal("\t\tShould lower the difficulty at the retarget, got: ", d)
+			}
+			if errs := blockchain.ValidateChainWith(ValidationOptions{Full: true}); len(errs) != 0 {
+				t.Fatal("\t\tShould validate the chain, got: ", errs)
+			}
+			t.Log("\t\tShould lower the difficulty at the retarget")
+		}
+		t.Log("\tGiven proof-of-work enabled on existing chain")
+		{
+			store := NewMemoryStore()
+			blockchain, _ := Open(BlockchainClockMock{}, Config{SigningKey: key}, store)
+			blockchain.AddBlock(testAddr, []byte("Instant"))
+			activated := pow
+			activated.ActivationHeight = 2
+			blockchain, err := Open(BlockchainClockMock{}, Config{SigningKey: key, PoW: &activated}, store)
+			if err != nil {
+				t.Fatal("\t\tShould accept blocks before activation, got err: ", err)
+			}
+			star := blockchain.AddBlock(testAddr, []byte("Mined"))
+			if star.GetDifficulty() != 16 || len(blockchain.ValidateChainWith(ValidationOptions{Full: true})) != 0 {
+				t.Fatal("\t\tShould mine blocks from the activation height")
+			}
+			t.Log("\t\tShould mine blocks from the activation height")
+		}
+		t.Log("\tGiven blockchain closed while mining")
+		{
+			hard := PoWConfig{InitialDifficulty: 1 << 62}
+			blockchain := New(BlockchainClockMock{}, Config{SigningKey: key, PoW: &hard})
+			result := make(chan error)
+			go func() {
+				_, err := blockchain.addBlock(testAddr, []byte("Never mined"), "", "")
+				result <- err
+			}()
+			time.Sleep(10 * time.Millisecond)
+			blockchain.Close()
+			select {
+			case err := <-result:
+				if err != MiningCancelledErr {
+					t.Fatal("\t\tShould return MiningCancelledErr, got: ", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("\t\tShould stop mining")
+			}
+			if blockchain.GetChainHeight() != 1 {
+				t.Fatal("\t\tShould not add the block")
+			}
+			t.Log("\t\tShould stop mining")
+		}
+		t.Log("\tGiven mining stopped before close")
+		{
+			hard := PoWConfig{InitialDifficulty: 1 << 62, ActivationHeight: 2}
+			store := NewMemoryStore()
+			blockchain, _ := Open(BlockchainClockMock{}, Config{SigningKey: key, PoW: &hard}, store)
+			blockchain.StopMining()
+			if _, err := blockchain.addBlock(testAddr, []byte("Instant"), "", ""); err != nil {
+				t.Fatal("\t\tShould add blocks which are not mined, got err: ", err)
+			}
+			if _, err := blockchain.addBlock(testAddr, []byte("Never mined"), "", ""); err != MiningCancelledErr {
+				t.Fatal("\t\tShould return MiningCancelledErr, got: ", err)
+			}
+			if stored, _ := store.Load(); len(stored) != 2 {
+				t.Fatal("\t\tShould keep the store open, got blocks: ", len(stored))
+			}
+			t.Log("\t\tShould cancel mining only")
+			if err := blockchain.Close(); err != nil {
+				t.Fatal("\t\tShould close the blockchain, got err: ", err)
+			}
+		}
+	}
+}
+
+func TestTreeHeads(t *testing.T) {
+	t.Log("TreeHeads")
+	{
+		key := ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+		pubKey := key.Public().(ed25519.PublicKey)
+		t.Log("\tGiven tree heads published while the chain grows")
+		{
+			blockchain := New(BlockchainClockMock{}, Config{SigningKey: key})
+			if _, err := blockchain.GetLatestTreeHead(); err != NoTreeHeadErr {
+				t.Fatal("\t\tShould return NoTreeHeadErr before publication, got: ", err)
+			}
+			t.Log("\t\tShould return NoTreeHeadErr before publication")
+			older, _ := blockchain.PublishTreeHead()
+			for i := 1; i <= 5; i++ {
+				blockchain.AddBlock(testAddr, []byte(fmt.Sprintf("Star %d", i)))
+			}
+			newer, _ := blockchain.PublishTreeHead()
+			if again, _ := blockchain.PublishTreeHead(); !bytes.Equal(again.Signature, newer.Signature) || len(blockchain.GetTreeHeads()) != 2 {
+				t.Fatal("\t\tShould not publish the same head twice, got: ", blockchain.GetTreeHeads())
+			}
+			t.Log("\t\tShould not publish the same head twice")
+			if latest, _ := blockchain.GetLatestTreeHead(); latest.Height != 5 || latest.HeadHash != blockchain.chain[5].GetHash() ||
+				latest.Root != blockchain.GetAccumulatorRoot() {
+				t.Fatal("\t\tShould commit to the head of the chain, got: ", latest)
+			}
+			t.Log("\t\tShould commit to the head of the chain")
+			if !older.Verify(pubKey, DefaultChainID) || newer.Verify(pubKey, "other-chain") {
+				t.Fatal("\t\tShould verify the signature for the chain only")
+			}
+			t.Log("\t\tShould verify the signature for the chain only")
+			consistency, err := blockchain.GetTreeHeadConsistency(0, 5)
+			if err != nil || consistency.OldRoot != older.Root || consistency.NewRoot != newer.Root {
+				t.Fatal("\t\tShould return the proof between the roots, got: ", consistency, err)
+			}
+			if err := CheckTreeHeadConsistency(older, newer, consistency, pubKey, DefaultChainID); err != nil {
+				t.Fatal("\t\tShould confirm the newer head extends the older one, got err: ", err)
+			}
+			t.Log("\t\tShould confirm the newer head extends the older one")
+			if _, err := blockchain.GetTreeHeadConsistency(5, 0); err != TreeHeadOrderErr {
+				t.Fatal("\t\tShould return TreeHeadOrderErr, got: ", err)
+			}
+			if _, err := blockchain.GetTreeHeadConsistency(0, 6); err == nil {
+				t.Fatal("\t\tShould return error for height above the chain")
+			}
+			t.Log("\t\tShould return error for heights above the chain")
+			tampered := newer
+			tampered.Root[0] ^= 1
+			if err := CheckTreeHeadConsistency(older, tampered, consistency, pubKey, DefaultChainID); err != TreeHeadSigErr {
+				t.Fatal("\t\tShould reject tampered tree head, got: ", err)
+			}
+			t.Log("\t\tShould reject tampered tree head")
+			forged := newer
+			forged.HeadHash = blockchain.chain[3].GetHash()
+			forged.Signature = ed25519.Sign(key, forged.signedData(DefaultChainID))
+			if err := CheckTreeHeadConsistency(older, forged, consistency, pubKey, DefaultChainID); err != TreeHeadMismatchErr {
+				t.Fatal("\t\tShould reject signed head hash which is not the last leaf of the root, got: ", err)
+			}
+			t.Log("\t\tShould reject signed head hash which is not the last leaf of the root")
+		}
+		t.Log("\tGiven tree head published before restart")
+		{
+			store := NewMemoryStore()
+			blockchain, _ := Open(BlockchainClockMock{}, Config{SigningKey: key}, store)
+			blockchain.AddBlock(testAddr, []byte("Star 1"))
+			older, _ := blockchain.PublishTreeHead()
+			blockchain.Close()
+			restarted, err := Open(BlockchainClockMock{}, Config{SigningKey: key}, store)
+			if err != nil {
+				t.Fatal("\t\tShould reopen the chain, got err: ", err)
+			}
+			if heads := restarted.GetTreeHeads(); len(heads) != 1 || !bytes.Equal(heads[0].Signature, older.Signature) {
+				t.Fatal("\t\tShould reload the published head, got: ", heads)
+			}
+			t.Log("\t\tShould reload the published head")
+			restarted.AddBlock(testAddr, []byte("Star 2"))
+			newer, _ := restarted.PublishTreeHead()
+			consistency, err := restarted.GetTreeHeadConsistency(older.Height, newer.Height)
+			if err != nil {
+				t.Fatal("\t\tShould return the proof, got err: ", err)
+			}
+			if err := CheckTreeHeadConsistency(older, newer, consistency, pubKey, DefaultChainID); err != nil {
+				t.Fatal("\t\tShould confirm the head published before restart, got err: ", err)
+			}
+			t.Log("\t\tShould confirm the head published before restart")
+		}
+		t.Log("\tGiven tree heads published to FileStore")
+		{
+			dir, err := ioutil.TempDir("", "starchain-heads")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "chain.dat")
+			open := func(config Config) (*Blockchain, error) {
+				store, err := OpenFileStore(path)
+				if err != nil {
+					return nil, err
+				}
+				return Open(BlockchainClockMock{}, config, store)
+			}
+			blockchain, err := open(Config{SigningKey: key})
+			if err != nil {
+				t.Fatal("\t\tShould create the store, got err: ", err)
+			}
+			blockchain.PublishTreeHead()
+			blockchain.AddBlock(testAddr, []byte("Star 1"))
+			blockchain.PublishTreeHead()
+			published := blockchain.GetTreeHeads()
+			blockchain.Close()
+			blockchain, err = open(Config{SigningKey: key})
+			if err != nil {
+				t.Fatal("\t\tShould reopen the store, got err: ", err)
+			}
+			heads := blockchain.GetTreeHeads()
+			if len(heads) != 2 || heads[1].Root != published[1].Root || !bytes.Equal(heads[1].Signature, published[1].Signature) {
+				t.Fatal("\t\tShould reload published heads, got: ", heads)
+			}
+			if latest, err := blockchain.GetLatestTreeHead(); err != nil || latest.Height != 1 || !latest.Verify(pubKey, DefaultChainID) {
+				t.Fatal("\t\tShould serve the latest head after restart, got: ", latest, err)
+			}
+			blockchain.Close()
+			t.Log("\t\tShould reload published heads")
+			forged := published[1]
+			forged.Root[0] ^= 1
+			forged.Signature = ed25519.Sign(key, forged.signedData(DefaultChainID))
+			payload, _ := encodeTreeHead(forged)
+			file, _ := os.OpenFile(path+TreeHeadsSuffix, os.O_WRONLY|os.O_APPEND, 0644)
+			file.Write(frameRecord(payload))
+			file.Close()
+			if _, err := open(Config{SigningKey: key}); err == nil || !strings.HasPrefix(err.Error(), InvalidStoreErr.Error()) ||
+				!strings.Contains(err.Error(), TreeHeadMismatchErr.Error()) {
+				t.Fatal("\t\tShould reject head which does not match the chain, got: ", err)
+			}
+			t.Log("\t\tShould reject head which does not match the chain")
+		}
+		t.Log("\tGiven history rewritten after the older head")
+		{
+			blockchain := New(BlockchainClockMock{}, Config{SigningKey: key})
+			blockchain.AddBlock(testAddr, []byte("Star 1"))
+			older, _ := blockchain.PublishTreeHead()
+			rewritten := New(BlockchainClockMock{}, Config{SigningKey: key})
+			rewritten.chain = blockchain.chain[:1]
+			rewritten.reindex()
+			for i := 1; i <= 5; i++ {
+				rewritten.AddBlock(testAddr, []byte(fmt.Sprintf("Rewritten star %d", i)))
+			}
+			newer, _ := rewritten.PublishTreeHead()
+			consistency, _ := rewritten.GetTreeHeadConsistency(older.Height, newer.Height)
+			if err := CheckTreeHeadConsistency(older, newer, consistency, pubKey, DefaultChainID); err != mmr.RootMismatchErr {
+				t.Fatal("\t\tShould prove the history was rewritten, got: ", err)
+			}
+			t.Log("\t\tShould prove the history was rewritten")
+		}
+		t.Log("\tGiven tree head interval")
+		{
+			blockchain := New(BlockchainClockMock{}, Config{SigningKey: key, TreeHeadInterval: 60})
+			deadline := time.Now().Add(5 * time.Second)
+			for len(blockchain.GetTreeHeads()) == 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			blockchain.Close()
+			if heads := blockchain.GetTreeHeads(); len(heads) != 1 || heads[0].Height != 0 {
+				t.Fatal("\t\tShould publish the first tree head on open, got: ", heads)
+			}
+			t.Log("\t\tShould publish the first tree head on open")
+		}
+	}
+}
+
+func TestAppendBlocks(t *testing.T) {
+	t.Log("AppendBlocks")
+	{
+		key := ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))
+		leader := New(BlockchainClockMock{}, Config{SigningKey: key})
+		for i := 1; i <= 5; i++ {
+			leader.AddBlock(testAddr, []byte(fmt.Sprintf("Star %d", i)))
+		}
+		t.Log("\tGiven follower seeded with the genesis block of the leader")
+		{
+			store := NewMemoryStore()
+			genesis, _ := leader.GetBlocksSince(0, 1)
+			if err := Seed(store, genesis[0], Config{}); err == nil || !strings.HasPrefix(err.Error(), ReplicationErr.Error()) {
+				t.Fatal("\t\tShould return ReplicationErr for untrusted producer, got: ", err)
+			}
+			if stored, _ := store.Load(); len(stored) != 0 {
+				t.Fatal("\t\tShould not write genesis block of untrusted producer")
+			}
+			t.Log("\t\tShould reject genesis block of untrusted producer")
+			other := Config{SigningKey: key, Genesis: &Genesis{ChainID: "starchain-test", Timestamp: 1592150000, Data: "Test network"}}
+			if err := Seed(store, genesis[0], other); err == nil || !strings.HasPrefix(err.Error(), GenesisMismatchErr.Error()) {
+				t.Fatal("\t\tShould return GenesisMismatchErr, got: ", err)
+			}
+			t.Log("\t\tShould reject genesis block of other network")
+			config := Config{TrustedKeys: []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}}
+			if err := Seed(store, genesis[0], config); err != nil {
+				t.Fatal("\t\tShould seed the empty store, got err: ", err)
+			}
+			if err := Seed(store, genesis[0], config); err != NonEmptySeedErr {
+				t.Fatal("\t\tShould return NonEmptySeedErr, got: ", err)
+			}
+			t.Log("\t\tShould seed the empty store only")
+			follower, err := Open(BlockchainClockMock{}, config, store)
+			if err != nil || follower.GetChainHeight() != 1 {
+				t.Fatal("\t\tShould open the seeded store, got err: ", err)
+			}
+			blocks, _ := leader.GetBlocksSince(1, 3)
+			if len(blocks) != 3 || blocks[0].GetHeight() != 1 {
+				t.Fatal("\t\tShould return blocks from the height, got: ", blocks)
+			}
+			if appended, err := follower.AppendBlocks(blocks); err != nil || appended != 3 {
+				t.Fatal("\t\tShould append linked blocks, got: ", appended, err)
+			}
+			t.Log("\t\tShould append linked blocks")
+			blocks, _ = leader.GetBlocksSince(2, 0)
+			if appended, err := follower.AppendBlocks(blocks); err != nil || appended != 2 || follower.GetChainHeight() != 6 {
+				t.Fatal("\t\tShould skip blocks the chain holds, got: ", appended, err)
+			}
+			t.Log("\t\tShould skip blocks the chain holds")
+			if blocks, _ := leader.GetBlocksSince(6, 0); len(blocks) != 0 {
+				t.Fatal("\t\tShould return no blocks at the chain height, got: ", blocks)
+			}
+			if _, err := leader.GetBlocksSince(7, 0); err == nil {
+				t.Fatal("\t\tShould return error above the chain height")
+			}
+			t.Log("\t\tShould return no blocks at the chain height")
+		}
+		t.Log("\tGiven blocks which do not extend the chain")
+		{
+			follower := New(BlockchainClockMock{}, Config{SigningKey: key})
+			follower.AddBlock(testAddr, []byte("Other star"))
+			blocks, _ := leader.GetBlocksSince(2, 0)
+			if appended, err := follower.AppendBlocks(blocks); appended != 0 || err == nil || !strings.HasPrefix(err.Error(), ReplicationErr.Error()) {
+				t.Fatal("\t\tShould reject blocks of another history, got: ", appended, err)
+			}
+			t.Log("\t\tShould reject blocks of another history")
+			stranger := New(BlockchainClockMock{}, Config{})
+			blocks, _ = leader.GetBlocksSince(1, 0)
+			if appended, err := stranger.AppendBlocks(blocks); appended != 0 || err == nil {
+				t.Fatal("\t\tShould reject blocks of untrusted producer, got: ", appended, err)
+			}
+			t.Log("\t\tShould reject blocks of untrusted producer")
+		}
 	}
 }
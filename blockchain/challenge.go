@@ -0,0 +1,272 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChallengeVersion is the version of the structured challenge format
+const ChallengeVersion = "1"
+
+// LegacySuffix ends every legacy addr:ts:starRegistry message
+const LegacySuffix = "starRegistry"
+
+// Structured challenge is modelled after EIP-4361 (Sign-In with Ethereum):
+//
+//	${domain} wants you to prove ownership of the address:
+//	${address}
+//
+//	Register a star in the Starchain registry.
+//
+//	Version: 1
+//	Chain ID: ${chainId}
+//	Nonce: ${nonce}
+//	Issued At: ${issuedAt}
+//	Expiration Time: ${expirationTime}
+//	Star RA: ${ra}
+//	Star Dec: ${dec}
+//
+// Times are RFC3339 UTC, the nonce is lower case hex and star coordinates
+// are optional (both or none).
+const (
+	challengeHeaderSuffix = " wants you to prove ownership of the address:"
+	challengeStatement    = "Register a star in the Starchain registry."
+	challengeTimeLayout   = "2006-01-02T15:04:05Z"
+)
+
+var (
+	MalformedMsgErr   = errors.New("Message is malformed")
+	LegacyMsgErr      = errors.New("Legacy addr:ts:starRegistry messages are not accepted")
+	WrongDomainErr    = errors.New("Message was issued for a different domain or chain")
+	StarMismatchErr   = errors.New("Star coordinates do not match the message")
+	LegacyStarErr     = errors.New("Legacy messages can not carry star coordinates")
+	EmptyStarCoordErr = errors.New("Star coordinates require both RA and Dec")
+)
+
+// StarCoordinates struct contains right ascension and declination
+// of the star the challenge is bound to.
+type StarCoordinates struct {
+	RA  string `json:"ra"`
+	Dec string `json:"dec"`
+}
+
+// ChallengeMessage struct is the parsed content of a challenge message.
+// Legacy messages carry only Address, IssuedAt and optionally Nonce.
+type ChallengeMessage struct {
+	Legacy         bool
+	Domain         string
+	Address        string
+	ChainID        string
+	Nonce          string
+	IssuedAt       int64
+	ExpirationTime int64
+	Star           *StarCoordinates
+}
+
+// String method formats the message, it is the inverse of ParseChallenge
+func (m ChallengeMessage) String() string {
+	if m.Legacy {
+		msg := fmt.Sprintf("%s:%d:%s", m.Address, m.IssuedAt, LegacySuffix)
+		if m.Nonce != "" {
+			msg += ":" + m.Nonce
+		}
+		return msg
+	}
+	var sb strings.Builder
+	sb.WriteString(m.Domain + challengeHeaderSuffix + "\n")
+	sb.WriteString(m.Address + "\n")
+	sb.WriteString("\n")
+	sb.WriteString(challengeStatement + "\n")
+	sb.WriteString("\n")
+	sb.WriteString("Version: " + ChallengeVersion + "\n")
+	sb.WriteString("Chain ID: " + m.ChainID + "\n")
+	sb.WriteString("Nonce: " + m.Nonce + "\n")
+	sb.WriteString("Issued At: " + formatChallengeTime(m.IssuedAt) + "\n")
+	sb.WriteString("Expiration Time: " + formatChallengeTime(m.ExpirationTime))
+	if m.Star != nil {
+		sb.WriteString("\nStar RA: " + m.Star.RA)
+		sb.WriteString("\nStar Dec: " + m.Star.Dec)
+	}
+	return sb.String()
+}
+
+// ParseChallenge fn strictly parses a structured challenge message.
+// When allowLegacy is set addr:ts:starRegistry[:nonce] messages are
+// accepted as well, otherwise they are rejected with LegacyMsgErr.
+func ParseChallenge(msg string, allowLegacy bool) (ChallengeMessage, error) {
+	if !strings.Contains(msg, "\n") {
+		if !isLegacyChallenge(msg) {
+			return ChallengeMessage{}, malformed("unknown message format")
+		}
+		if !allowLegacy {
+			return ChallengeMessage{}, LegacyMsgErr
+		}
+		return parseLegacyChallenge(msg)
+	}
+	var (
+		result ChallengeMessage
+		err    error
+	)
+	lines := strings.Split(msg, "\n")
+	if len(lines) != 10 && len(lines) != 12 {
+		return result, malformed("unexpected number of lines")
+	}
+	if !strings.HasSuffix(lines[0], challengeHeaderSuffix) {
+		return result, malformed("missing header")
+	}
+	result.Domain = strings.TrimSuffix(lines[0], challengeHeaderSuffix)
+	if !isToken(result.Domain) {
+		return result, malformed("invalid domain")
+	}
+	result.Address = lines[1]
+	if !isToken(result.Address) {
+		return result, malformed("invalid address")
+	}
+	if lines[2] != "" || lines[3] != challengeStatement || lines[4] != "" {
+		return result, malformed("invalid statement")
+	}
+	if version, err := field(lines[5], "Version"); err != nil {
+		return result, err
+	} else if version != ChallengeVersion {
+		return result, malformed("unsupported version " + version)
+	}
+	if result.ChainID, err = field(lines[6], "Chain ID"); err != nil {
+		return result, err
+	}
+	if !isToken(result.ChainID) {
+		return result, malformed("invalid chain ID")
+	}
+	if result.Nonce, err = field(lines[7], "Nonce"); err != nil {
+		return result, err
+	}
+	if !isNonce(result.Nonce) {
+		return result, malformed("invalid nonce")
+	}
+	if result.IssuedAt, err = timeField(lines[8], "Issued At"); err != nil {
+		return result, err
+	}
+	if result.ExpirationTime, err = timeField(lines[9], "Expiration Time"); err != nil {
+		return result, err
+	}
+	if result.ExpirationTime <= result.IssuedAt {
+		return result, malformed("expiration time before issued at")
+	}
+	if len(lines) == 12 {
+		var star StarCoordinates
+		if star.RA, err = field(lines[10], "Star RA"); err != nil {
+			return result, err
+		}
+		if star.Dec, err = field(lines[11], "Star Dec"); err != nil {
+			return result, err
+		}
+		if star.RA == "" || star.Dec == "" {
+			return result, EmptyStarCoordErr
+		}
+		result.Star = &star
+	}
+	return result, nil
+}
+
+// isLegacyChallenge fn reports whether msg looks like addr:ts:starRegistry
+func isLegacyChallenge(msg string) bool {
+	return strings.HasSuffix(msg, ":"+LegacySuffix) || strings.Contains(msg, ":"+LegacySuffix+":")
+}
+
+// parseLegacyChallenge fn parses addr:ts:starRegistry[:nonce] message.
+// It splits the message from the right, so the address may contain colons.
+func parseLegacyChallenge(msg string) (ChallengeMessage, error) {
+	result := ChallengeMessage{Legacy: true}
+	rest := msg
+	if i := strings.LastIndex(rest, ":"); i >= 0 && rest[i+1:] != LegacySuffix {
+		result.Nonce = rest[i+1:]
+		rest = rest[:i]
+		if !isNonce(result.Nonce) {
+			return result, malformed("invalid nonce")
+		}
+	}
+	if !strings.HasSuffix(rest, ":"+LegacySuffix) {
+		return result, malformed("missing " + LegacySuffix)
+	}
+	rest = strings.TrimSuffix(rest, ":"+LegacySuffix)
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 {
+		return result, malformed("missing address or timestamp")
+	}
+	result.Address, rest = rest[:i], rest[i+1:]
+	if !isToken(result.Address) {
+		return result, malformed("invalid address")
+	}
+	if len(rest) < 10 || !isDigits(rest) {
+		return result, malformed(fmt.Sprintf("timestamp %s is not a number", rest))
+	}
+	ts, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return result, malformed(fmt.Sprintf("timestamp %s is not a number", rest))
+	}
+	result.IssuedAt = ts
+	return result, nil
+}
+
+// matchesStar fn checks whether JSON star data contains the coordinates
+func matchesStar(coords StarCoordinates, starData []byte) bool {
+	var star StarCoordinates
+	if err := json.Unmarshal(starData, &star); err != nil {
+		return false
+	}
+	return star == coords
+}
+
+func field(line string, name string) (string, error) {
+	prefix := name + ": "
+	if !strings.HasPrefix(line, prefix) {
+		return "", malformed("missing " + name)
+	}
+	return strings.TrimPrefix(line, prefix), nil
+}
+
+func timeField(line string, name string) (int64, error) {
+	value, err := field(line, name)
+	if err != nil {
+		return 0, err
+	}
+	t, err := time.Parse(challengeTimeLayout, value)
+	if err != nil || formatChallengeTime(t.Unix()) != value {
+		return 0, malformed("invalid " + name)
+	}
+	return t.Unix(), nil
+}
+
+func formatChallengeTime(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format(challengeTimeLayout)
+}
+
+// isToken fn reports whether s is non empty and has no whitespace
+func isToken(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " \t\r\n")
+}
+
+func isNonce(s string) bool {
+	if len(s) != 2*NonceSize || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func malformed(reason string) error {
+	return fmt.Errorf("%w: %s", MalformedMsgErr, reason)
+}
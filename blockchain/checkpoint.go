@@ -0,0 +1,66 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"github.com/starchain/block"
+)
+
+// Checkpoint struct is a block up to which the chain was found valid.
+// Later validations start after the last checkpoint, unless Full is set.
+// Proofs tells whether ownership proofs were verified as well.
+type Checkpoint struct {
+	Height int
+	Hash   [sha256.Size]byte
+	Proofs bool
+}
+
+// maxCheckpoints is the number of checkpoints kept, the oldest one is
+// dropped when a new one is recorded. More than one is kept, so the last
+// checkpoint with verified proofs survives validations without proofs.
+const maxCheckpoints = 8
+
+// GetCheckpoints method returns the last maxCheckpoints checkpoints
+// in the order they were recorded
+func (b *Blockchain) GetCheckpoints() []Checkpoint {
+	b.checkpointMutex.Lock()
+	defer b.checkpointMutex.Unlock()
+	return append([]Checkpoint{}, b.checkpoints...)
+}
+
+// startHeight method returns height validation of the chain starts at:
+// the block after the last checkpoint covering the options, 0 when
+// there is none, Full is set or the checkpointed block is not in the chain
+func (b *Blockchain) startHeight(chain []*block.Block, opts ValidationOptions) int {
+	if opts.Full {
+		return 0
+	}
+	b.checkpointMutex.Lock()
+	defer b.checkpointMutex.Unlock()
+	for i := len(b.checkpoints) - 1; i >= 0; i-- {
+		cp := b.checkpoints[i]
+		if opts.VerifyProofs && !cp.Proofs {
+			continue
+		}
+		if cp.Height < len(chain) && chain[cp.Height].GetHash() == cp.Hash {
+			return cp.Height + 1
+		}
+		return 0
+	}
+	return 0
+}
+
+// recordCheckpoint method records the head of the validated chain,
+// unless the last checkpoint already covers it
+func (b *Blockchain) recordCheckpoint(chain []*block.Block, opts ValidationOptions) {
+	height := len(chain) - 1
+	cp := Checkpoint{Height: height, Hash: chain[height].GetHash(), Proofs: opts.VerifyProofs}
+	b.checkpointMutex.Lock()
+	defer b.checkpointMutex.Unlock()
+	if n := len(b.checkpoints); n > 0 && b.checkpoints[n-1] == cp {
+		return
+	}
+	if len(b.checkpoints) == maxCheckpoints {
+		b.checkpoints = append(b.checkpoints[:0], b.checkpoints[1:]...)
+	}
+	b.checkpoints = append(b.checkpoints, cp)
+}
@@ -0,0 +1,229 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/starchain/block"
+	"github.com/starchain/contracts"
+	"github.com/starchain/utils"
+	"io"
+)
+
+// Export formats
+const (
+	// ExportNDJSON is a JSON header line followed by one JSON block per line
+	ExportNDJSON = "ndjson"
+	// ExportBinary is exportMagic followed by records framed the same way
+	// as in FileStore: the JSON header and blocks encoded by MarshalBinary
+	ExportBinary = "binary"
+)
+
+// ExportVersion is the version of the export header
+const ExportVersion = 1
+
+// exportMagic starts every binary export, NDJSON export starts with '{'
+var exportMagic = []byte("STARCHAIN\x00")
+
+var (
+	InvalidExportErr     = errors.New("Export file is invalid")
+	UnsupportedExportErr = errors.New("Export format is not supported")
+	NonEmptyStoreErr     = errors.New("Chain can be imported only into an empty store")
+)
+
+// ExportHeader struct describes the exported chain,
+// import checks the blocks against it
+type ExportHeader struct {
+	Version     int    `json:"version"`
+	ChainID     string `json:"chainId"`
+	GenesisHash string `json:"genesisHash"`
+	HeadHash    string `json:"headHash"`
+	Blocks      int    `json:"blocks"`
+}
+
+// Export method streams the whole chain in the format to w
+func (b *Blockchain) Export(w io.Writer, format string) error {
+	b.mutex.RLock()
+	chain := b.chain
+	b.mutex.RUnlock()
+	header := ExportHeader{
+		Version:     ExportVersion,
+		ChainID:     b.config.ChainID,
+		GenesisHash: utils.HashToStr(chain[0].GetHash()),
+		HeadHash:    utils.HashToStr(chain[len(chain)-1].GetHash()),
+		Blocks:      len(chain),
+	}
+	writer := bufio.NewWriter(w)
+	switch format {
+	case ExportNDJSON:
+		encoder := json.NewEncoder(writer)
+		if err := encoder.Encode(header); err != nil {
+			return err
+		}
+		for _, block := range chain {
+			if err := encoder.Encode(block); err != nil {
+				return err
+			}
+		}
+	case ExportBinary:
+		encoded, err := json.Marshal(header)
+		if err != nil {
+			return err
+		}
+		writer.Write(exportMagic)
+		writer.Write(frameRecord(encoded))
+		for _, block := range chain {
+			payload, err := block.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			if _, err := writer.Write(frameRecord(payload)); err != nil {
+				return err
+			}
+		}
+	default:
+		return UnsupportedExportErr
+	}
+	return writer.Flush()
+}
+
+// Import fn rebuilds Blockchain from the export read from r.
+// Every block is validated, linked to the previous one and checked to be
+// signed by a trusted producer before anything is written to the store,
+// the first bad height is reported in InvalidExportErr. Blocks are then
+// appended to the empty store and opened with Open.
+func Import(r io.Reader, clock contracts.Clock, config Config, store Store) (*Blockchain, error) {
+	stored, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) > 0 {
+		return nil, NonEmptyStoreErr
+	}
+	reader := bufio.NewReader(r)
+	var (
+		next   func() ([]byte, error)
+		decode func([]byte, *block.Block) error
+	)
+	if magic, _ := reader.Peek(len(exportMagic)); bytes.Equal(magic, exportMagic) {
+		reader.Discard(len(exportMagic))
+		next = func() ([]byte, error) {
+			return nextRecord(reader)
+		}
+		decode = func(encoded []byte, b *block.Block) error {
+			return b.UnmarshalBinary(encoded)
+		}
+	} else {
+		next = func() ([]byte, error) {
+			return nextLine(reader)
+		}
+		decode = func(encoded []byte, b *block.Block) error {
+			return json.Unmarshal(encoded, b)
+		}
+	}
+	var header ExportHeader
+	encoded, err := next()
+	if err != nil || json.Unmarshal(encoded, &header) != nil {
+		return nil, errors.New(fmt.Sprintf("%s: header is malformed", InvalidExportErr))
+	}
+	if header.Version != ExportVersion {
+		return nil, UnsupportedExportErr
+	}
+	if chainID := config.withDefaults().ChainID; header.ChainID != chainID {
+		return nil, errors.New(fmt.Sprintf("%s: chain ID %s does not match %s", InvalidExportErr, header.ChainID, chainID))
+	}
+	var chain []*block.Block
+	trustedKeys := config.trustedKeys()
+	for {
+		height := len(chain)
+		encoded, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, invalidBlock(height, err.Error())
+		}
+		var b block.Block
+		if err := decode(encoded, &b); err != nil {
+			return nil, invalidBlock(height, err.Error())
+		}
+		if reason := checkLink(chain, &b); reason != "" {
+			return nil, invalidBlock(height, reason)
+		}
+		if err := verifyProducer(trustedKeys, &b); err != nil {
+			return nil, invalidBlock(height, err.Error())
+		}
+		chain = append(chain, &b)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New(fmt.Sprintf("%s: no blocks", InvalidExportErr))
+	}
+	if len(chain) != header.Blocks {
+		return nil, errors.New(fmt.Sprintf("%s: %d blocks, header says %d", InvalidExportErr, len(chain), header.Blocks))
+	}
+	if utils.HashToStr(chain[0].GetHash()) != header.GenesisHash {
+		return nil, errors.New(fmt.Sprintf("%s: genesis hash does not match the header", InvalidExportErr))
+	}
+	if utils.HashToStr(chain[len(chain)-1].GetHash()) != header.HeadHash {
+		return nil, errors.New(fmt.Sprintf("%s: head hash does not match the header", InvalidExportErr))
+	}
+	for _, b := range chain {
+		if err := store.Append(b); err != nil {
+			return nil, err
+		}
+	}
+	return Open(clock, config, store)
+}
+
+// checkLink fn returns why the block can not follow the chain,
+// empty string when it can
+func checkLink(chain []*block.Block, b *block.Block) string {
+	if !b.Validate() {
+		return "hash does not match the block"
+	}
+	height := len(chain)
+	if b.GetHeight() != height {
+		return fmt.Sprintf("height field is %d", b.GetHeight())
+	}
+	var prevHash [sha256.Size]byte
+	if height > 0 {
+		prevHash = chain[height-1].GetHash()
+	}
+	if b.GetPrevHash() != prevHash {
+		return fmt.Sprintf("prevHash %x does not match previous block hash %x", b.GetPrevHash(), prevHash)
+	}
+	return ""
+}
+
+func invalidBlock(height int, reason string) error {
+	return errors.New(fmt.Sprintf("%s: block at height %d: %s", InvalidExportErr, height, reason))
+}
+
+// nextRecord fn reads the next framed record, io.EOF marks the end
+func nextRecord(reader *bufio.Reader) ([]byte, error) {
+	if _, err := reader.Peek(1); err == io.EOF {
+		return nil, io.EOF
+	}
+	payload, err := readRecord(reader, MaxRecordSize+recordHeaderSize)
+	if err != nil {
+		return nil, recordErr(err)
+	}
+	return payload, nil
+}
+
+// nextLine fn reads the next non-empty line, io.EOF marks the end
+func nextLine(reader *bufio.Reader) ([]byte, error) {
+	for {
+		line, err := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
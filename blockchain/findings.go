@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"fmt"
+	"github.com/starchain/block"
+)
+
+// Kinds of validation findings
+const (
+	FindingHashMismatch        = "hash-mismatch"
+	FindingPrevHashMismatch    = "prev-hash-mismatch"
+	FindingHeightGap           = "height-gap"
+	FindingTimestampRegression = "timestamp-regression"
+	FindingBadGenesis          = "bad-genesis"
+	FindingGenesisOwner        = "genesis-owner"
+	FindingGenesisPrevHash     = "genesis-prev-hash"
+	FindingEmptyOwner          = "empty-owner"
+	FindingClockDrift          = "clock-drift"
+	FindingBadDifficulty       = "bad-difficulty"
+	FindingInsufficientWork    = "insufficient-work"
+	FindingBadProducer         = "bad-producer"
+	FindingBadProof            = "bad-proof"
+	FindingCorruptRecord       = "corrupt-record"
+)
+
+// Finding struct is a problem ValidateChain found in the block at Height.
+// Expected and Actual hold the values which differ, when the kind has
+// them, Detail describes the problem in words.
+type Finding struct {
+	Kind     string
+	Height   int
+	Expected string
+	Actual   string
+	Detail   string
+}
+
+// Error method makes Finding an error, so findings are reported
+// wherever ValidateChain errors are
+func (f *Finding) Error() string {
+	msg := fmt.Sprintf("Block at height %d: %s: %s", f.Height, f.Kind, f.Detail)
+	if f.Expected != "" || f.Actual != "" {
+		msg += fmt.Sprintf(" (expected %s, actual %s)", f.Expected, f.Actual)
+	}
+	return msg
+}
+
+// newFinding fn returns finding of the kind without expected and actual values
+func newFinding(kind string, height int, err error) *Finding {
+	return &Finding{Kind: kind, Height: height, Detail: err.Error()}
+}
+
+// checkHash fn returns hash mismatch finding when the stored hash of the
+// block at the height differs from its calculated hash, nil otherwise
+func checkHash(b *block.Block, height int) *Finding {
+	if b.Validate() {
+		return nil
+	}
+	finding := &Finding{
+		Kind: FindingHashMismatch, Height: height, Expected: fmt.Sprintf("%x", b.CalculateHash()), Actual: fmt.Sprintf("%x", b.GetHash()),
+		Detail: "stored hash does not match calculated hash",
+	}
+	if version := b.GetHashVersion(); !block.KnownHashVersion(version) {
+		finding.Detail = fmt.Sprintf("unknown hash version %d", version)
+	}
+	return finding
+}
+
+// checkPrevHash fn returns prev-hash mismatch finding when the block at
+// the height does not link to the calculated hash of prev, nil otherwise
+func checkPrevHash(prev *block.Block, b *block.Block, height int) *Finding {
+	prevHash := prev.CalculateHash()
+	if b.GetPrevHash() == prevHash {
+		return nil
+	}
+	return &Finding{
+		Kind: FindingPrevHashMismatch, Height: height, Expected: fmt.Sprintf("%x", prevHash), Actual: fmt.Sprintf("%x", b.GetPrevHash()),
+		Detail: "prevHash does not match calculated hash of the previous block",
+	}
+}
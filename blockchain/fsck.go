@@ -0,0 +1,123 @@
+package blockchain
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/starchain/block"
+	"io"
+	"os"
+)
+
+var (
+	FileChangedErr   = errors.New("Store file changed since it was checked")
+	NothingToKeepErr = errors.New("Genesis block is damaged, there is no good block to keep")
+)
+
+// FsckReport struct is the result of CheckFile. Findings are ordered by
+// height, the first GoodBlocks blocks are intact and take GoodSize bytes
+// at the start of the file.
+type FsckReport struct {
+	Size       int64
+	Blocks     int
+	Findings   []*Finding
+	GoodBlocks int
+	GoodSize   int64
+}
+
+// CheckFile fn scans the store file without opening it as FileStore,
+// so a damaged file is reported instead of refused. Every block is checked
+// with Block.Validate and against the hash of the previous block.
+// Scanning stops at the first record which can not be read, records
+// after it can not be located.
+func CheckFile(path string) (FsckReport, error) {
+	var report FsckReport
+	file, err := os.Open(path)
+	if err != nil {
+		return report, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return report, err
+	}
+	report.Size = info.Size()
+	var (
+		reader  = bufio.NewReader(file)
+		offsets = []int64{0}
+		prev    *block.Block
+	)
+	for offset := int64(0); offset < report.Size; offset = offsets[len(offsets)-1] {
+		height := report.Blocks
+		payload, err := readRecord(reader, report.Size-offset)
+		if err != nil {
+			err = recordErr(err)
+		}
+		var b *block.Block
+		if err == nil {
+			b, err = decodeRecord(payload)
+		}
+		if err != nil {
+			report.Findings = append(report.Findings, &Finding{
+				Kind: FindingCorruptRecord, Height: height,
+				Detail: fmt.Sprintf("record at offset %d can not be read: %s, following records are lost", offset, err),
+			})
+			break
+		}
+		if finding := checkHash(b, height); finding != nil {
+			report.Findings = append(report.Findings, finding)
+		}
+		if prev != nil {
+			if finding := checkPrevHash(prev, b, height); finding != nil {
+				report.Findings = append(report.Findings, finding)
+			}
+		}
+		prev = b
+		report.Blocks++
+		offsets = append(offsets, offset+recordHeaderSize+int64(len(payload)))
+	}
+	report.GoodBlocks = report.Blocks
+	if len(report.Findings) > 0 {
+		report.GoodBlocks = report.Findings[0].Height
+	}
+	report.GoodSize = offsets[report.GoodBlocks]
+	return report, nil
+}
+
+// RepairFile fn copies the store file to backupPath, which must not exist,
+// and truncates the file after the last good block of the report
+func RepairFile(path string, backupPath string, report FsckReport) error {
+	if report.GoodBlocks == 0 {
+		return NothingToKeepErr
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != report.Size {
+		return FileChangedErr
+	}
+	backup, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(backup, file)
+	if err == nil {
+		err = backup.Sync()
+	}
+	if closeErr := backup.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("Could not write backup: %s", err))
+	}
+	if err := file.Truncate(report.GoodSize); err != nil {
+		return err
+	}
+	return file.Sync()
+}
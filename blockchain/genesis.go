@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/starchain/block"
+	"io/ioutil"
+)
+
+var (
+	InvalidGenesisErr  = errors.New("Genesis must have chain ID, timestamp bigger than 0 and hex encoded Ed25519 public key as producer key")
+	GenesisMismatchErr = errors.New("Stored genesis block does not match the genesis configuration")
+	GenesisChainIDErr  = errors.New("Chain ID does not match the genesis configuration")
+	GenesisProducerErr = errors.New("Genesis block can be created only by its producer")
+)
+
+// Genesis struct defines block 0 of a network. Every node configured
+// with the same Genesis derives the same block 0.
+// ProducerKey is hex encoded Ed25519 public key of the node which signs
+// the genesis block, other nodes trust it and get the chain from that node.
+// Without ProducerKey every node signs the genesis block on its own,
+// the hash is the same, the signature is not.
+type Genesis struct {
+	ChainID     string `json:"chainId"`
+	Timestamp   int64  `json:"timestamp"`
+	Data        string `json:"data"`
+	ProducerKey string `json:"producerKey,omitempty"`
+}
+
+// LoadGenesis fn reads and validates JSON genesis configuration
+func LoadGenesis(path string) (Genesis, error) {
+	var genesis Genesis
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return genesis, err
+	}
+	if err := json.Unmarshal(content, &genesis); err != nil {
+		return genesis, errors.New(fmt.Sprintf("Could not decode genesis %s: %s", path, err))
+	}
+	return genesis, genesis.Validate()
+}
+
+// Validate method checks the genesis configuration
+func (g Genesis) Validate() error {
+	if g.ChainID == "" || g.Timestamp <= 0 {
+		return InvalidGenesisErr
+	}
+	if g.ProducerKey != "" {
+		if _, err := g.producerKey(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Block method returns unsigned genesis block
+func (g Genesis) Block() *block.Block {
+	var prevHash [sha256.Size]byte
+	return block.New(g.Timestamp, 0, "", &prevHash, []byte(g.Data))
+}
+
+// producerKey method decodes ProducerKey, nil when it is not set
+func (g Genesis) producerKey() (ed25519.PublicKey, error) {
+	if g.ProducerKey == "" {
+		return nil, nil
+	}
+	pubKey, err := hex.DecodeString(g.ProducerKey)
+	if err != nil || len(pubKey) != ed25519.PublicKeySize {
+		return nil, InvalidGenesisErr
+	}
+	return ed25519.PublicKey(pubKey), nil
+}
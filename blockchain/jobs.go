@@ -0,0 +1,139 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/starchain/contracts"
+	"io"
+	"sync"
+)
+
+// Validation job states
+const (
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobCancelled = "cancelled"
+)
+
+// maxFinishedJobs is the number of finished jobs kept for polling
+const maxFinishedJobs = 16
+
+// Job errors are shared with contracts, so they can be told apart
+// behind BlockchainOperator
+var (
+	JobRunningErr = contracts.JobRunningErr
+	UnknownJobErr = contracts.UnknownJobErr
+)
+
+// ValidationJob struct is the status of validation running in background
+type ValidationJob struct {
+	ID         string
+	State      string
+	Checked    int
+	Total      int
+	Errors     []error
+	StartedAt  int64
+	FinishedAt int64
+}
+
+// validationJob struct is a job together with the function cancelling it
+type validationJob struct {
+	mutex  sync.Mutex
+	status ValidationJob
+	cancel context.CancelFunc
+}
+
+func (j *validationJob) get() ValidationJob {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	status := j.status
+	status.Errors = append([]error{}, j.status.Errors...)
+	return status
+}
+
+// StartValidation method validates the chain in background and returns
+// the job, its status can be polled with GetValidation.
+// Only one job runs at a time, JobRunningErr is returned otherwise.
+// Progress of opts is replaced by the job progress.
+func (b *Blockchain) StartValidation(opts ValidationOptions) (ValidationJob, error) {
+	var id [8]byte
+	if _, err := io.ReadFull(b.random, id[:]); err != nil {
+		return ValidationJob{}, err
+	}
+	b.jobsMutex.Lock()
+	defer b.jobsMutex.Unlock()
+	for _, j := range b.jobs {
+		if j.get().State == JobRunning {
+			return ValidationJob{}, JobRunningErr
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &validationJob{cancel: cancel}
+	job.status = ValidationJob{ID: hex.EncodeToString(id[:]), State: JobRunning, StartedAt: b.clock.GetTime()}
+	opts.Progress = func(checked int, total int) {
+		job.mutex.Lock()
+		job.status.Checked, job.status.Total = checked, total
+		job.mutex.Unlock()
+	}
+	b.jobs[job.status.ID] = job
+	b.jobOrder = append(b.jobOrder, job.status.ID)
+	b.pruneJobs()
+	go func() {
+		defer cancel()
+		errs, err := b.ValidateChainContext(ctx, opts)
+		job.mutex.Lock()
+		defer job.mutex.Unlock()
+		job.status.Errors = errs
+		job.status.State = JobDone
+		if err != nil {
+			job.status.State = JobCancelled
+		}
+		job.status.FinishedAt = b.clock.GetTime()
+	}()
+	return job.get(), nil
+}
+
+// GetValidation method returns status of the validation job
+func (b *Blockchain) GetValidation(id string) (ValidationJob, error) {
+	b.jobsMutex.Lock()
+	job, ok := b.jobs[id]
+	b.jobsMutex.Unlock()
+	if !ok {
+		return ValidationJob{}, UnknownJobErr
+	}
+	return job.get(), nil
+}
+
+// CancelValidation method stops the validation job,
+// the job is cancelled once its status is JobCancelled
+func (b *Blockchain) CancelValidation(id string) (ValidationJob, error) {
+	b.jobsMutex.Lock()
+	job, ok := b.jobs[id]
+	b.jobsMutex.Unlock()
+	if !ok {
+		return ValidationJob{}, UnknownJobErr
+	}
+	job.cancel()
+	return job.get(), nil
+}
+
+// pruneJobs method drops the oldest finished jobs above maxFinishedJobs.
+// Caller must hold jobsMutex.
+func (b *Blockchain) pruneJobs() {
+	finished := 0
+	for _, id := range b.jobOrder {
+		if b.jobs[id].get().State != JobRunning {
+			finished++
+		}
+	}
+	kept := b.jobOrder[:0]
+	for _, id := range b.jobOrder {
+		if finished > maxFinishedJobs && b.jobs[id].get().State != JobRunning {
+			delete(b.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	b.jobOrder = kept
+}
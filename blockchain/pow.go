@@ -0,0 +1,97 @@
+package blockchain
+
+import (
+	"errors"
+	"github.com/starchain/block"
+	"math/big"
+)
+
+// Proof-of-work defaults, see PoWConfig
+const (
+	DefaultInitialDifficulty uint64 = 1 << 16
+	DefaultRetargetInterval  int    = 32
+	DefaultBlockInterval     int64  = 60
+)
+
+// maxRetargetFactor limits the difficulty change of a single retarget
+const maxRetargetFactor = 4
+
+var MiningCancelledErr = errors.New("Mining was cancelled")
+
+// PoWConfig struct enables proof-of-work: blocks from ActivationHeight on
+// are mined. Difficulty starts at InitialDifficulty and every
+// RetargetInterval blocks it is scaled toward one block per BlockInterval
+// seconds. Zero values are replaced with defaults.
+type PoWConfig struct {
+	// InitialDifficulty is the average number of hashes the first mined blocks take
+	InitialDifficulty uint64
+	// RetargetInterval is the number of blocks between difficulty changes
+	RetargetInterval int
+	// BlockInterval is the number of seconds mining of a block should take
+	BlockInterval int64
+	// ActivationHeight is the height of the first mined block, so the mode
+	// can be enabled on an existing chain, 1 by default
+	ActivationHeight int
+}
+
+// withDefaults method returns the config with unset values filled in
+func (p PoWConfig) withDefaults() PoWConfig {
+	if p.InitialDifficulty == 0 {
+		p.InitialDifficulty = DefaultInitialDifficulty
+	}
+	if p.RetargetInterval < 2 {
+		p.RetargetInterval = DefaultRetargetInterval
+	}
+	if p.BlockInterval <= 0 {
+		p.BlockInterval = DefaultBlockInterval
+	}
+	if p.ActivationHeight < 1 {
+		p.ActivationHeight = 1
+	}
+	return p
+}
+
+// difficulty method returns difficulty the block at the height has to be
+// mined with, chain holds at least the blocks before it.
+// Blocks keep the difficulty of the previous block, except at multiples
+// of RetargetInterval with a full interval of mined blocks behind them:
+// there it is multiplied by the expected time of the interval divided
+// by the time it took, limited to maxRetargetFactor either way.
+func (p PoWConfig) difficulty(chain []*block.Block, height int) uint64 {
+	if height <= p.ActivationHeight {
+		return p.InitialDifficulty
+	}
+	previous := chain[height-1].GetDifficulty()
+	if previous == 0 {
+		previous = p.InitialDifficulty
+	}
+	if height%p.RetargetInterval != 0 || height-p.RetargetInterval < p.ActivationHeight {
+		return previous
+	}
+	expected := p.BlockInterval * int64(p.RetargetInterval-1)
+	actual := chain[height-1].GetTimestamp() - chain[height-p.RetargetInterval].GetTimestamp()
+	if actual < expected/maxRetargetFactor {
+		actual = expected / maxRetargetFactor
+	}
+	if actual > expected*maxRetargetFactor {
+		actual = expected * maxRetargetFactor
+	}
+	if actual <= 0 {
+		actual = 1
+	}
+	next := new(big.Int).SetUint64(previous)
+	next.Mul(next, big.NewInt(expected))
+	next.Div(next, big.NewInt(actual))
+	if next.Sign() <= 0 {
+		return 1
+	}
+	if !next.IsUint64() {
+		return ^uint64(0)
+	}
+	return next.Uint64()
+}
+
+// mined method reports whether the block at the height has to be mined
+func (p *PoWConfig) mined(height int) bool {
+	return p != nil && height >= p.ActivationHeight
+}
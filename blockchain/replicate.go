@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"github.com/starchain/block"
+)
+
+// MaxBlocksPerRange limits the number of blocks returned by GetBlocksSince
+const MaxBlocksPerRange = 100
+
+var (
+	ReplicationErr  = errors.New("Block received from the leader is rejected")
+	NonEmptySeedErr = errors.New("Only an empty store can be seeded")
+)
+
+// GetBlocksSince method returns at most limit blocks from the height on,
+// no blocks when the height is the chain height. Limit is capped
+// at MaxBlocksPerRange.
+func (b *Blockchain) GetBlocksSince(height int, limit int) ([]*block.Block, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if height < 0 || height > len(b.chain) {
+		return nil, errors.New(fmt.Sprintf("Invalid height: %v", height))
+	}
+	if limit <= 0 || limit > MaxBlocksPerRange {
+		limit = MaxBlocksPerRange
+	}
+	end := height + limit
+	if end > len(b.chain) {
+		end = len(b.chain)
+	}
+	return append([]*block.Block{}, b.chain[height:end]...), nil
+}
+
+// AppendBlocks method appends blocks received from the leader and returns
+// the number of blocks appended. Every block is checked the same way
+// ValidateChain does, without ownership proofs: its hash, linkage to the
+// previous block and the producer signature. Blocks the chain already
+// holds are skipped. The first rejected block stops appending and is
+// reported in ReplicationErr.
+func (b *Blockchain) AppendBlocks(blocks []*block.Block) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := b.clock.GetTime()
+	appended := 0
+	for _, next := range blocks {
+		height := next.GetHeight()
+		if height >= 0 && height < len(b.chain) && b.chain[height].GetHash() == next.GetHash() {
+			continue
+		}
+		chain := append(b.chain[:len(b.chain):len(b.chain)], next)
+		if errs := b.validateBlock(chain, len(b.chain), now, ValidationOptions{}); len(errs) > 0 {
+			return appended, errors.New(fmt.Sprintf("%s: %s", ReplicationErr, errs[0]))
+		}
+		if err := b.appendBlock(next); err != nil {
+			return appended, err
+		}
+		appended++
+	}
+	return appended, nil
+}
+
+// Seed fn writes the genesis block of the leader to the empty store,
+// so Open loads the chain of the leader instead of creating own genesis
+// block. The block has to match config.Genesis when it is set and be
+// signed by a trusted producer of the config, nothing is written otherwise.
+func Seed(store Store, genesis *block.Block, config Config) error {
+	stored, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if len(stored) > 0 {
+		return NonEmptySeedErr
+	}
+	if reason := checkLink(nil, genesis); reason != "" {
+		return errors.New(fmt.Sprintf("%s: %s", ReplicationErr, reason))
+	}
+	if configured := config.Genesis; configured != nil && genesis.GetHash() != configured.Block().GetHash() {
+		return errors.New(fmt.Sprintf("%s: leader %x, configured %x", GenesisMismatchErr, genesis.GetHash(), configured.Block().GetHash()))
+	}
+	if err := verifyProducer(config.trustedKeys(), genesis); err != nil {
+		return errors.New(fmt.Sprintf("%s: %s", ReplicationErr, err))
+	}
+	return store.Append(genesis)
+}
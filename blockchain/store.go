@@ -0,0 +1,300 @@
+package blockchain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/starchain/block"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Store interface persists blocks of the chain.
+// Blocks are appended in height order and never modified.
+type Store interface {
+	// Load returns all stored blocks in height order
+	Load() ([]*block.Block, error)
+	// Append persists the block, it is durable once Append returns
+	Append(b *block.Block) error
+	// Close releases resources of the store
+	Close() error
+}
+
+// recordHeaderSize is the size of record length and CRC32 of the payload
+const recordHeaderSize = 8
+
+// MaxRecordSize limits the size of a single stored block
+const MaxRecordSize = 16 << 20
+
+var (
+	CorruptedStoreErr  = errors.New("Store is corrupted")
+	ClosedStoreErr     = errors.New("Store is closed")
+	OversizedRecordErr = errors.New("Record length exceeds MaxRecordSize")
+)
+
+// MemoryStore struct keeps blocks in memory only,
+// they are lost when the process exits
+type MemoryStore struct {
+	mutex  sync.Mutex
+	blocks []*block.Block
+	heads  []TreeHead
+}
+
+// NewMemoryStore fn returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Load() ([]*block.Block, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*block.Block{}, s.blocks...), nil
+}
+
+func (s *MemoryStore) Append(b *block.Block) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.blocks = append(s.blocks, b)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// FileStore struct is an append-only file of block records.
+// Every record is: payload length (uint32 BE), CRC32 of the payload
+// (uint32 BE) and the payload, the block encoded by MarshalBinary. Each append is fsynced.
+// Records written before the binary encoding hold the block as JSON,
+// they are still read, see decodeRecord.
+// A torn record at the end of the file, left by a crash in the middle
+// of Append, is truncated when the store is opened.
+// Published tree heads are kept in the same kind of records in the file
+// with TreeHeadsSuffix next to it.
+type FileStore struct {
+	mutex     sync.Mutex
+	file      *os.File
+	size      int64
+	blocks    []*block.Block
+	headsFile *os.File
+	headsSize int64
+	heads     []TreeHead
+}
+
+// OpenFileStore fn opens or creates the store file and reads all records.
+// It fails with CorruptedStoreErr when a record other than a torn final
+// one is damaged, the file is left untouched for starchain fsck then.
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	store := FileStore{file: file}
+	err = store.read()
+	if err == nil {
+		err = store.openTreeHeads(path + TreeHeadsSuffix)
+	}
+	if err != nil {
+		file.Close()
+		if strings.HasPrefix(err.Error(), CorruptedStoreErr.Error()) {
+			return nil, errors.New(fmt.Sprintf("%s, run starchain fsck -data %s to find the damage and repair it", err, path))
+		}
+		return nil, err
+	}
+	return &store, nil
+}
+
+// read method loads all records and truncates a torn final record:
+// a short header, a length running past the end of the file or a bad
+// checksum of the record ending the file. Other damage is reported.
+func (s *FileStore) read() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	reader := bufio.NewReader(s.file)
+	var offset int64
+	for offset < size {
+		payload, err := readRecord(reader, size-offset)
+		end := offset + recordHeaderSize + int64(len(payload))
+		if err == io.ErrUnexpectedEOF || (err == CorruptedStoreErr && end >= size) {
+			return s.truncate(offset)
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s: record at offset %d: %s", CorruptedStoreErr, offset, recordErr(err)))
+		}
+		b, err := decodeRecord(payload)
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s: record at offset %d: %s", CorruptedStoreErr, offset, err))
+		}
+		s.blocks = append(s.blocks, b)
+		offset = end
+	}
+	s.size = offset
+	_, err = s.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// legacyRecord struct is the JSON payload of records written before
+// blocks were stored by MarshalBinary. Data is the hex encoded body
+// and blocks are hashed with HashV1.
+type legacyRecord struct {
+	Time             int64  `json:"time"`
+	Height           int    `json:"height"`
+	Owner            string `json:"owner"`
+	PrevHash         string `json:"prevHash"`
+	Data             string `json:"data"`
+	Message          string `json:"message,omitempty"`
+	MessageSignature string `json:"messageSignature,omitempty"`
+	Hash             string `json:"hash"`
+	Signature        string `json:"signature,omitempty"`
+	KeyID            string `json:"keyId,omitempty"`
+}
+
+// decodeRecord fn decodes the block of the record payload.
+// Binary payloads start with the encoding version, legacy JSON
+// payloads with '{', these are converted to the version 1 block JSON.
+func decodeRecord(payload []byte) (*block.Block, error) {
+	var b block.Block
+	if len(payload) == 0 || payload[0] != '{' {
+		if err := b.UnmarshalBinary(payload); err != nil {
+			return nil, err
+		}
+		return &b, nil
+	}
+	var legacy legacyRecord
+	if err := json.Unmarshal(payload, &legacy); err != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", block.MalformedBlockErr, err))
+	}
+	encoded, err := json.Marshal(map[string]interface{}{
+		"version":           1,
+		"time":              legacy.Time,
+		"height":            legacy.Height,
+		"owner":             legacy.Owner,
+		"previousBlockHash": legacy.PrevHash,
+		"body":              legacy.Data,
+		"message":           legacy.Message,
+		"messageSignature":  legacy.MessageSignature,
+		"hash":              legacy.Hash,
+		"signature":         legacy.Signature,
+		"keyId":             legacy.KeyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// truncate method drops everything after offset and syncs the file
+func (s *FileStore) truncate(offset int64) error {
+	if err := s.file.Truncate(offset); err != nil {
+		return err
+	}
+	s.size = offset
+	if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileStore) Load() ([]*block.Block, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*block.Block{}, s.blocks...), nil
+}
+
+func (s *FileStore) Append(b *block.Block) error {
+	payload, err := b.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	record := frameRecord(payload)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return ClosedStoreErr
+	}
+	_, err = s.file.Write(record)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		// Drop partially written record, so the next append does not
+		// follow it. If that fails too, it is truncated on the next open.
+		s.truncate(s.size)
+		return err
+	}
+	s.size += int64(len(record))
+	s.blocks = append(s.blocks, b)
+	return nil
+}
+
+func (s *FileStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	if headsErr := s.headsFile.Close(); err == nil {
+		err = headsErr
+	}
+	s.file = nil
+	s.headsFile = nil
+	return err
+}
+
+// frameRecord fn prefixes the payload with its length and CRC32
+func frameRecord(payload []byte) []byte {
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[recordHeaderSize:], payload)
+	return record
+}
+
+// recordErr fn describes readRecord error in words
+func recordErr(err error) error {
+	switch err {
+	case io.ErrUnexpectedEOF:
+		return errors.New("record is truncated")
+	case CorruptedStoreErr:
+		return errors.New("record checksum does not match")
+	}
+	return err
+}
+
+// readRecord fn reads a single record and checks its CRC.
+// It returns OversizedRecordErr when the length is above MaxRecordSize,
+// io.ErrUnexpectedEOF when the record does not fit in the remaining bytes
+// of the file and CorruptedStoreErr when the checksum does not match.
+func readRecord(reader io.Reader, remaining int64) ([]byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > MaxRecordSize {
+		return nil, OversizedRecordErr
+	}
+	if int64(length) > remaining-recordHeaderSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return payload, CorruptedStoreErr
+	}
+	return payload, nil
+}
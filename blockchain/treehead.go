@@ -0,0 +1,262 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/starchain/block"
+	"github.com/starchain/mmr"
+	"log"
+	"time"
+)
+
+// treeHeadDomain separates signed tree heads from other messages
+// signed with the node key
+const treeHeadDomain = "starchain-tree-head-v1"
+
+var (
+	NoTreeHeadErr       = errors.New("No tree head was published")
+	TreeHeadSigErr      = errors.New("Tree head signature is not valid")
+	TreeHeadOrderErr    = errors.New("Newer tree head is below the older one")
+	TreeHeadMismatchErr = errors.New("Tree head does not match the chain")
+)
+
+// TreeHead struct is the signed statement of the chain state: block at
+// Height with HeadHash is the last one and Root is the accumulator root
+// over Height+1 blocks. Auditors keep tree heads, two heads signed by the
+// node which are not consistent prove the operator rewrote history.
+type TreeHead struct {
+	Height    int
+	HeadHash  [sha256.Size]byte
+	Root      [sha256.Size]byte
+	Timestamp int64
+	KeyID     string
+	Signature []byte
+}
+
+// TreeHeadConsistency struct proves the accumulator with NewRoot over
+// NewHeight+1 blocks extends the one with OldRoot over OldHeight+1 blocks,
+// see mmr.VerifyConsistency. OldHeadProof and NewHeadProof prove the head
+// hash of each tree head is the last leaf of its root.
+// Auditors check it against tree heads they kept.
+type TreeHeadConsistency struct {
+	OldHeight    int
+	NewHeight    int
+	OldRoot      [sha256.Size]byte
+	NewRoot      [sha256.Size]byte
+	Proof        mmr.ConsistencyProof
+	OldHeadProof mmr.Proof
+	NewHeadProof mmr.Proof
+}
+
+// signedData method returns bytes the tree head signature is made over
+func (h TreeHead) signedData(chainID string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(treeHeadDomain)
+	writeString(&buf, chainID)
+	binary.Write(&buf, binary.BigEndian, uint64(h.Height))
+	buf.Write(h.HeadHash[:])
+	buf.Write(h.Root[:])
+	binary.Write(&buf, binary.BigEndian, h.Timestamp)
+	return buf.Bytes()
+}
+
+// Verify method checks the tree head was signed for the chain
+// with the private key of pubKey
+func (h TreeHead) Verify(pubKey ed25519.PublicKey, chainID string) bool {
+	return len(h.Signature) == ed25519.SignatureSize && h.KeyID == block.KeyID(pubKey) &&
+		ed25519.Verify(pubKey, h.signedData(chainID), h.Signature)
+}
+
+// writeString fn writes the string prefixed with its length
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+// PublishTreeHead method signs the current head of the chain and appends
+// it to published tree heads, persisted when the store is a TreeHeadStore.
+// Nothing is appended when the chain did not grow since the last published
+// head, which is returned instead.
+func (b *Blockchain) PublishTreeHead() (TreeHead, error) {
+	b.mutex.RLock()
+	height := len(b.chain) - 1
+	head := TreeHead{
+		Height:    height,
+		HeadHash:  b.chain[height].GetHash(),
+		Root:      b.accumulator.Root(),
+		Timestamp: b.clock.GetTime(),
+	}
+	b.mutex.RUnlock()
+	b.treeHeadsMutex.Lock()
+	defer b.treeHeadsMutex.Unlock()
+	if n := len(b.treeHeads); n > 0 && b.treeHeads[n-1].Height >= height {
+		return b.treeHeads[n-1], nil
+	}
+	head.KeyID = block.KeyID(b.GetProducerKey())
+	head.Signature = ed25519.Sign(b.config.SigningKey, head.signedData(b.config.ChainID))
+	if store, ok := b.store.(TreeHeadStore); ok {
+		if err := store.AppendTreeHead(head); err != nil {
+			return TreeHead{}, err
+		}
+	}
+	b.treeHeads = append(b.treeHeads, head)
+	return head, nil
+}
+
+// loadTreeHeads method reloads tree heads persisted by the store.
+// Every head has to be signed by a trusted key and match the chain.
+func (b *Blockchain) loadTreeHeads() error {
+	store, ok := b.store.(TreeHeadStore)
+	if !ok {
+		return nil
+	}
+	heads, err := store.LoadTreeHeads()
+	if err != nil {
+		return err
+	}
+	for _, head := range heads {
+		if err := b.checkTreeHead(head); err != nil {
+			return errors.New(fmt.Sprintf("%s: tree head at height %d: %s", InvalidStoreErr, head.Height, err))
+		}
+	}
+	b.treeHeadsMutex.Lock()
+	defer b.treeHeadsMutex.Unlock()
+	b.treeHeads = heads
+	return nil
+}
+
+// checkTreeHead method returns TreeHeadSigErr when the head was not signed
+// by a trusted key and TreeHeadMismatchErr when it does not match the chain
+func (b *Blockchain) checkTreeHead(head TreeHead) error {
+	pubKey, ok := b.trustedKeys[head.KeyID]
+	if !ok || !head.Verify(pubKey, b.config.ChainID) {
+		return TreeHeadSigErr
+	}
+	if head.Height < 0 || head.Height >= len(b.chain) || b.chain[head.Height].GetHash() != head.HeadHash {
+		return TreeHeadMismatchErr
+	}
+	if root, err := b.accumulator.RootAt(uint64(head.Height + 1)); err != nil || root != head.Root {
+		return TreeHeadMismatchErr
+	}
+	return nil
+}
+
+// startTreeHeads method publishes the first tree head and then one every
+// TreeHeadInterval seconds until the blockchain is closed, in background
+func (b *Blockchain) startTreeHeads() {
+	if b.config.TreeHeadInterval > 0 {
+		go b.publishTreeHeads(time.Duration(b.config.TreeHeadInterval) * time.Second)
+	}
+}
+
+// publishTreeHeads method publishes tree heads until the blockchain is
+// closed, heads which can not be persisted are logged and skipped
+func (b *Blockchain) publishTreeHeads(interval time.Duration) {
+	publish := func() {
+		if _, err := b.PublishTreeHead(); err != nil {
+			log.Println("ERR: could not publish tree head: ", err)
+		}
+	}
+	publish()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			publish()
+		case <-b.closed.Done():
+			return
+		}
+	}
+}
+
+// GetTreeHeads method returns published tree heads from the oldest,
+// including the ones reloaded from the store
+func (b *Blockchain) GetTreeHeads() []TreeHead {
+	b.treeHeadsMutex.Lock()
+	defer b.treeHeadsMutex.Unlock()
+	return append([]TreeHead{}, b.treeHeads...)
+}
+
+// GetLatestTreeHead method returns the last published tree head
+// or NoTreeHeadErr
+func (b *Blockchain) GetLatestTreeHead() (TreeHead, error) {
+	b.treeHeadsMutex.Lock()
+	defer b.treeHeadsMutex.Unlock()
+	if len(b.treeHeads) == 0 {
+		return TreeHead{}, NoTreeHeadErr
+	}
+	return b.treeHeads[len(b.treeHeads)-1], nil
+}
+
+// GetTreeHeadConsistency method returns proof that the accumulator over
+// newHeight+1 blocks extends the one over oldHeight+1 blocks. It is built
+// from the accumulator, so heads published before a restart are covered.
+func (b *Blockchain) GetTreeHeadConsistency(oldHeight int, newHeight int) (TreeHeadConsistency, error) {
+	if oldHeight > newHeight {
+		return TreeHeadConsistency{}, TreeHeadOrderErr
+	}
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if oldHeight < 0 || newHeight >= len(b.chain) {
+		return TreeHeadConsistency{}, errors.New(fmt.Sprintf("Invalid heights: %v, %v", oldHeight, newHeight))
+	}
+	consistency := TreeHeadConsistency{OldHeight: oldHeight, NewHeight: newHeight}
+	var err error
+	if consistency.OldRoot, err = b.accumulator.RootAt(uint64(oldHeight + 1)); err != nil {
+		return TreeHeadConsistency{}, err
+	}
+	if consistency.NewRoot, err = b.accumulator.RootAt(uint64(newHeight + 1)); err != nil {
+		return TreeHeadConsistency{}, err
+	}
+	if consistency.Proof, err = b.accumulator.ConsistencyProof(uint64(oldHeight+1), uint64(newHeight+1)); err != nil {
+		return TreeHeadConsistency{}, err
+	}
+	if consistency.OldHeadProof, err = b.accumulator.ProofAt(uint64(oldHeight), uint64(oldHeight+1)); err != nil {
+		return TreeHeadConsistency{}, err
+	}
+	if consistency.NewHeadProof, err = b.accumulator.ProofAt(uint64(newHeight), uint64(newHeight+1)); err != nil {
+		return TreeHeadConsistency{}, err
+	}
+	return consistency, nil
+}
+
+// CheckTreeHeadConsistency fn checks both tree heads were signed for the
+// chain with the key, the head hash of each is the last leaf of its root
+// and the proof shows the newer one extends the older one. It needs
+// nothing but the node public key, so auditors can run it.
+func CheckTreeHeadConsistency(older TreeHead, newer TreeHead, consistency TreeHeadConsistency, pubKey ed25519.PublicKey, chainID string) error {
+	if !older.Verify(pubKey, chainID) || !newer.Verify(pubKey, chainID) {
+		return TreeHeadSigErr
+	}
+	if older.Height > newer.Height {
+		return TreeHeadOrderErr
+	}
+	proof := consistency.Proof
+	if proof.OldSize != uint64(older.Height+1) || proof.NewSize != uint64(newer.Height+1) {
+		return mmr.MalformedProofErr
+	}
+	if err := mmr.VerifyConsistency(older.Root, newer.Root, proof); err != nil {
+		return err
+	}
+	if err := checkHeadProof(older, consistency.OldHeadProof); err != nil {
+		return err
+	}
+	return checkHeadProof(newer, consistency.NewHeadProof)
+}
+
+// checkHeadProof fn checks the proof shows the head hash is the last leaf
+// of the root of the tree head, TreeHeadMismatchErr when it is not
+func checkHeadProof(head TreeHead, proof mmr.Proof) error {
+	if proof.Index != uint64(head.Height) || proof.Size != uint64(head.Height+1) {
+		return mmr.MalformedProofErr
+	}
+	if mmr.Verify(head.Root, head.HeadHash, proof) != nil {
+		return TreeHeadMismatchErr
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package blockchain
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// TreeHeadStore interface is implemented by stores which also persist
+// published tree heads, Open reloads them so they are served after
+// a restart
+type TreeHeadStore interface {
+	// LoadTreeHeads returns all stored tree heads from the oldest
+	LoadTreeHeads() ([]TreeHead, error)
+	// AppendTreeHead persists the head, it is durable once it returns
+	AppendTreeHead(head TreeHead) error
+}
+
+// TreeHeadsSuffix is appended to the FileStore path to get the file
+// published tree heads are stored in
+const TreeHeadsSuffix = ".heads"
+
+func (s *MemoryStore) LoadTreeHeads() ([]TreeHead, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]TreeHead{}, s.heads...), nil
+}
+
+func (s *MemoryStore) AppendTreeHead(head TreeHead) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.heads = append(s.heads, head)
+	return nil
+}
+
+// treeHeadRecord struct is the payload of a record of the tree heads file
+type treeHeadRecord struct {
+	Height    int    `json:"height"`
+	HeadHash  string `json:"headHash"`
+	Root      string `json:"root"`
+	Timestamp int64  `json:"timestamp"`
+	KeyID     string `json:"keyId"`
+	Signature string `json:"signature"`
+}
+
+func encodeTreeHead(head TreeHead) ([]byte, error) {
+	return json.Marshal(treeHeadRecord{
+		Height:    head.Height,
+		HeadHash:  hex.EncodeToString(head.HeadHash[:]),
+		Root:      hex.EncodeToString(head.Root[:]),
+		Timestamp: head.Timestamp,
+		KeyID:     head.KeyID,
+		Signature: hex.EncodeToString(head.Signature),
+	})
+}
+
+func decodeTreeHead(payload []byte) (TreeHead, error) {
+	var (
+		record treeHeadRecord
+		head   TreeHead
+	)
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return head, err
+	}
+	head.Height = record.Height
+	head.Timestamp = record.Timestamp
+	head.KeyID = record.KeyID
+	for _, field := range []struct {
+		value string
+		hash  *[sha256.Size]byte
+	}{{record.HeadHash, &head.HeadHash}, {record.Root, &head.Root}} {
+		raw, err := hex.DecodeString(field.value)
+		if err != nil || len(raw) != sha256.Size {
+			return head, errors.New(fmt.Sprintf("Hash %s is malformed", field.value))
+		}
+		copy(field.hash[:], raw)
+	}
+	signature, err := hex.DecodeString(record.Signature)
+	if err != nil {
+		return head, err
+	}
+	head.Signature = signature
+	return head, nil
+}
+
+// openTreeHeads method opens or creates the tree heads file of the store
+// and reads all heads. Like blocks, a torn final record is truncated and
+// other damage is reported as CorruptedStoreErr.
+func (s *FileStore) openTreeHeads(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	size := info.Size()
+	reader := bufio.NewReader(file)
+	var offset int64
+	for offset < size {
+		payload, err := readRecord(reader, size-offset)
+		end := offset + recordHeaderSize + int64(len(payload))
+		if err == io.ErrUnexpectedEOF || (err == CorruptedStoreErr && end >= size) {
+			if err := file.Truncate(offset); err != nil {
+				file.Close()
+				return err
+			}
+			break
+		}
+		var head TreeHead
+		if err == nil {
+			head, err = decodeTreeHead(payload)
+		}
+		if err != nil {
+			file.Close()
+			return errors.New(fmt.Sprintf("%s: tree head record at offset %d: %s", CorruptedStoreErr, offset, recordErr(err)))
+		}
+		s.heads = append(s.heads, head)
+		offset = end
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	s.headsFile = file
+	s.headsSize = offset
+	return nil
+}
+
+func (s *FileStore) LoadTreeHeads() ([]TreeHead, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]TreeHead{}, s.heads...), nil
+}
+
+func (s *FileStore) AppendTreeHead(head TreeHead) error {
+	payload, err := encodeTreeHead(head)
+	if err != nil {
+		return err
+	}
+	record := frameRecord(payload)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.headsFile == nil {
+		return ClosedStoreErr
+	}
+	_, err = s.headsFile.Write(record)
+	if err == nil {
+		err = s.headsFile.Sync()
+	}
+	if err != nil {
+		if s.headsFile.Truncate(s.headsSize) == nil {
+			s.headsFile.Seek(s.headsSize, io.SeekStart)
+		}
+		return err
+	}
+	s.headsSize += int64(len(record))
+	s.heads = append(s.heads, head)
+	return nil
+}
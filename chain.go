@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/starchain/blockchain"
+	"io"
+	"os"
+)
+
+const exportUsage = `Usage: starchain export -data file [-signing-key file] [-trusted-keys keys]
+                        [-chain-id id] [-genesis file] [-pow flags]
+                        [-format ndjson|binary] [OUT]
+
+Validates the chain stored in the data file and writes it to OUT,
+stdout when omitted.
+`
+
+const importUsage = `Usage: starchain import -data file [-signing-key file] [-trusted-keys keys]
+                        [-chain-id id] [-genesis file] [-pow flags] [IN]
+
+Validates every block of the export read from IN, stdin when omitted,
+and stores the chain in the data file, which must not exist yet.
+`
+
+// chainFlags struct holds flags shared by export and import
+type chainFlags struct {
+	dataPath       string
+	signingKeyPath string
+	trustedKeys    string
+	genesisPath    string
+	pow            powFlags
+	flags          *flag.FlagSet
+	config         blockchain.Config
+}
+
+// register method adds the shared flags to the flag set
+func (f *chainFlags) register(flags *flag.FlagSet) {
+	f.config = blockchain.DefaultConfig()
+	f.flags = flags
+	flags.StringVar(&f.dataPath, "data", "", "chain file of the node")
+	flags.StringVar(&f.signingKeyPath, "signing-key", "", "file with hex encoded Ed25519 seed of the node")
+	flags.StringVar(&f.trustedKeys, "trusted-keys", "", "comma separated hex encoded Ed25519 public keys of other block producers")
+	flags.StringVar(&f.config.ChainID, "chain-id", f.config.ChainID, "chain ID of the exported chain")
+	flags.StringVar(&f.genesisPath, "genesis", "", "JSON file defining the genesis block of the network")
+	f.pow.register(flags)
+}
+
+// blockchainConfig method returns config with keys read from the flags
+func (f *chainFlags) blockchainConfig() blockchain.Config {
+	config := f.config
+	if f.signingKeyPath != "" {
+		config.SigningKey = readSigningKey(f.signingKeyPath)
+	}
+	config.TrustedKeys = parseTrustedKeys(f.trustedKeys)
+	config.PoW = f.pow.powConfig()
+	if f.genesisPath != "" {
+		config = withGenesis(config, f.genesisPath, isFlagSet(f.flags, "chain-id"))
+	}
+	return config
+}
+
+// exportCommand fn runs `starchain export` subcommand and returns exit code
+func exportCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	var shared chainFlags
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	shared.register(flags)
+	format := flags.String("format", blockchain.ExportNDJSON, "ndjson or binary")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if shared.dataPath == "" || flags.NArg() > 1 {
+		fmt.Fprint(stderr, exportUsage)
+		return 2
+	}
+	if _, err := os.Stat(shared.dataPath); err != nil {
+		fmt.Fprintln(stderr, "ERR:", err)
+		return 1
+	}
+	store, err := blockchain.OpenFileStore(shared.dataPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "ERR:", err)
+		return 1
+	}
+	bchain, err := blockchain.Open(blockchain.BlockchainClock{}, shared.blockchainConfig(), store)
+	if err != nil {
+		store.Close()
+		fmt.Fprintln(stderr, "ERR:", err)
+		return 1
+	}
+	defer bchain.Close()
+	out := stdout
+	if flags.NArg() == 1 {
+		file, err := os.Create(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(stderr, "ERR:", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := bchain.Export(out, *format); err != nil {
+		fmt.Fprintln(stderr, "ERR:", err)
+		return 1
+	}
+	return 0
+}
+
+// importCommand fn runs `starchain import` subcommand and returns exit code.
+// The data file and its tree heads file are removed when the import fails.
+func importCommand(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	var shared chainFlags
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	shared.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if shared.dataPath == "" || flags.NArg() > 1 {
+		fmt.Fprint(stderr, importUsage)
+		return 2
+	}
+	if _, err := os.Stat(shared.dataPath); err == nil {
+		fmt.Fprintf(stderr, "ERR: %s already exists\n", shared.dataPath)
+		return 1
+	}
+	in := stdin
+	if flags.NArg() == 1 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(stderr, "ERR:", err)
+			return 1
+		}
+		defer file.Close()
+		in = file
+	}
+	config := shared.blockchainConfig()
+	store, err := blockchain.OpenFileStore(shared.dataPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "ERR:", err)
+		return 1
+	}
+	bchain, err := blockchain.Import(in, blockchain.BlockchainClock{}, config, store)
+	if err != nil {
+		store.Close()
+		os.Remove(shared.dataPath)
+		os.Remove(shared.dataPath + blockchain.TreeHeadsSuffix)
+		fmt.Fprintln(stderr, "ERR:", err)
+		return 1
+	}
+	defer bchain.Close()
+	fmt.Fprintf(stdout, "imported %d blocks\n", bchain.GetChainHeight())
+	return 0
+}
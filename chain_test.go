@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/starchain/blockchain"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// export fn runs the export command and returns its exit code and output
+func export(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := exportCommand(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// importChain fn runs the import command reading stdin and returns its
+// exit code and output
+func importChain(stdin io.Reader, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := importCommand(args, stdin, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// exists fn reports whether the file exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestExportImportCommands(t *testing.T) {
+	t.Log("export and import commands")
+	{
+		dir, err := ioutil.TempDir("", "starchain-chain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "chain.dat")
+		if err := writeChain(path, 3); err != nil {
+			t.Fatal(err)
+		}
+		keyPath := filepath.Join(dir, "signing.key")
+		ioutil.WriteFile(keyPath, []byte(hex.EncodeToString([]byte("starchain producer key - 32 byte"))+"\n"), 0600)
+		t.Log("\tGiven wrong arguments")
+		{
+			if code, _, stderr := export(); code != 2 || !strings.HasPrefix(stderr, "Usage: starchain export") {
+				t.Fatal("\t\tShould exit export with 2 and print usage without -data, got: ", code, stderr)
+			}
+			if code, _, stderr := importChain(strings.NewReader("")); code != 2 || !strings.HasPrefix(stderr, "Usage: starchain import") {
+				t.Fatal("\t\tShould exit import with 2 and print usage without -data, got: ", code, stderr)
+			}
+			if code, _, _ := export("-data", path, "a", "b"); code != 2 {
+				t.Fatal("\t\tShould exit export with 2 with extra arguments, got: ", code)
+			}
+			if code, _, _ := importChain(strings.NewReader(""), "-unknown"); code != 2 {
+				t.Fatal("\t\tShould exit import with 2 with unknown flag, got: ", code)
+			}
+			t.Log("\t\tShould exit with 2")
+			if code, _, stderr := export("-data", filepath.Join(dir, "missing.dat")); code != 1 || !strings.HasPrefix(stderr, "ERR:") {
+				t.Fatal("\t\tShould exit export with 1 without the data file, got: ", code, stderr)
+			}
+			t.Log("\t\tShould exit export with 1 without the data file")
+		}
+		t.Log("\tGiven exported chain")
+		{
+			code, exported, stderr := export("-data", path, "-signing-key", keyPath)
+			if code != 0 || len(strings.Split(strings.TrimSpace(exported), "\n")) != 5 {
+				t.Fatal("\t\tShould export the header and 4 blocks to stdout, got: ", code, stderr)
+			}
+			t.Log("\t\tShould export the header and 4 blocks to stdout")
+			imported := filepath.Join(dir, "imported.dat")
+			code, stdout, stderr := importChain(strings.NewReader(exported), "-data", imported, "-signing-key", keyPath)
+			if code != 0 || stdout != "imported 4 blocks\n" {
+				t.Fatal("\t\tShould import the chain from stdin, got: ", code, stdout, stderr)
+			}
+			if code, again, _ := export("-data", imported, "-signing-key", keyPath); code != 0 || again != exported {
+				t.Fatal("\t\tShould export the imported chain unchanged, got: ", code, again)
+			}
+			t.Log("\t\tShould round trip the chain")
+			binary := filepath.Join(dir, "chain.bin")
+			if code, stdout, _ := export("-data", path, "-signing-key", keyPath, "-format", "binary", binary); code != 0 || stdout != "" {
+				t.Fatal("\t\tShould export the chain to OUT, got: ", code, stdout)
+			}
+			importedBinary := filepath.Join(dir, "imported-binary.dat")
+			if code, stdout, stderr := importChain(strings.NewReader(""), "-data", importedBinary, "-signing-key", keyPath, binary); code != 0 || stdout != "imported 4 blocks\n" {
+				t.Fatal("\t\tShould import the binary export from IN, got: ", code, stdout, stderr)
+			}
+			t.Log("\t\tShould round trip the binary export through files")
+			content, _ := ioutil.ReadFile(imported)
+			code, _, stderr = importChain(strings.NewReader(exported), "-data", imported, "-signing-key", keyPath)
+			if code != 1 || stderr != "ERR: "+imported+" already exists\n" {
+				t.Fatal("\t\tShould exit with 1 when the data file exists, got: ", code, stderr)
+			}
+			if unchanged, _ := ioutil.ReadFile(imported); !bytes.Equal(unchanged, content) {
+				t.Fatal("\t\tShould not modify the existing data file")
+			}
+			t.Log("\t\tShould not import into existing data file")
+		}
+		t.Log("\tGiven export with bad header")
+		{
+			target := filepath.Join(dir, "bad-header.dat")
+			code, _, stderr := importChain(strings.NewReader("not a header\n"), "-data", target, "-signing-key", keyPath)
+			if code != 1 || !strings.Contains(stderr, "header is malformed") {
+				t.Fatal("\t\tShould exit with 1 and report the header, got: ", code, stderr)
+			}
+			if exists(target) || exists(target+blockchain.TreeHeadsSuffix) {
+				t.Fatal("\t\tShould remove the data files of the failed import")
+			}
+			t.Log("\t\tShould exit with 1 and leave no data files")
+		}
+		t.Log("\tGiven export with bad block")
+		{
+			_, exported, _ := export("-data", path, "-signing-key", keyPath)
+			lines := strings.Split(exported, "\n")
+			lines[3] = strings.Replace(lines[3], `"height":2`, `"height":5`, 1)
+			target := filepath.Join(dir, "bad-block.dat")
+			code, _, stderr := importChain(strings.NewReader(strings.Join(lines, "\n")), "-data", target, "-signing-key", keyPath)
+			if code != 1 || !strings.Contains(stderr, "block at height 2") {
+				t.Fatal("\t\tShould exit with 1 and report the height of the bad block, got: ", code, stderr)
+			}
+			if exists(target) || exists(target+blockchain.TreeHeadsSuffix) {
+				t.Fatal("\t\tShould remove the data files of the failed import")
+			}
+			t.Log("\t\tShould exit with 1 and report the height of the bad block")
+		}
+	}
+}
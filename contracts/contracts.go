@@ -1,5 +1,7 @@
 package contracts
 
+import "errors"
+
 type Block = struct {
 	Body              string
 	Hash              string
@@ -7,24 +9,200 @@ type Block = struct {
 	Owner             string
 	PreviousBlockHash string
 	Time              int64
+	Signature         string
+	KeyID             string
+	Message           string
+	MessageSignature  string
+	HashVersion       int
+	Difficulty        uint64
+	Nonce             uint64
 }
 
-type StarData struct {
+// ChallengeOwner is who the challenge is requested for: single Address,
+// or Threshold of Owners for stars co-owned through a multisig policy
+type ChallengeOwner struct {
 	Address   string
-	Message   string
-	Data      []byte
+	Owners    []string
+	Threshold int
+}
+
+// Challenge is issued to Address, the policy address for owners
+type Challenge struct {
+	Address      string
+	Message      string
+	IssuedAt     int64
+	ExpiresAt    int64
+	ValidFor     int64
+	MaxClockSkew int64
+}
+
+// StarCoordinates binds a challenge to a single star
+type StarCoordinates struct {
+	RA  string
+	Dec string
+}
+
+// StarData is the star submission. Stars co-owned through a multisig
+// policy address carry Signatures by owner address instead of Signature.
+type StarData struct {
+	Address    string
+	Message    string
+	Data       []byte
+	Signature  string
+	Signatures map[string]string
+}
+
+// ValidationOptions selects optional checks of the chain validation
+type ValidationOptions struct {
+	// VerifyProofs re-verifies ownership proofs stored in star blocks
+	VerifyProofs bool
+	// Full re-validates every block instead of only the blocks
+	// added after the last successful validation
+	Full bool
+}
+
+// Finding is a problem found in the block at Height, Kind is one of
+// hash-mismatch, prev-hash-mismatch, height-gap, timestamp-regression,
+// bad-genesis, genesis-owner, genesis-prev-hash, empty-owner, clock-drift,
+// bad-difficulty, insufficient-work, bad-producer or bad-proof. Expected and Actual are empty
+// when the kind has no values to compare.
+type Finding struct {
+	Kind     string
+	Height   int
+	Expected string
+	Actual   string
+	Message  string
+}
+
+// InclusionProof proves the block with BlockHash is at Height of the
+// chain committed to by Root, hashes are hex encoded. Path holds siblings
+// from the block up to the peak of its mountain, Peaks all peaks of the
+// Merkle Mountain Range of Size blocks.
+type InclusionProof struct {
+	Height    int
+	BlockHash string
+	Root      string
+	Size      uint64
+	Path      []string
+	Peaks     []string
+}
+
+// BlockRange is a run of consecutive blocks of the chain, each encoded
+// by the block JSON codec so followers can verify and append it.
+// ChainHeight is the number of blocks of the chain.
+type BlockRange struct {
+	ChainHeight int
+	Blocks      [][]byte
+}
+
+// TreeHead is the signed statement of the chain state: block at Height
+// with HeadHash is the last one and Root is the accumulator root over
+// Height+1 blocks. Hashes and the signature are hex encoded.
+type TreeHead struct {
+	Height    int
+	HeadHash  string
+	Root      string
+	Timestamp int64
+	KeyID     string
 	Signature string
 }
 
+// TreeHeadConsistency proves the accumulator with NewRoot over NewHeight+1
+// blocks extends the one with OldRoot over OldHeight+1 blocks: OldPeaks
+// are peaks of the older accumulator, Paths[i] holds siblings from
+// OldPeaks[i] up to its peak among NewPeaks. Hashes are hex encoded.
+type TreeHeadConsistency struct {
+	OldHeight   int
+	NewHeight   int
+	OldRoot     string
+	NewRoot     string
+	OldPeaks    []string
+	Paths       [][]string
+	NewPeaks    []string
+	OldHeadPath []string
+	NewHeadPath []string
+}
+
+// ValidationJob is the status of validation running in background,
+// State is one of running, done or cancelled
+type ValidationJob struct {
+	ID         string
+	State      string
+	Checked    int
+	Total      int
+	Valid      bool
+	ErrorLog   []string
+	Findings   []Finding
+	StartedAt  int64
+	FinishedAt int64
+}
+
+var (
+	JobRunningErr        = errors.New("Another validation job is running")
+	UnknownJobErr        = errors.New("Validation job not found")
+	OwnersWithAddressErr = errors.New("address and owners can not be used together")
+	InvalidOwnersErr     = errors.New("Invalid owners policy")
+	// TooManyChallengesErr is returned when the limit of outstanding
+	// challenges is reached, TooManyAddressChallengesErr when the limit
+	// of a single address is
+	TooManyChallengesErr        = errors.New("Too many outstanding challenges, try again later")
+	TooManyAddressChallengesErr = errors.New("Too many outstanding challenges of the address, try again later")
+)
+
 type BlockchainOperator interface {
-	RequestMessageOwnershipVerification(addr string) (string, error)
+	// RequestMessageOwnershipVerification returns OwnersWithAddressErr when
+	// both address and owners are given, or InvalidOwnersErr prefixed error
+	RequestMessageOwnershipVerification(owner ChallengeOwner, star *StarCoordinates) (Challenge, error)
 	GetBlockByHeight(h int) (Block, error)
 	GetBlockByHash(h string) (Block, error)
 	GetStarsByWalletAddress(addr string) []string
+	// GetBlocksSince returns blocks from the height on
+	GetBlocksSince(height int) (BlockRange, error)
+	// GetInclusionProof returns proof of the block at the height
+	// against the current accumulator root
+	GetInclusionProof(height int) (InclusionProof, error)
+	// GetLatestTreeHead returns the last published tree head
+	GetLatestTreeHead() (TreeHead, error)
+	// GetTreeHeads returns published tree heads from the oldest
+	GetTreeHeads() []TreeHead
+	// GetTreeHeadConsistency returns proof that the chain
+	// at newHeight extends the one at oldHeight
+	GetTreeHeadConsistency(oldHeight int, newHeight int) (TreeHeadConsistency, error)
 	SubmitStar(star StarData) (Block, error)
-	Validate() (bool, []string)
+	// Validate checks the chain with the options
+	Validate(opts ValidationOptions) (bool, []Finding)
+	// StartValidation validates the chain in background, returns
+	// JobRunningErr when another validation job is running
+	StartValidation(opts ValidationOptions) (ValidationJob, error)
+	// GetValidation returns status of the job or UnknownJobErr
+	GetValidation(id string) (ValidationJob, error)
+	// CancelValidation stops the job or returns UnknownJobErr
+	CancelValidation(id string) (ValidationJob, error)
 }
 
 type Clock interface {
 	GetTime() int64
 }
+
+// Verifier checks ownership proofs of a single address scheme,
+// e.g. Bitcoin signed messages or Ethereum personal_sign.
+type Verifier interface {
+	// Scheme returns unique name of the address scheme
+	Scheme() string
+	// Supports reports whether the address has the shape of the scheme.
+	// It does not check checksums, see ValidateAddress.
+	Supports(addr string) bool
+	// ValidateAddress returns error when the address is malformed
+	ValidateAddress(addr string) error
+	// Verify returns nil when sig is a valid signature of msg
+	// created by the owner of the address
+	Verify(addr string, msg string, sig string) error
+}
+
+// Canonicalizer is implemented by verifiers of schemes in which the same
+// address can be spelled in several ways, e.g. in upper or lower case.
+// Owners are stored and looked up in the canonical spelling.
+type Canonicalizer interface {
+	// CanonicalAddress returns the canonical spelling of a valid address
+	CanonicalAddress(addr string) (string, error)
+}
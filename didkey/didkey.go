@@ -0,0 +1,94 @@
+// didkey package provides verification of raw Ed25519 signatures created by
+// did:key identities, e.g. did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK
+package didkey
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"strings"
+)
+
+const (
+	// Scheme is the name of did:key address scheme
+	Scheme = "did:key"
+	// Prefix of every did:key identifier, multibase base58btc encoded
+	Prefix = "did:key:z"
+)
+
+// ed25519Codec is the multicodec code of Ed25519 public key (0xed) as varint
+var ed25519Codec = []byte{0xed, 0x01}
+
+var (
+	InvalidDIDErr         = errors.New("Identifier must be did:key with base58btc encoded Ed25519 public key")
+	UnsupportedKeyTypeErr = errors.New("Only Ed25519 did:key identifiers are supported")
+	MalformedSigErr       = errors.New("Signature must be base64 encoded 64 bytes Ed25519 signature")
+	AddrMismatchErr       = errors.New("Signature does not belong to the identifier")
+)
+
+// Verifier struct implements contracts.Verifier for did:key identifiers
+type Verifier struct{}
+
+func (v Verifier) Scheme() string {
+	return Scheme
+}
+
+// Supports method accepts every did:key identifier
+func (v Verifier) Supports(addr string) bool {
+	return strings.HasPrefix(addr, "did:key:")
+}
+
+func (v Verifier) ValidateAddress(addr string) error {
+	_, err := DecodeDID(addr)
+	return err
+}
+
+func (v Verifier) Verify(addr string, msg string, sig string) error {
+	return VerifyMessage(addr, msg, sig)
+}
+
+// DecodeDID fn returns Ed25519 public key encoded in the did:key identifier
+func DecodeDID(did string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(did, Prefix) {
+		return nil, InvalidDIDErr
+	}
+	decoded := base58.Decode(did[len(Prefix):])
+	if len(decoded) < len(ed25519Codec) {
+		return nil, InvalidDIDErr
+	}
+	if decoded[0] != ed25519Codec[0] || decoded[1] != ed25519Codec[1] {
+		return nil, UnsupportedKeyTypeErr
+	}
+	if len(decoded) != len(ed25519Codec)+ed25519.PublicKeySize {
+		return nil, InvalidDIDErr
+	}
+	return ed25519.PublicKey(decoded[len(ed25519Codec):]), nil
+}
+
+// EncodeDID fn returns did:key identifier of the Ed25519 public key
+func EncodeDID(pubKey ed25519.PublicKey) string {
+	return Prefix + base58.Encode(append(append([]byte{}, ed25519Codec...), pubKey...))
+}
+
+// VerifyMessage fn checks whether base64 encoded Ed25519 signature sig
+// was created over raw msg bytes by the key of the did:key identifier.
+func VerifyMessage(did string, msg string, sig string) error {
+	pubKey, err := DecodeDID(did)
+	if err != nil {
+		return err
+	}
+	rawSig, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil || len(rawSig) != ed25519.SignatureSize {
+		return MalformedSigErr
+	}
+	if !ed25519.Verify(pubKey, []byte(msg), rawSig) {
+		return AddrMismatchErr
+	}
+	return nil
+}
+
+// SignMessage fn creates base64 encoded Ed25519 signature of the message
+func SignMessage(key ed25519.PrivateKey, msg string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(msg)))
+}
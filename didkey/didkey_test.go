@@ -0,0 +1,92 @@
+package didkey
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+var (
+	seed = sha256.Sum256([]byte("did:key test seed"))
+	key  = ed25519.NewKeyFromSeed(seed[:])
+)
+
+func TestEncodeDID(t *testing.T) {
+	t.Log("EncodeDID")
+	{
+		t.Log("\tGiven Ed25519 public key")
+		{
+			did := EncodeDID(key.Public().(ed25519.PublicKey))
+			if !strings.HasPrefix(did, "did:key:z6Mk") {
+				t.Fatal("\t\tShould return did:key with Ed25519 multicodec prefix, got: ", did)
+			}
+			t.Log("\t\tShould return did:key with Ed25519 multicodec prefix")
+			pubKey, err := DecodeDID(did)
+			if err != nil {
+				t.Fatal("\t\tShould decode the identifier, got err: ", err)
+			}
+			if !pubKey.Equal(key.Public()) {
+				t.Fatal("\t\tShould decode the same key, got: ", pubKey)
+			}
+			t.Log("\t\tShould decode the same key")
+		}
+	}
+}
+
+func TestDecodeDID(t *testing.T) {
+	t.Log("DecodeDID")
+	{
+		cases := []struct {
+			did string
+			err error
+		}{
+			// Example from did:key specification
+			{"did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK", nil},
+			// secp256k1 key
+			{"did:key:zQ3shokFTS3brHcDQrn82RUDfCZESWL1ZdCEJwekUDPQiYBme", UnsupportedKeyTypeErr},
+			{"did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2d0K", InvalidDIDErr},
+			{"did:key:6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK", InvalidDIDErr},
+			{"did:web:example.com", InvalidDIDErr},
+		}
+		for _, c := range cases {
+			t.Log("\tGiven identifier", c.did)
+			{
+				if _, err := DecodeDID(c.did); err != c.err {
+					t.Fatalf("\t\tShould return err: %v, got: %v", c.err, err)
+				}
+				t.Log("\t\tShould decode identifier correctly")
+			}
+		}
+	}
+}
+
+func TestVerifyMessage(t *testing.T) {
+	t.Log("VerifyMessage")
+	{
+		did := EncodeDID(key.Public().(ed25519.PublicKey))
+		msg := "Sign me"
+		sig := SignMessage(key, msg)
+		t.Log("\tGiven signature created by the identifier key")
+		{
+			if err := VerifyMessage(did, msg, sig); err != nil {
+				t.Fatal("\t\tShould accept the signature, got err: ", err)
+			}
+			t.Log("\t\tShould accept the signature")
+		}
+		t.Log("\tGiven signature of other message")
+		{
+			if err := VerifyMessage(did, msg+"!", sig); err != AddrMismatchErr {
+				t.Fatal("\t\tShould return AddrMismatchErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the signature")
+		}
+		t.Log("\tGiven signature which is not base64")
+		{
+			if err := VerifyMessage(did, msg, "sig"); err != MalformedSigErr {
+				t.Fatal("\t\tShould return MalformedSigErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the signature")
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// ethereum package provides verification of Ethereum personal_sign (EIP-191)
+// signatures created by wallets such as MetaMask for 0x prefixed addresses.
+package ethereum
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"golang.org/x/crypto/sha3"
+	"strings"
+)
+
+const (
+	// Scheme is the name of Ethereum address scheme
+	Scheme = "ethereum"
+	// MessagePrefix is prepended to every message before hashing (EIP-191 v0x45)
+	MessagePrefix = "\x19Ethereum Signed Message:\n"
+	// AddressLen is the length of an address in bytes
+	AddressLen = 20
+	// SigLen is the length of r || s || v signature in bytes
+	SigLen = 65
+)
+
+var (
+	InvalidAddrErr         = errors.New("Address must be 0x followed by 40 hex characters")
+	InvalidAddrChecksumErr = errors.New("Address EIP-55 checksum is invalid")
+	MalformedSigErr        = errors.New("Signature must be 0x followed by 130 hex characters")
+	AddrMismatchErr        = errors.New("Signature does not belong to the address")
+)
+
+// Verifier struct implements contracts.Verifier for Ethereum addresses
+type Verifier struct{}
+
+func (v Verifier) Scheme() string {
+	return Scheme
+}
+
+// Supports method accepts every 0x prefixed address
+func (v Verifier) Supports(addr string) bool {
+	return strings.HasPrefix(addr, "0x") || strings.HasPrefix(addr, "0X")
+}
+
+// ValidateAddress method checks hex encoding and, for mixed case addresses,
+// the EIP-55 checksum. All lower or all upper case addresses have no checksum.
+func (v Verifier) ValidateAddress(addr string) error {
+	_, err := decodeAddress(addr)
+	return err
+}
+
+func (v Verifier) Verify(addr string, msg string, sig string) error {
+	return VerifyMessage(addr, msg, sig)
+}
+
+// CanonicalAddress method returns the EIP-55 checksummed address
+func (v Verifier) CanonicalAddress(addr string) (string, error) {
+	decoded, err := decodeAddress(addr)
+	if err != nil {
+		return "", err
+	}
+	return checksumAddress(decoded), nil
+}
+
+// HashMessage fn returns Keccak-256 hash of the message with
+// personal_sign prefix: "\x19Ethereum Signed Message:\n" + len(msg) + msg
+func HashMessage(msg string) []byte {
+	return keccak256([]byte(fmt.Sprintf("%s%d%s", MessagePrefix, len(msg), msg)))
+}
+
+// VerifyMessage fn checks whether hex encoded personal_sign signature sig
+// was created over msg by the owner of addr.
+// Recovery id v may be either 0/1 or 27/28.
+func VerifyMessage(addr string, msg string, sig string) error {
+	expected, err := decodeAddress(addr)
+	if err != nil {
+		return err
+	}
+	rawSig, err := decodeHex(sig)
+	if err != nil || len(rawSig) != SigLen {
+		return MalformedSigErr
+	}
+	v := rawSig[SigLen-1]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return MalformedSigErr
+	}
+	// RecoverCompact expects: 27 + recovery id, r, s (uncompressed key)
+	compact := make([]byte, SigLen)
+	compact[0] = 27 + v
+	copy(compact[1:], rawSig[:SigLen-1])
+	pubKey, _, err := ecdsa.RecoverCompact(compact, HashMessage(msg))
+	if err != nil {
+		return errors.New(fmt.Sprintf("Could not recover public key: %s", err))
+	}
+	if PubKeyToAddress(pubKey) != checksumAddress(expected) {
+		return AddrMismatchErr
+	}
+	return nil
+}
+
+// SignMessage fn creates hex encoded personal_sign signature of the message.
+// The recovery id is stored as 27/28, the same way MetaMask does it.
+func SignMessage(key *btcec.PrivateKey, msg string) string {
+	compact := ecdsa.SignCompact(key, HashMessage(msg), false)
+	sig := make([]byte, SigLen)
+	copy(sig, compact[1:])
+	sig[SigLen-1] = compact[0]
+	return "0x" + hex.EncodeToString(sig)
+}
+
+// PubKeyToAddress fn returns EIP-55 checksummed address of the public key
+func PubKeyToAddress(pubKey *btcec.PublicKey) string {
+	hash := keccak256(pubKey.SerializeUncompressed()[1:])
+	var addr [AddressLen]byte
+	copy(addr[:], hash[len(hash)-AddressLen:])
+	return checksumAddress(addr)
+}
+
+func decodeAddress(addr string) ([AddressLen]byte, error) {
+	var result [AddressLen]byte
+	if len(addr) != 2+2*AddressLen || !(strings.HasPrefix(addr, "0x") || strings.HasPrefix(addr, "0X")) {
+		return result, InvalidAddrErr
+	}
+	raw, err := hex.DecodeString(addr[2:])
+	if err != nil {
+		return result, InvalidAddrErr
+	}
+	copy(result[:], raw)
+	body := addr[2:]
+	if body != strings.ToLower(body) && body != strings.ToUpper(body) {
+		if checksumAddress(result)[2:] != body {
+			return result, InvalidAddrChecksumErr
+		}
+	}
+	return result, nil
+}
+
+// checksumAddress fn encodes address using EIP-55 mixed case checksum
+func checksumAddress(addr [AddressLen]byte) string {
+	lower := hex.EncodeToString(addr[:])
+	hash := keccak256([]byte(lower))
+	result := []byte(lower)
+	for i, c := range result {
+		nibble := hash[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		}
+		if c >= 'a' && nibble&0x0f >= 8 {
+			result[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(result)
+}
+
+func decodeHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	return hex.DecodeString(s)
+}
+
+func keccak256(data []byte) []byte {
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
@@ -0,0 +1,127 @@
+package ethereum
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"strings"
+	"testing"
+)
+
+var (
+	// Well known key 0x0123...0123 and its address (ethers.js docs)
+	keyHex = "0123456789012345678901234567890123456789012345678901234567890123"
+	addr   = "0x14791697260E4c9A71f18484C9f997B308e59325"
+)
+
+func testKey() *btcec.PrivateKey {
+	raw, _ := hex.DecodeString(keyHex)
+	key, _ := btcec.PrivKeyFromBytes(raw)
+	return key
+}
+
+func TestHashMessage(t *testing.T) {
+	t.Log("HashMessage")
+	{
+		t.Log("\tGiven message Hello World")
+		{
+			expected := "a1de988600a42c4b4ab089b619297c17d53cffae5d5120d82d8a92d0bb3b78f2"
+			if actual := fmt.Sprintf("%x", HashMessage("Hello World")); actual != expected {
+				t.Fatalf("\t\tShould return EIP-191 hash %s, got: %s", expected, actual)
+			}
+			t.Log("\t\tShould return EIP-191 hash")
+		}
+	}
+}
+
+func TestPubKeyToAddress(t *testing.T) {
+	t.Log("PubKeyToAddress")
+	{
+		t.Log("\tGiven well known private key")
+		{
+			if actual := PubKeyToAddress(testKey().PubKey()); actual != addr {
+				t.Fatalf("\t\tShould return checksummed address %s, got: %s", addr, actual)
+			}
+			t.Log("\t\tShould return checksummed address")
+		}
+	}
+}
+
+func TestCanonicalAddress(t *testing.T) {
+	t.Log("CanonicalAddress")
+	{
+		for _, spelling := range []string{addr, strings.ToLower(addr), "0X" + strings.ToUpper(addr[2:])} {
+			t.Log("\tGiven address", spelling)
+			{
+				if actual, err := (Verifier{}).CanonicalAddress(spelling); err != nil || actual != addr {
+					t.Fatalf("\t\tShould return checksummed address %s, got: %s %v", addr, actual, err)
+				}
+				t.Log("\t\tShould return checksummed address")
+			}
+		}
+		t.Log("\tGiven address with broken checksum")
+		{
+			broken := "0x14791697260e4C9A71f18484C9f997B308e59325"
+			if _, err := (Verifier{}).CanonicalAddress(broken); err != InvalidAddrChecksumErr {
+				t.Fatal("\t\tShould return InvalidAddrChecksumErr, got: ", err)
+			}
+			t.Log("\t\tShould return InvalidAddrChecksumErr")
+		}
+	}
+}
+
+func TestVerifyMessage(t *testing.T) {
+	t.Log("VerifyMessage")
+	{
+		msg := "Sign me"
+		sig := SignMessage(testKey(), msg)
+		t.Log("\tGiven signature created by the address owner")
+		{
+			if err := VerifyMessage(addr, msg, sig); err != nil {
+				t.Fatal("\t\tShould accept the signature, got err: ", err)
+			}
+			if err := VerifyMessage(strings.ToLower(addr), msg, sig); err != nil {
+				t.Fatal("\t\tShould accept lower case address, got err: ", err)
+			}
+			t.Log("\t\tShould accept the signature")
+		}
+		t.Log("\tGiven signature with 0/1 recovery id")
+		{
+			raw, _ := hex.DecodeString(sig[2:])
+			raw[SigLen-1] -= 27
+			if err := VerifyMessage(addr, msg, hex.EncodeToString(raw)); err != nil {
+				t.Fatal("\t\tShould accept the signature, got err: ", err)
+			}
+			t.Log("\t\tShould accept the signature")
+		}
+		t.Log("\tGiven signature of other message")
+		{
+			if err := VerifyMessage(addr, msg+"!", sig); err != AddrMismatchErr {
+				t.Fatal("\t\tShould return AddrMismatchErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the signature")
+		}
+		t.Log("\tGiven truncated signature")
+		{
+			if err := VerifyMessage(addr, msg, sig[:len(sig)-2]); err != MalformedSigErr {
+				t.Fatal("\t\tShould return MalformedSigErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the signature")
+		}
+		t.Log("\tGiven address with broken EIP-55 checksum")
+		{
+			broken := "0x14791697260e4C9A71f18484C9f997B308e59325"
+			if err := VerifyMessage(broken, msg, sig); err != InvalidAddrChecksumErr {
+				t.Fatal("\t\tShould return InvalidAddrChecksumErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the address")
+		}
+		t.Log("\tGiven too short address")
+		{
+			if err := VerifyMessage(addr[:40], msg, sig); err != InvalidAddrErr {
+				t.Fatal("\t\tShould return InvalidAddrErr, got: ", err)
+			}
+			t.Log("\t\tShould reject the address")
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/starchain/blockchain"
+	"io"
+)
+
+const fsckUsage = `Usage: starchain fsck -data file [-repair] [-backup file]
+
+Checks every block stored in the data file without starting the server
+and reports damaged heights. With -repair the file is copied to the backup
+file (data file with .bak suffix by default) and truncated after the last
+good block. Exits with 1 when damage is found and not repaired.
+`
+
+// fsckCommand fn runs `starchain fsck` subcommand and returns exit code
+func fsckCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dataPath := flags.String("data", "", "chain file of the node")
+	repair := flags.Bool("repair", false, "truncate the chain after the last good block")
+	backupPath := flags.String("backup", "", "file the data file is copied to before repair, must not exist")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *dataPath == "" || flags.NArg() > 0 {
+		fmt.Fprint(stderr, fsckUsage)
+		return 2
+	}
+	report, err := blockchain.CheckFile(*dataPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "ERR:", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "checked %d blocks (%d bytes)\n", report.Blocks, report.Size)
+	for _, finding := range report.Findings {
+		fmt.Fprintln(stdout, finding)
+	}
+	if len(report.Findings) == 0 {
+		fmt.Fprintln(stdout, "no damage found")
+		return 0
+	}
+	fmt.Fprintf(stdout, "first bad height %d, %d good blocks\n", report.GoodBlocks, report.GoodBlocks)
+	if !*repair {
+		return 1
+	}
+	if *backupPath == "" {
+		*backupPath = *dataPath + ".bak"
+	}
+	if err := blockchain.RepairFile(*dataPath, *backupPath, report); err != nil {
+		fmt.Fprintln(stderr, "ERR:", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "backup written to %s, truncated to %d blocks (%d bytes)\n", *backupPath, report.GoodBlocks, report.GoodSize)
+	return 0
+}
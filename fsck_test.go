@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"github.com/starchain/blockchain"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type BlockchainClockMock struct{}
+
+func (b BlockchainClockMock) GetTime() int64 {
+	return time.Date(2020, time.June, 14, 17, 46, 32, 0, time.UTC).Unix()
+}
+
+// writeChain fn stores the genesis block and n star blocks in the file
+func writeChain(path string, n int) error {
+	store, err := blockchain.OpenFileStore(path)
+	if err != nil {
+		return err
+	}
+	config := blockchain.Config{SigningKey: ed25519.NewKeyFromSeed([]byte("starchain producer key - 32 byte"))}
+	chain, err := blockchain.Open(BlockchainClockMock{}, config, store)
+	if err != nil {
+		return err
+	}
+	for i := 1; i <= n; i++ {
+		chain.AddBlock("1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe", []byte(fmt.Sprintf("Star %d", i)))
+	}
+	return chain.Close()
+}
+
+// fsck fn runs the fsck command and returns its exit code and output
+func fsck(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := fsckCommand(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestFsckCommand(t *testing.T) {
+	t.Log("fsck command")
+	{
+		dir, err := ioutil.TempDir("", "starchain-fsck")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "chain.dat")
+		if err := writeChain(path, 3); err != nil {
+			t.Fatal(err)
+		}
+		t.Log("\tGiven wrong arguments")
+		{
+			if code, _, stderr := fsck(); code != 2 || !strings.HasPrefix(stderr, "Usage: starchain fsck") {
+				t.Fatal("\t\tShould exit with 2 and print usage without -data, got: ", code, stderr)
+			}
+			if code, _, _ := fsck("-data", path, "extra"); code != 2 {
+				t.Fatal("\t\tShould exit with 2 with extra arguments, got: ", code)
+			}
+			if code, _, _ := fsck("-unknown"); code != 2 {
+				t.Fatal("\t\tShould exit with 2 with unknown flag, got: ", code)
+			}
+			t.Log("\t\tShould exit with 2")
+		}
+		t.Log("\tGiven undamaged file")
+		{
+			code, stdout, _ := fsck("-data", path)
+			if code != 0 || !strings.Contains(stdout, "checked 4 blocks") || !strings.Contains(stdout, "no damage found") {
+				t.Fatal("\t\tShould exit with 0, got: ", code, stdout)
+			}
+			t.Log("\t\tShould exit with 0")
+		}
+		t.Log("\tGiven file with damaged last block")
+		{
+			content, _ := ioutil.ReadFile(path)
+			damaged := append([]byte{}, content...)
+			damaged[len(damaged)-1] ^= 0xff
+			ioutil.WriteFile(path, damaged, 0644)
+			code, stdout, _ := fsck("-data", path)
+			if code != 1 || !strings.Contains(stdout, "first bad height 3, 3 good blocks") {
+				t.Fatal("\t\tShould exit with 1, got: ", code, stdout)
+			}
+			if unchanged, _ := ioutil.ReadFile(path); !bytes.Equal(unchanged, damaged) {
+				t.Fatal("\t\tShould not modify the file without -repair")
+			}
+			t.Log("\t\tShould exit with 1 without -repair")
+			code, stdout, _ = fsck("-data", path, "-repair")
+			backup := path + ".bak"
+			if code != 0 || !strings.Contains(stdout, "backup written to "+backup+", truncated to 3 blocks") {
+				t.Fatal("\t\tShould repair the file, got: ", code, stdout)
+			}
+			if saved, _ := ioutil.ReadFile(backup); !bytes.Equal(saved, damaged) {
+				t.Fatal("\t\tShould write the backup to the default path")
+			}
+			t.Log("\t\tShould write the backup to the default path")
+			if code, stdout, _ := fsck("-data", path); code != 0 || !strings.Contains(stdout, "checked 3 blocks") {
+				t.Fatal("\t\tShould leave 3 good blocks, got: ", code, stdout)
+			}
+			t.Log("\t\tShould repair the file")
+			ioutil.WriteFile(path, damaged, 0644)
+			if code, _, stderr := fsck("-data", path, "-repair"); code != 1 || !strings.Contains(stderr, "ERR:") {
+				t.Fatal("\t\tShould exit with 1 when the backup exists, got: ", code, stderr)
+			}
+			t.Log("\t\tShould not overwrite the backup")
+		}
+	}
+}
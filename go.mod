@@ -1,3 +1,11 @@
 module github.com/starchain
 
 go 1.14
+
+require (
+	github.com/btcsuite/btcd v0.24.2
+	github.com/btcsuite/btcd/btcec/v2 v2.3.4
+	github.com/btcsuite/btcd/btcutil v1.1.6
+	github.com/btcsuite/btcd/chaincfg/chainhash v1.1.0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+)
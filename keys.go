@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"github.com/starchain/keystore"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const keysUsage = `Usage: starchain keys <command> [-keystore file] [args]
+
+Commands:
+  generate -type TYPE NAME  generate a new key, TYPE is one of: %s
+  list                      print names, types and addresses of all keys
+  sign NAME [MESSAGE]       sign the message, read from stdin when omitted
+                            (without the trailing newline)
+`
+
+// keysCommand fn runs `starchain keys` subcommand and returns exit code
+func keysCommand(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintf(stderr, keysUsage, strings.Join(keystore.Types(), ", "))
+		return 2
+	}
+	flags := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := flags.String("keystore", keystore.DefaultPath, "keystore file")
+	keyType := flags.String("type", keystore.BitcoinP2PKH, "type of the generated key")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	ks, err := keystore.Open(*path)
+	if err != nil {
+		fmt.Fprintln(stderr, "ERR:", err)
+		return 1
+	}
+	switch args[0] {
+	case "generate":
+		if flags.NArg() != 1 {
+			fmt.Fprintf(stderr, keysUsage, strings.Join(keystore.Types(), ", "))
+			return 2
+		}
+		key, err := ks.Generate(flags.Arg(0), *keyType, rand.Reader)
+		if err == nil {
+			err = ks.Save()
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "ERR:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, key.Address)
+	case "list":
+		for _, key := range ks.List() {
+			fmt.Fprintf(stdout, "%s\t%s\t%s\n", key.Name, key.Type, key.Address)
+		}
+	case "sign":
+		var msg string
+		switch flags.NArg() {
+		case 1:
+			content, err := ioutil.ReadAll(stdin)
+			if err != nil {
+				fmt.Fprintln(stderr, "ERR:", err)
+				return 1
+			}
+			// Shell tools such as echo and jq -r end the output with a newline
+			msg = strings.TrimSuffix(string(content), "\n")
+		case 2:
+			msg = flags.Arg(1)
+		default:
+			fmt.Fprintf(stderr, keysUsage, strings.Join(keystore.Types(), ", "))
+			return 2
+		}
+		sig, err := ks.Sign(flags.Arg(0), msg)
+		if err != nil {
+			fmt.Fprintln(stderr, "ERR:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, sig)
+	default:
+		fmt.Fprintf(stderr, keysUsage, strings.Join(keystore.Types(), ", "))
+		return 2
+	}
+	return 0
+}
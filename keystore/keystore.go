@@ -0,0 +1,228 @@
+// keystore package provides a local key file for development and CI.
+// It generates keys of every supported address scheme and signs challenges
+// the same way wallets do, so the whole request-sign-submit flow can run
+// without an external wallet. Private keys are stored unencrypted.
+package keystore
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/starchain/bitcoin"
+	"github.com/starchain/didkey"
+	"github.com/starchain/ethereum"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Key types which can be generated
+const (
+	BitcoinP2PKH      = "bitcoin-p2pkh"
+	BitcoinP2SHP2WPKH = "bitcoin-p2sh-p2wpkh"
+	BitcoinP2WPKH     = "bitcoin-p2wpkh"
+	BitcoinP2TR       = "bitcoin-p2tr"
+	Ethereum          = "ethereum"
+	DIDKey            = "did:key"
+	// Producer is the Ed25519 key a node signs blocks with
+	Producer = "producer"
+)
+
+// DefaultPath is the keystore file used when none is given
+const DefaultPath = "starchain-keys.json"
+
+var (
+	UnknownKeyTypeErr = errors.New("Unknown key type")
+	DuplicateKeyErr   = errors.New("Key with this name already exists")
+	KeyNotFoundErr    = errors.New("Key not found")
+	EmptyNameErr      = errors.New("Key name is empty")
+	MalformedKeyErr   = errors.New("Private key in the keystore is malformed")
+)
+
+// Key struct is a single keystore entry.
+// Address is the owner address, or hex encoded public key of Producer keys.
+type Key struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Address    string `json:"address"`
+	PrivateKey string `json:"privateKey"`
+}
+
+// Keystore struct holds keys read from a keystore file
+type Keystore struct {
+	path string
+	keys map[string]Key
+}
+
+// Types fn returns every key type which can be generated
+func Types() []string {
+	return []string{
+		BitcoinP2PKH,
+		BitcoinP2SHP2WPKH,
+		BitcoinP2WPKH,
+		BitcoinP2TR,
+		Ethereum,
+		DIDKey,
+		Producer,
+	}
+}
+
+// Open fn reads the keystore file, missing file is an empty keystore
+func Open(path string) (*Keystore, error) {
+	ks := Keystore{path: path, keys: make(map[string]Key)}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &ks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []Key
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not decode keystore %s: %s", path, err))
+	}
+	for _, k := range keys {
+		ks.keys[k.Name] = k
+	}
+	return &ks, nil
+}
+
+// Save method writes the keystore file readable only by the user.
+// The file is replaced atomically.
+func (ks *Keystore) Save() error {
+	content, err := json.MarshalIndent(ks.List(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(ks.path), ".keystore-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), ks.path)
+}
+
+// List method returns keys sorted by name
+func (ks *Keystore) List() []Key {
+	keys := make([]Key, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+// Get method returns the key by name
+func (ks *Keystore) Get(name string) (Key, error) {
+	k, ok := ks.keys[name]
+	if !ok {
+		return Key{}, KeyNotFoundErr
+	}
+	return k, nil
+}
+
+// Generate method creates a new key of the type using random
+// and adds it to the keystore. Call Save to persist it.
+func (ks *Keystore) Generate(name string, keyType string, random io.Reader) (Key, error) {
+	if name == "" {
+		return Key{}, EmptyNameErr
+	}
+	if _, ok := ks.keys[name]; ok {
+		return Key{}, DuplicateKeyErr
+	}
+	var seed [32]byte
+	if _, err := io.ReadFull(random, seed[:]); err != nil {
+		return Key{}, errors.New(fmt.Sprintf("Could not generate key: %s", err))
+	}
+	k := Key{Name: name, Type: keyType, PrivateKey: hex.EncodeToString(seed[:])}
+	address, err := k.address()
+	if err != nil {
+		return Key{}, err
+	}
+	k.Address = address
+	ks.keys[name] = k
+	return k, nil
+}
+
+// Sign method signs the message with the named key using the signature
+// format expected by the verifier of its address scheme
+func (ks *Keystore) Sign(name string, msg string) (string, error) {
+	k, err := ks.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return k.Sign(msg)
+}
+
+// Sign method signs the message with the key
+func (k Key) Sign(msg string) (string, error) {
+	raw, err := hex.DecodeString(k.PrivateKey)
+	if err != nil || len(raw) != 32 {
+		return "", MalformedKeyErr
+	}
+	switch k.Type {
+	case DIDKey, Producer:
+		return didkey.SignMessage(ed25519.NewKeyFromSeed(raw), msg), nil
+	}
+	key, _ := btcec.PrivKeyFromBytes(raw)
+	switch k.Type {
+	case BitcoinP2PKH:
+		return bitcoin.SignMessage(key, msg, true), nil
+	case BitcoinP2SHP2WPKH:
+		return bitcoin.SignMessageBIP137(key, msg, bitcoin.P2SHP2WPKH)
+	case BitcoinP2WPKH:
+		return bitcoin.SignMessageBIP137(key, msg, bitcoin.P2WPKH)
+	case BitcoinP2TR:
+		return bitcoin.SignMessageBIP322(key, msg)
+	case Ethereum:
+		return ethereum.SignMessage(key, msg), nil
+	}
+	return "", UnknownKeyTypeErr
+}
+
+// address method derives the address of the key
+func (k Key) address() (string, error) {
+	raw, err := hex.DecodeString(k.PrivateKey)
+	if err != nil || len(raw) != 32 {
+		return "", MalformedKeyErr
+	}
+	switch k.Type {
+	case DIDKey:
+		return didkey.EncodeDID(ed25519.NewKeyFromSeed(raw).Public().(ed25519.PublicKey)), nil
+	case Producer:
+		pubKey := ed25519.NewKeyFromSeed(raw).Public().(ed25519.PublicKey)
+		return hex.EncodeToString(pubKey), nil
+	}
+	_, pubKey := btcec.PrivKeyFromBytes(raw)
+	switch k.Type {
+	case BitcoinP2PKH:
+		return bitcoin.P2PKHAddress(pubKey, true), nil
+	case BitcoinP2SHP2WPKH:
+		return bitcoin.P2SHP2WPKHAddress(pubKey), nil
+	case BitcoinP2WPKH:
+		return bitcoin.P2WPKHAddress(pubKey), nil
+	case BitcoinP2TR:
+		return bitcoin.P2TRAddress(pubKey), nil
+	case Ethereum:
+		return ethereum.PubKeyToAddress(pubKey), nil
+	}
+	return "", UnknownKeyTypeErr
+}
@@ -0,0 +1,120 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/starchain/blockchain"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var msg = "starchain.local wants you to prove ownership of the address:\n..."
+
+func TestGenerate(t *testing.T) {
+	t.Log("Generate")
+	{
+		ks, _ := Open(filepath.Join(os.TempDir(), "starchain-missing-keystore.json"))
+		random := bytes.NewReader(bytes.Repeat([]byte{0x42}, 32*len(Types())))
+		verifiers := blockchain.DefaultVerifiers()
+		for _, keyType := range Types() {
+			t.Log("\tGiven key type ", keyType)
+			{
+				key, err := ks.Generate(keyType, keyType, random)
+				if err != nil {
+					t.Fatal("\t\tShould generate the key, got err: ", err)
+				}
+				t.Log("\t\tShould generate the key: ", key.Address)
+				sig, err := ks.Sign(keyType, msg)
+				if err != nil {
+					t.Fatal("\t\tShould sign the message, got err: ", err)
+				}
+				if keyType == Producer {
+					pubKey, _ := hex.DecodeString(key.Address)
+					rawSig, _ := base64.StdEncoding.DecodeString(sig)
+					if !ed25519.Verify(pubKey, []byte(msg), rawSig) {
+						t.Fatal("\t\tShould create valid Ed25519 signature")
+					}
+					t.Log("\t\tShould create valid Ed25519 signature")
+					continue
+				}
+				verified := false
+				for _, v := range verifiers {
+					if v.Supports(key.Address) {
+						if err := v.Verify(key.Address, msg, sig); err != nil {
+							t.Fatal("\t\tShould create signature accepted by the verifier, got err: ", err)
+						}
+						verified = true
+						break
+					}
+				}
+				if !verified {
+					t.Fatal("\t\tShould generate address supported by a verifier")
+				}
+				t.Log("\t\tShould create signature accepted by the verifier")
+			}
+		}
+		t.Log("\tGiven name which already exists")
+		{
+			if _, err := ks.Generate(Ethereum, Ethereum, bytes.NewReader(make([]byte, 32))); err != DuplicateKeyErr {
+				t.Fatal("\t\tShould return DuplicateKeyErr, got: ", err)
+			}
+			t.Log("\t\tShould return DuplicateKeyErr")
+		}
+		t.Log("\tGiven unknown key type")
+		{
+			if _, err := ks.Generate("other", "dogecoin", bytes.NewReader(make([]byte, 32))); err != UnknownKeyTypeErr {
+				t.Fatal("\t\tShould return UnknownKeyTypeErr, got: ", err)
+			}
+			if _, err := ks.Get("other"); err != KeyNotFoundErr {
+				t.Fatal("\t\tShould not add the key, got: ", err)
+			}
+			t.Log("\t\tShould return UnknownKeyTypeErr")
+		}
+	}
+}
+
+func TestSaveOpen(t *testing.T) {
+	t.Log("Save and Open")
+	{
+		dir, err := ioutil.TempDir("", "starchain-keystore")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, DefaultPath)
+		t.Log("\tGiven saved keystore")
+		{
+			ks, _ := Open(path)
+			key, _ := ks.Generate("alice", BitcoinP2TR, bytes.NewReader(bytes.Repeat([]byte{0x07}, 32)))
+			if err := ks.Save(); err != nil {
+				t.Fatal("\t\tShould save the keystore, got err: ", err)
+			}
+			info, err := os.Stat(path)
+			if err != nil || info.Mode().Perm() != 0600 {
+				t.Fatal("\t\tShould save file readable only by the user, got: ", info, err)
+			}
+			t.Log("\t\tShould save file readable only by the user")
+			reopened, err := Open(path)
+			if err != nil {
+				t.Fatal("\t\tShould open the keystore, got err: ", err)
+			}
+			loaded, err := reopened.Get("alice")
+			if err != nil || loaded != key {
+				t.Fatal("\t\tShould load the same key, got: ", loaded, err)
+			}
+			t.Log("\t\tShould load the same key")
+		}
+		t.Log("\tGiven malformed keystore file")
+		{
+			ioutil.WriteFile(path, []byte("not json"), 0600)
+			if _, err := Open(path); err == nil {
+				t.Fatal("\t\tShould return err, got nil")
+			}
+			t.Log("\t\tShould return err")
+		}
+	}
+}
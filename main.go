@@ -1,24 +1,251 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
 	"github.com/starchain/api"
+	"github.com/starchain/block"
 	"github.com/starchain/blockchain"
 	"github.com/starchain/contracts"
 	"github.com/starchain/proxy"
+	"github.com/starchain/replica"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout is the time in-flight requests have to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "keys":
+			os.Exit(keysCommand(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "export":
+			os.Exit(exportCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "import":
+			os.Exit(importCommand(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "fsck":
+			os.Exit(fsckCommand(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
 	log.Println("Hello StarchainGo!")
 	var (
-		bchain          *blockchain.Blockchain
 		clock           contracts.Clock
 		blockchainProxy contracts.BlockchainOperator
+		config          blockchain.Config = blockchain.DefaultConfig()
+		signingKeyPath  string
+		trustedKeys     string
+		dataPath        string
+		genesisPath     string
+		pow             powFlags
+		addr            string
+		adminTokenPath  string
+		adminToken      string
+		leader          string
+		pollInterval    int64
+		store           blockchain.Store = blockchain.NewMemoryStore()
 	)
+	flag.Int64Var(&config.ChallengeTTL, "challenge-ttl", config.ChallengeTTL, "number of seconds a challenge can be used to submit a star")
+	flag.Int64Var(&config.MaxClockSkew, "max-clock-skew", config.MaxClockSkew, "number of seconds a message timestamp may be ahead of the server clock")
+	flag.Int64Var(&config.MaxClockDrift, "max-clock-drift", config.MaxClockDrift, "number of seconds a block timestamp may be ahead of the server clock")
+	flag.Int64Var(&config.TreeHeadInterval, "tree-head-interval", 0, "number of seconds between signed tree heads, disabled when 0")
+	flag.StringVar(&config.Domain, "domain", config.Domain, "domain put in every challenge message")
+	flag.StringVar(&config.ChainID, "chain-id", config.ChainID, "chain ID put in every challenge message")
+	flag.BoolVar(&config.LegacyChallenges, "legacy-challenges", false, "issue and accept legacy addr:ts:starRegistry messages")
+	flag.StringVar(&signingKeyPath, "signing-key", "", "file with hex encoded Ed25519 seed the node signs blocks with, random key when empty")
+	flag.StringVar(&trustedKeys, "trusted-keys", "", "comma separated hex encoded Ed25519 public keys of other block producers")
+	flag.StringVar(&dataPath, "data", "", "append-only file the chain is stored in, in memory only when empty")
+	flag.StringVar(&genesisPath, "genesis", "", "JSON file defining the genesis block of the network")
+	flag.StringVar(&addr, "addr", ":8000", "address the REST API listens on")
+	flag.StringVar(&adminTokenPath, "admin-token", "", "file with the bearer token of admin endpoints, they are disabled when empty")
+	flag.StringVar(&leader, "follow", "", "URL of the leader node to replicate the chain from, writes are redirected to it")
+	flag.Int64Var(&pollInterval, "follow-interval", replica.DefaultPollInterval, "number of seconds between polls of the leader")
+	pow.register(flag.CommandLine)
+	flag.Parse()
+	config.PoW = pow.powConfig()
+	if genesisPath != "" {
+		config = withGenesis(config, genesisPath, isFlagSet(flag.CommandLine, "chain-id"))
+	}
+	if signingKeyPath != "" {
+		config.SigningKey = readSigningKey(signingKeyPath)
+	}
+	config.TrustedKeys = parseTrustedKeys(trustedKeys)
+	if adminTokenPath != "" {
+		adminToken = readAdminToken(adminTokenPath)
+	}
 	clock = blockchain.BlockchainClock{}
-	bchain = blockchain.New(clock)
+	if dataPath != "" {
+		if config.SigningKey == nil && leader == "" {
+			// Blocks signed by a random key do not validate after restart,
+			// blocks of a follower are signed by the leader
+			log.Fatal("-data requires -signing-key")
+		}
+		fileStore, err := blockchain.OpenFileStore(dataPath)
+		if err != nil {
+			log.Fatal("Could not open chain file: ", err)
+		}
+		store = fileStore
+	}
+	var (
+		bchain   *blockchain.Blockchain
+		follower *replica.Follower
+		err      error
+	)
+	if leader != "" {
+		if follower, err = replica.Open(context.Background(), leader, nil, clock, config, store); err == nil {
+			bchain = follower.Blockchain()
+		}
+	} else {
+		bchain, err = blockchain.Open(clock, config, store)
+	}
+	if err != nil {
+		log.Fatal("Could not load the chain: ", err)
+	}
+	log.Printf("INFO: chain height %d", bchain.GetChainHeight())
+	producerKey := bchain.GetProducerKey()
+	log.Printf("INFO: signing blocks with key %s (%x)", block.KeyID(producerKey), []byte(producerKey))
 	blockchainProxy = proxy.New(bchain)
-	restApi := api.Create(&blockchainProxy)
-	http.ListenAndServe(":8000", restApi)
+	var restApi http.Handler
+	followCtx, stopFollowing := context.WithCancel(context.Background())
+	following := make(chan struct{})
+	if follower != nil {
+		log.Printf("INFO: following leader %s", follower.Leader())
+		restApi = api.CreateFollower(&blockchainProxy, follower.Leader(), adminToken)
+		go func() {
+			follower.Run(followCtx, pollInterval)
+			close(following)
+		}()
+	} else {
+		restApi = api.Create(&blockchainProxy, adminToken)
+		close(following)
+	}
+	server := &http.Server{Addr: addr, Handler: restApi}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	drained := make(chan struct{})
+	go func() {
+		<-stop
+		log.Println("INFO: shutting down")
+		// Pending submissions return instead of mining until the timeout,
+		// the chain stays open for requests which are still served
+		bchain.StopMining()
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Println("ERR: shutdown: ", err)
+		}
+		close(drained)
+	}()
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Println("ERR: ", err)
+	} else {
+		<-drained
+	}
+	stopFollowing()
+	<-following
+	if err := bchain.Close(); err != nil {
+		log.Println("ERR: could not close the chain: ", err)
+	}
+}
+
+// readSigningKey fn reads hex encoded Ed25519 seed from the file
+func readSigningKey(path string) ed25519.PrivateKey {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Panic("Could not read signing key: ", err)
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		log.Panicf("Signing key must be hex encoded %d bytes seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed)
+}
+
+// readAdminToken fn reads the admin token from the file,
+// surrounding whitespace is dropped
+func readAdminToken(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Panic("Could not read admin token: ", err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		log.Panic("Admin token file is empty: ", path)
+	}
+	return token
+}
+
+// parseTrustedKeys fn decodes comma separated hex encoded public keys
+func parseTrustedKeys(keys string) []ed25519.PublicKey {
+	var result []ed25519.PublicKey
+	for _, k := range strings.Split(keys, ",") {
+		if k = strings.TrimSpace(k); k == "" {
+			continue
+		}
+		pubKey, err := hex.DecodeString(k)
+		if err != nil || len(pubKey) != ed25519.PublicKeySize {
+			log.Panicf("Trusted key %s must be hex encoded %d bytes", k, ed25519.PublicKeySize)
+		}
+		result = append(result, ed25519.PublicKey(pubKey))
+	}
+	return result
+}
+
+// withGenesis fn returns config with the genesis read from the file.
+// Chain ID of the genesis is used unless it was set explicitly,
+// Open rejects the config when they differ.
+func withGenesis(config blockchain.Config, path string, chainIDSet bool) blockchain.Config {
+	genesis, err := blockchain.LoadGenesis(path)
+	if err != nil {
+		log.Panic("Could not load genesis: ", err)
+	}
+	if !chainIDSet {
+		config.ChainID = genesis.ChainID
+	}
+	config.Genesis = &genesis
+	return config
+}
+
+// isFlagSet fn reports whether the flag was given on the command line
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// powFlags struct holds proof-of-work flags
+type powFlags struct {
+	enabled bool
+	config  blockchain.PoWConfig
+}
+
+// register method adds proof-of-work flags to the flag set
+func (f *powFlags) register(flags *flag.FlagSet) {
+	flags.BoolVar(&f.enabled, "pow", false, "mine new blocks with proof-of-work")
+	flags.Uint64Var(&f.config.InitialDifficulty, "pow-difficulty", blockchain.DefaultInitialDifficulty, "average number of hashes the first mined blocks take")
+	flags.IntVar(&f.config.RetargetInterval, "pow-retarget", blockchain.DefaultRetargetInterval, "number of blocks between difficulty changes")
+	flags.Int64Var(&f.config.BlockInterval, "pow-block-interval", blockchain.DefaultBlockInterval, "number of seconds mining of a block should take")
+	flags.IntVar(&f.config.ActivationHeight, "pow-activation", 1, "height of the first mined block")
+}
+
+// powConfig method returns proof-of-work config, nil when it is disabled
+func (f *powFlags) powConfig() *blockchain.PoWConfig {
+	if !f.enabled {
+		return nil
+	}
+	config := f.config
+	return &config
 }
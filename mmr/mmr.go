@@ -0,0 +1,265 @@
+// mmr package provides Merkle Mountain Range, an append-only accumulator
+// over block hashes. Leaves are grouped into perfect binary trees
+// (mountains) of decreasing height, the root commits to their peaks.
+// Verify checks an inclusion proof with nothing but the root, so a third
+// party can check a star is in the registry without the chain.
+// VerifyConsistency checks that a newer root extends an older one,
+// so an operator who rewrote history is caught.
+package mmr
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/bits"
+)
+
+const (
+	// leafPrefix and nodePrefix separate leaf hashes from node hashes,
+	// so an inner node can not be passed off as a leaf
+	leafPrefix byte = 0
+	nodePrefix byte = 1
+)
+
+var (
+	IndexErr          = errors.New("Leaf index is out of range")
+	MalformedProofErr = errors.New("Proof does not match the accumulator size")
+	RootMismatchErr   = errors.New("Proof does not lead to the root")
+)
+
+// MMR struct is the accumulator, levels[0] are leaf hashes and
+// levels[h] are roots of complete subtrees of height h
+type MMR struct {
+	levels [][][sha256.Size]byte
+}
+
+// ConsistencyProof struct proves the accumulator of NewSize leaves extends
+// the one of OldSize leaves: every old peak is a node of the new
+// accumulator. Paths[i] holds siblings from OldPeaks[i] up to the peak
+// of its new mountain, NewPeaks holds all peaks of the new accumulator.
+type ConsistencyProof struct {
+	OldSize  uint64
+	NewSize  uint64
+	OldPeaks [][sha256.Size]byte
+	Paths    [][][sha256.Size]byte
+	NewPeaks [][sha256.Size]byte
+}
+
+// Proof struct proves inclusion of the leaf at Index in the accumulator
+// of Size leaves. Path holds siblings from the leaf up to the peak of its
+// mountain, Peaks holds all peaks from the highest mountain to the lowest.
+type Proof struct {
+	Index uint64
+	Size  uint64
+	Path  [][sha256.Size]byte
+	Peaks [][sha256.Size]byte
+}
+
+// New fn returns empty accumulator
+func New() *MMR {
+	return &MMR{}
+}
+
+// Append method adds the hash as the next leaf and merges
+// mountains of equal height
+func (m *MMR) Append(hash [sha256.Size]byte) {
+	node := leafHash(hash)
+	for h := 0; ; h++ {
+		if h == len(m.levels) {
+			m.levels = append(m.levels, nil)
+		}
+		m.levels[h] = append(m.levels[h], node)
+		n := len(m.levels[h])
+		if n%2 == 1 {
+			return
+		}
+		node = nodeHash(m.levels[h][n-2], m.levels[h][n-1])
+	}
+}
+
+// Size method returns the number of leaves
+func (m *MMR) Size() uint64 {
+	if len(m.levels) == 0 {
+		return 0
+	}
+	return uint64(len(m.levels[0]))
+}
+
+// Root method returns the peaks bagged into a single hash,
+// zero hash when the accumulator is empty
+func (m *MMR) Root() [sha256.Size]byte {
+	return bag(m.peaks(m.Size()))
+}
+
+// RootAt method returns root the accumulator had with size leaves
+func (m *MMR) RootAt(size uint64) ([sha256.Size]byte, error) {
+	if size > m.Size() {
+		return [sha256.Size]byte{}, IndexErr
+	}
+	return bag(m.peaks(size)), nil
+}
+
+// Proof method returns inclusion proof of the leaf at the index
+// against the current root
+func (m *MMR) Proof(index uint64) (Proof, error) {
+	return m.ProofAt(index, m.Size())
+}
+
+// ProofAt method returns inclusion proof of the leaf at the index
+// against the root the accumulator had with size leaves
+func (m *MMR) ProofAt(index uint64, size uint64) (Proof, error) {
+	if index >= size || size > m.Size() {
+		return Proof{}, IndexErr
+	}
+	return Proof{Index: index, Size: size, Path: m.path(size, 0, index), Peaks: m.peaks(size)}, nil
+}
+
+// ConsistencyProof method returns proof that the accumulator of newSize
+// leaves extends the one of oldSize leaves
+func (m *MMR) ConsistencyProof(oldSize uint64, newSize uint64) (ConsistencyProof, error) {
+	if oldSize == 0 || oldSize > newSize || newSize > m.Size() {
+		return ConsistencyProof{}, IndexErr
+	}
+	proof := ConsistencyProof{OldSize: oldSize, NewSize: newSize, NewPeaks: m.peaks(newSize)}
+	for _, mountain := range mountains(oldSize) {
+		index := mountain.start >> mountain.height
+		proof.OldPeaks = append(proof.OldPeaks, m.levels[mountain.height][index])
+		proof.Paths = append(proof.Paths, m.path(newSize, mountain.height, index))
+	}
+	return proof, nil
+}
+
+// path method returns siblings of the node at the level and index up to
+// the peak of its mountain in the accumulator of size leaves
+func (m *MMR) path(size uint64, level int, index uint64) [][sha256.Size]byte {
+	var path [][sha256.Size]byte
+	for h := level; h < len(m.levels); h++ {
+		sibling := index ^ 1
+		if sibling >= size>>uint(h) {
+			break
+		}
+		path = append(path, m.levels[h][sibling])
+		index /= 2
+	}
+	return path
+}
+
+// peaks method returns roots of the mountains of the accumulator
+// of size leaves, from the highest to the lowest
+func (m *MMR) peaks(size uint64) [][sha256.Size]byte {
+	var peaks [][sha256.Size]byte
+	for _, mountain := range mountains(size) {
+		peaks = append(peaks, m.levels[mountain.height][mountain.start>>mountain.height])
+	}
+	return peaks
+}
+
+// mountain struct is a perfect tree of 2^height leaves from start
+type mountain struct {
+	start  uint64
+	height int
+}
+
+// mountains fn splits size leaves into mountains, one per set bit
+// of size from the highest. Mountains start at multiples of their width.
+func mountains(size uint64) []mountain {
+	var result []mountain
+	var start uint64
+	for h := 63; h >= 0; h-- {
+		width := uint64(1) << uint(h)
+		if size&width != 0 {
+			result = append(result, mountain{start: start, height: h})
+			start += width
+		}
+	}
+	return result
+}
+
+// mountainOf fn returns position of the mountain holding the leaf
+// among mountains of size leaves, together with the mountain
+func mountainOf(size uint64, leaf uint64) (int, mountain) {
+	for i, mountain := range mountains(size) {
+		if leaf < mountain.start+uint64(1)<<uint(mountain.height) {
+			return i, mountain
+		}
+	}
+	return -1, mountain{}
+}
+
+// Verify fn checks the proof that hash is the leaf at proof.Index
+// of the accumulator with the root
+func Verify(root [sha256.Size]byte, hash [sha256.Size]byte, proof Proof) error {
+	if proof.Index >= proof.Size || len(proof.Peaks) != bits.OnesCount64(proof.Size) {
+		return MalformedProofErr
+	}
+	peak, mountain := mountainOf(proof.Size, proof.Index)
+	if len(proof.Path) != mountain.height {
+		return MalformedProofErr
+	}
+	if climb(leafHash(hash), proof.Index, proof.Path) != proof.Peaks[peak] || bag(proof.Peaks) != root {
+		return RootMismatchErr
+	}
+	return nil
+}
+
+// VerifyConsistency fn checks the proof that the accumulator with newRoot
+// extends the one with oldRoot, so leaves committed to by oldRoot were
+// neither changed nor removed
+func VerifyConsistency(oldRoot [sha256.Size]byte, newRoot [sha256.Size]byte, proof ConsistencyProof) error {
+	oldMountains := mountains(proof.OldSize)
+	if proof.OldSize == 0 || proof.OldSize > proof.NewSize || len(proof.OldPeaks) != len(oldMountains) ||
+		len(proof.Paths) != len(oldMountains) || len(proof.NewPeaks) != bits.OnesCount64(proof.NewSize) {
+		return MalformedProofErr
+	}
+	if bag(proof.OldPeaks) != oldRoot || bag(proof.NewPeaks) != newRoot {
+		return RootMismatchErr
+	}
+	for i, old := range oldMountains {
+		peak, mountain := mountainOf(proof.NewSize, old.start)
+		if len(proof.Paths[i]) != mountain.height-old.height {
+			return MalformedProofErr
+		}
+		if climb(proof.OldPeaks[i], old.start>>old.height, proof.Paths[i]) != proof.NewPeaks[peak] {
+			return RootMismatchErr
+		}
+	}
+	return nil
+}
+
+// climb fn hashes the node at the index of its level with the siblings
+// on the path and returns the top node. Mountains start at multiples of
+// their width, so the index tells the side of the sibling at every level.
+func climb(node [sha256.Size]byte, index uint64, path [][sha256.Size]byte) [sha256.Size]byte {
+	for _, sibling := range path {
+		if index%2 == 0 {
+			node = nodeHash(node, sibling)
+		} else {
+			node = nodeHash(sibling, node)
+		}
+		index /= 2
+	}
+	return node
+}
+
+// bag fn folds peaks from the lowest to the highest into the root
+func bag(peaks [][sha256.Size]byte) [sha256.Size]byte {
+	if len(peaks) == 0 {
+		return [sha256.Size]byte{}
+	}
+	root := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		root = nodeHash(peaks[i], root)
+	}
+	return root
+}
+
+func leafHash(hash [sha256.Size]byte) [sha256.Size]byte {
+	return sha256.Sum256(append([]byte{leafPrefix}, hash[:]...))
+}
+
+func nodeHash(left [sha256.Size]byte, right [sha256.Size]byte) [sha256.Size]byte {
+	preimage := make([]byte, 0, 1+2*sha256.Size)
+	preimage = append(preimage, nodePrefix)
+	preimage = append(preimage, left[:]...)
+	preimage = append(preimage, right[:]...)
+	return sha256.Sum256(preimage)
+}
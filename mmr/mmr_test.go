@@ -0,0 +1,200 @@
+package mmr
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func leaf(i int) [sha256.Size]byte {
+	return sha256.Sum256([]byte(fmt.Sprintf("Block %d", i)))
+}
+
+// naiveRoot fn computes the root from leaves, splitting them into
+// mountains by the set bits of their number
+func naiveRoot(leaves [][sha256.Size]byte) [sha256.Size]byte {
+	var peaks [][sha256.Size]byte
+	for len(leaves) > 0 {
+		width := 1
+		for width*2 <= len(leaves) {
+			width *= 2
+		}
+		level := make([][sha256.Size]byte, width)
+		for i := range level {
+			level[i] = leafHash(leaves[i])
+		}
+		for len(level) > 1 {
+			next := make([][sha256.Size]byte, len(level)/2)
+			for i := range next {
+				next[i] = nodeHash(level[2*i], level[2*i+1])
+			}
+			level = next
+		}
+		peaks = append(peaks, level[0])
+		leaves = leaves[width:]
+	}
+	return bag(peaks)
+}
+
+func TestProof(t *testing.T) {
+	t.Log("Proof")
+	{
+		t.Log("\tGiven accumulators of 1 to 40 leaves")
+		{
+			acc := New()
+			var leaves [][sha256.Size]byte
+			for n := 1; n <= 40; n++ {
+				acc.Append(leaf(n - 1))
+				leaves = append(leaves, leaf(n-1))
+				root := acc.Root()
+				if acc.Size() != uint64(n) || root != naiveRoot(leaves) {
+					t.Fatalf("\t\tShould commit to all %d leaves", n)
+				}
+				for i := 0; i < n; i++ {
+					proof, err := acc.Proof(uint64(i))
+					if err != nil {
+						t.Fatal("\t\tShould return proof, got err: ", err)
+					}
+					if err := Verify(root, leaf(i), proof); err != nil {
+						t.Fatalf("\t\tShould verify leaf %d of %d, got err: %v", i, n, err)
+					}
+					if err := Verify(root, leaf(n), proof); err != RootMismatchErr {
+						t.Fatalf("\t\tShould reject other leaf, got err: %v", err)
+					}
+				}
+			}
+			t.Log("\t\tShould commit to all leaves")
+			t.Log("\t\tShould verify every leaf")
+			t.Log("\t\tShould reject other leaf")
+			if _, err := acc.Proof(40); err != IndexErr {
+				t.Fatal("\t\tShould return IndexErr, got: ", err)
+			}
+			t.Log("\t\tShould return IndexErr")
+		}
+		t.Log("\tGiven proof of older accumulator")
+		{
+			acc := New()
+			for i := 0; i < 11; i++ {
+				acc.Append(leaf(i))
+			}
+			root := acc.Root()
+			proof, _ := acc.Proof(9)
+			acc.Append(leaf(11))
+			if err := Verify(root, leaf(9), proof); err != nil {
+				t.Fatal("\t\tShould verify against the older root, got err: ", err)
+			}
+			t.Log("\t\tShould verify against the older root")
+			if err := Verify(acc.Root(), leaf(9), proof); err != RootMismatchErr {
+				t.Fatal("\t\tShould not verify against the new root, got err: ", err)
+			}
+			t.Log("\t\tShould not verify against the new root")
+		}
+		t.Log("\tGiven proof at an older size")
+		{
+			acc := New()
+			for i := 0; i < 20; i++ {
+				acc.Append(leaf(i))
+			}
+			for size := uint64(1); size <= 20; size++ {
+				root, _ := acc.RootAt(size)
+				proof, err := acc.ProofAt(size-1, size)
+				if err != nil || Verify(root, leaf(int(size-1)), proof) != nil {
+					t.Fatalf("\t\tShould verify the last leaf against the root of size %d, got err: %v", size, err)
+				}
+			}
+			t.Log("\t\tShould verify the last leaf against the root of every size")
+			if _, err := acc.ProofAt(5, 5); err != IndexErr {
+				t.Fatal("\t\tShould return IndexErr for index out of size, got: ", err)
+			}
+			if _, err := acc.ProofAt(5, 21); err != IndexErr {
+				t.Fatal("\t\tShould return IndexErr for size above the accumulator, got: ", err)
+			}
+			t.Log("\t\tShould return IndexErr")
+		}
+		t.Log("\tGiven tampered proofs")
+		{
+			acc := New()
+			for i := 0; i < 13; i++ {
+				acc.Append(leaf(i))
+			}
+			root := acc.Root()
+			proof, _ := acc.Proof(5)
+			cases := map[string]struct {
+				tamper func(p *Proof)
+				err    error
+			}{
+				"index":       {func(p *Proof) { p.Index = 4 }, RootMismatchErr},
+				"size":        {func(p *Proof) { p.Size = 12 }, MalformedProofErr},
+				"short path":  {func(p *Proof) { p.Path = p.Path[1:] }, MalformedProofErr},
+				"sibling":     {func(p *Proof) { p.Path[0] = leaf(0) }, RootMismatchErr},
+				"peak":        {func(p *Proof) { p.Peaks[2] = leaf(0) }, RootMismatchErr},
+				"index range": {func(p *Proof) { p.Index = 13 }, MalformedProofErr},
+			}
+			for name, c := range cases {
+				tampered := proof
+				tampered.Path = append([][sha256.Size]byte{}, proof.Path...)
+				tampered.Peaks = append([][sha256.Size]byte{}, proof.Peaks...)
+				c.tamper(&tampered)
+				if err := Verify(root, leaf(5), tampered); err != c.err {
+					t.Fatalf("\t\tShould reject proof with tampered %s, got err: %v", name, err)
+				}
+			}
+			t.Log("\t\tShould reject tampered proofs")
+		}
+	}
+}
+
+func TestConsistencyProof(t *testing.T) {
+	t.Log("ConsistencyProof")
+	{
+		acc := New()
+		for i := 0; i < 40; i++ {
+			acc.Append(leaf(i))
+		}
+		t.Log("\tGiven every pair of sizes up to 40 leaves")
+		{
+			for oldSize := uint64(1); oldSize <= 40; oldSize++ {
+				oldRoot, _ := acc.RootAt(oldSize)
+				for newSize := oldSize; newSize <= 40; newSize++ {
+					newRoot, _ := acc.RootAt(newSize)
+					proof, err := acc.ConsistencyProof(oldSize, newSize)
+					if err != nil {
+						t.Fatal("\t\tShould return proof, got err: ", err)
+					}
+					if err := VerifyConsistency(oldRoot, newRoot, proof); err != nil {
+						t.Fatalf("\t\tShould verify %d extended to %d, got err: %v", oldSize, newSize, err)
+					}
+				}
+			}
+			t.Log("\t\tShould verify every extension")
+			if _, err := acc.ConsistencyProof(5, 41); err != IndexErr {
+				t.Fatal("\t\tShould return IndexErr, got: ", err)
+			}
+			if _, err := acc.ConsistencyProof(6, 5); err != IndexErr {
+				t.Fatal("\t\tShould return IndexErr, got: ", err)
+			}
+			t.Log("\t\tShould return IndexErr")
+		}
+		t.Log("\tGiven history rewritten after the old root")
+		{
+			oldRoot, _ := acc.RootAt(11)
+			rewritten := New()
+			for i := 0; i < 40; i++ {
+				if i == 9 {
+					rewritten.Append(leaf(100))
+					continue
+				}
+				rewritten.Append(leaf(i))
+			}
+			proof, _ := rewritten.ConsistencyProof(11, 40)
+			if err := VerifyConsistency(oldRoot, rewritten.Root(), proof); err != RootMismatchErr {
+				t.Fatal("\t\tShould reject the rewritten accumulator, got err: ", err)
+			}
+			proof.OldPeaks = nil
+			if err := VerifyConsistency(oldRoot, rewritten.Root(), proof); err != MalformedProofErr {
+				t.Fatal("\t\tShould reject malformed proof, got err: ", err)
+			}
+			t.Log("\t\tShould reject the rewritten accumulator")
+		}
+	}
+}
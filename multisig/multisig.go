@@ -0,0 +1,228 @@
+// multisig package provides m-of-n ownership policies for stars owned by
+// several addresses. The policy is represented as an address of its own,
+// e.g. multisig:2-of-3:0xAb..,1Fzp..,bc1q.., so it can be used everywhere
+// a single owner address is expected: in challenges, blocks and queries.
+package multisig
+
+import (
+	"errors"
+	"fmt"
+	"github.com/starchain/contracts"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Scheme is the name of multisig address scheme
+	Scheme = "multisig"
+	// Prefix of every policy address
+	Prefix = "multisig:"
+	// MaxOwners is the maximum number of co-owners of a star
+	MaxOwners = 15
+	// separator of owners and of signatures
+	separator = ","
+)
+
+var (
+	InvalidPolicyErr    = errors.New("Policy must be multisig:<m>-of-<n>:<addr1>,...,<addrN>")
+	ThresholdErr        = errors.New("Policy threshold must be between 1 and the number of owners")
+	OwnersCountErr      = errors.New(fmt.Sprintf("Policy must have between 2 and %d owners", MaxOwners))
+	DuplicateOwnerErr   = errors.New("Policy owners must be unique")
+	OwnerOrderErr       = errors.New("Policy owners must be sorted")
+	InvalidOwnerErr     = errors.New("Policy owner must be a single address without whitespace")
+	MalformedSigsErr    = errors.New("Signatures must be comma separated, one entry per owner")
+	NotEnoughSigsErr    = errors.New("Not enough valid signatures of the owners")
+	UnknownSignerErr    = errors.New("Signature of an address which is not an owner")
+	UnsupportedOwnerErr = errors.New("Owner address does not belong to any registered scheme")
+)
+
+// Policy struct describes star owned by Owners, M of which have to sign
+type Policy struct {
+	M      int
+	Owners []string
+}
+
+// New fn returns valid policy with owners sorted, so the same group
+// of owners has always the same policy address
+func New(m int, owners []string) (Policy, error) {
+	sorted := append([]string{}, owners...)
+	sort.Strings(sorted)
+	p := Policy{M: m, Owners: sorted}
+	return p, p.Validate()
+}
+
+// IsPolicy fn reports whether the address is a policy address
+func IsPolicy(addr string) bool {
+	return strings.HasPrefix(addr, Prefix)
+}
+
+// Parse fn strictly parses policy address, it is the inverse of String
+func Parse(addr string) (Policy, error) {
+	if !IsPolicy(addr) {
+		return Policy{}, InvalidPolicyErr
+	}
+	parts := strings.SplitN(addr[len(Prefix):], ":", 2)
+	if len(parts) != 2 {
+		return Policy{}, InvalidPolicyErr
+	}
+	threshold := strings.Split(parts[0], "-of-")
+	if len(threshold) != 2 {
+		return Policy{}, InvalidPolicyErr
+	}
+	m, err := parseCount(threshold[0])
+	if err != nil {
+		return Policy{}, err
+	}
+	n, err := parseCount(threshold[1])
+	if err != nil {
+		return Policy{}, err
+	}
+	p := Policy{M: m, Owners: strings.Split(parts[1], separator)}
+	if len(p.Owners) != n {
+		return Policy{}, InvalidPolicyErr
+	}
+	if err := p.Validate(); err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+// Validate method checks threshold and owners of the policy
+func (p Policy) Validate() error {
+	n := len(p.Owners)
+	if n < 2 || n > MaxOwners {
+		return OwnersCountErr
+	}
+	if p.M < 1 || p.M > n {
+		return ThresholdErr
+	}
+	for i, owner := range p.Owners {
+		if owner == "" || IsPolicy(owner) || strings.ContainsAny(owner, separator+" \t\r\n") {
+			return InvalidOwnerErr
+		}
+		if i > 0 && owner == p.Owners[i-1] {
+			return DuplicateOwnerErr
+		}
+		if i > 0 && owner < p.Owners[i-1] {
+			return OwnerOrderErr
+		}
+	}
+	return nil
+}
+
+// String method returns the policy address
+func (p Policy) String() string {
+	return fmt.Sprintf("%s%d-of-%d:%s", Prefix, p.M, len(p.Owners), strings.Join(p.Owners, separator))
+}
+
+// HasOwner method reports whether the address is one of the owners
+func (p Policy) HasOwner(addr string) bool {
+	i := sort.SearchStrings(p.Owners, addr)
+	return i < len(p.Owners) && p.Owners[i] == addr
+}
+
+// EncodeSignatures method returns signatures of the owners in policy order,
+// empty entries stand for owners which did not sign
+func (p Policy) EncodeSignatures(sigs map[string]string) (string, error) {
+	encoded := make([]string, len(p.Owners))
+	for addr, sig := range sigs {
+		if !p.HasOwner(addr) {
+			return "", UnknownSignerErr
+		}
+		if strings.Contains(sig, separator) {
+			return "", MalformedSigsErr
+		}
+		encoded[sort.SearchStrings(p.Owners, addr)] = sig
+	}
+	return strings.Join(encoded, separator), nil
+}
+
+// DecodeSignatures method returns non-empty signatures by owner address
+func (p Policy) DecodeSignatures(sig string) (map[string]string, error) {
+	encoded := strings.Split(sig, separator)
+	if len(encoded) != len(p.Owners) {
+		return nil, MalformedSigsErr
+	}
+	sigs := make(map[string]string)
+	for i, s := range encoded {
+		if s != "" {
+			sigs[p.Owners[i]] = s
+		}
+	}
+	return sigs, nil
+}
+
+// Verifier struct implements contracts.Verifier for policy addresses.
+// Signatures of the owners are checked by verifiers of their schemes.
+type Verifier struct {
+	verifierFor func(addr string) (contracts.Verifier, error)
+}
+
+// NewVerifier fn returns multisig verifier which looks up
+// verifiers of owner addresses with verifierFor
+func NewVerifier(verifierFor func(addr string) (contracts.Verifier, error)) Verifier {
+	return Verifier{verifierFor}
+}
+
+func (v Verifier) Scheme() string {
+	return Scheme
+}
+
+// Supports method accepts every policy address
+func (v Verifier) Supports(addr string) bool {
+	return IsPolicy(addr)
+}
+
+// ValidateAddress method checks the policy and every owner address
+func (v Verifier) ValidateAddress(addr string) error {
+	p, err := Parse(addr)
+	if err != nil {
+		return err
+	}
+	for _, owner := range p.Owners {
+		verifier, err := v.verifierFor(owner)
+		if err != nil || verifier.Scheme() == Scheme {
+			return UnsupportedOwnerErr
+		}
+		if err := verifier.ValidateAddress(owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify method checks that at least M owners signed the message.
+// sig holds the signatures encoded by Policy.EncodeSignatures,
+// any invalid signature fails the verification.
+func (v Verifier) Verify(addr string, msg string, sig string) error {
+	p, err := Parse(addr)
+	if err != nil {
+		return err
+	}
+	sigs, err := p.DecodeSignatures(sig)
+	if err != nil {
+		return err
+	}
+	if len(sigs) < p.M {
+		return NotEnoughSigsErr
+	}
+	for owner, ownerSig := range sigs {
+		verifier, err := v.verifierFor(owner)
+		if err != nil || verifier.Scheme() == Scheme {
+			return UnsupportedOwnerErr
+		}
+		if err := verifier.Verify(owner, msg, ownerSig); err != nil {
+			return errors.New(fmt.Sprintf("Signature of %s is invalid: %s", owner, err))
+		}
+	}
+	return nil
+}
+
+func parseCount(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || strconv.Itoa(n) != s {
+		return 0, InvalidPolicyErr
+	}
+	return n, nil
+}
@@ -0,0 +1,147 @@
+package multisig
+
+import (
+	"crypto/ed25519"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/starchain/bitcoin"
+	"github.com/starchain/contracts"
+	"github.com/starchain/didkey"
+	"github.com/starchain/ethereum"
+	"testing"
+)
+
+var (
+	btcKey, _ = btcec.PrivKeyFromBytes([]byte("starchain test key #1 - 32 bytes"))
+	ethKey, _ = btcec.PrivKeyFromBytes([]byte("starchain test key #2 - 32 bytes"))
+	didKey    = ed25519.NewKeyFromSeed([]byte("starchain test key #3 - 32 bytes"))
+	btcAddr   = bitcoin.P2PKHAddress(btcKey.PubKey(), true)
+	ethAddr   = ethereum.PubKeyToAddress(ethKey.PubKey())
+	didAddr   = didkey.EncodeDID(didKey.Public().(ed25519.PublicKey))
+)
+
+func verifierFor(addr string) (contracts.Verifier, error) {
+	for _, v := range []contracts.Verifier{bitcoin.Verifier{}, ethereum.Verifier{}, didkey.Verifier{}} {
+		if v.Supports(addr) {
+			return v, nil
+		}
+	}
+	return nil, UnsupportedOwnerErr
+}
+
+func TestParse(t *testing.T) {
+	t.Log("Parse")
+	{
+		t.Log("\tGiven policy created by New")
+		{
+			policy, err := New(2, []string{didAddr, btcAddr, ethAddr})
+			if err != nil {
+				t.Fatal("\t\tShould create the policy, got err: ", err)
+			}
+			expected := "multisig:2-of-3:" + ethAddr + "," + btcAddr + "," + didAddr
+			if policy.String() != expected {
+				t.Fatal("\t\tShould sort the owners, got: ", policy.String())
+			}
+			t.Log("\t\tShould sort the owners")
+			parsed, err := Parse(policy.String())
+			if err != nil || parsed.String() != policy.String() || parsed.M != 2 {
+				t.Fatal("\t\tShould parse the same policy, got: ", parsed, err)
+			}
+			t.Log("\t\tShould parse the same policy")
+			if !parsed.HasOwner(btcAddr) || parsed.HasOwner("1BoatSLRHtKNngkdXEeobR76b53LETtpyT") {
+				t.Fatal("\t\tShould report owners")
+			}
+			t.Log("\t\tShould report owners")
+		}
+		t.Log("\tGiven invalid policies")
+		{
+			cases := map[string]error{
+				btcAddr:                               InvalidPolicyErr,
+				"multisig:2-of-3:a,b":                 InvalidPolicyErr,
+				"multisig:02-of-2:a,b":                InvalidPolicyErr,
+				"multisig:2of2:a,b":                   InvalidPolicyErr,
+				"multisig:0-of-2:a,b":                 ThresholdErr,
+				"multisig:3-of-2:a,b":                 ThresholdErr,
+				"multisig:1-of-1:a":                   OwnersCountErr,
+				"multisig:1-of-2:a,a":                 DuplicateOwnerErr,
+				"multisig:1-of-2:b,a":                 OwnerOrderErr,
+				"multisig:1-of-2:a,b c":               InvalidOwnerErr,
+				"multisig:1-of-2:,a":                  InvalidOwnerErr,
+				"multisig:1-of-2:a,multisig:1-of-2:b": InvalidOwnerErr,
+				"multisig:1-of-16:a,b,c,d,e,f,g,h,i,j,k,l,m,n,o,p": OwnersCountErr,
+			}
+			for addr, expected := range cases {
+				if _, err := Parse(addr); err != expected {
+					t.Fatalf("\t\tShould return %v for %s, got: %v", expected, addr, err)
+				}
+			}
+			t.Log("\t\tShould return errors")
+		}
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	t.Log("Verifier")
+	{
+		policy, _ := New(2, []string{btcAddr, ethAddr, didAddr})
+		addr := policy.String()
+		msg := "Register a star in the Starchain registry."
+		v := NewVerifier(verifierFor)
+		t.Log("\tGiven policy address")
+		{
+			if !v.Supports(addr) || v.Supports(btcAddr) {
+				t.Fatal("\t\tShould support only policy addresses")
+			}
+			if err := v.ValidateAddress(addr); err != nil {
+				t.Fatal("\t\tShould accept the policy, got err: ", err)
+			}
+			unsupported, _ := New(1, []string{btcAddr, "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb"})
+			if err := v.ValidateAddress(unsupported.String()); err != UnsupportedOwnerErr {
+				t.Fatal("\t\tShould return UnsupportedOwnerErr, got: ", err)
+			}
+			t.Log("\t\tShould validate owner addresses")
+		}
+		t.Log("\tGiven m signatures")
+		{
+			sig, err := policy.EncodeSignatures(map[string]string{
+				btcAddr: bitcoin.SignMessage(btcKey, msg, true),
+				didAddr: didkey.SignMessage(didKey, msg),
+			})
+			if err != nil {
+				t.Fatal("\t\tShould encode signatures, got err: ", err)
+			}
+			if err := v.Verify(addr, msg, sig); err != nil {
+				t.Fatal("\t\tShould verify, got err: ", err)
+			}
+			t.Log("\t\tShould verify")
+		}
+		t.Log("\tGiven less than m signatures")
+		{
+			sig, _ := policy.EncodeSignatures(map[string]string{
+				ethAddr: ethereum.SignMessage(ethKey, msg),
+			})
+			if err := v.Verify(addr, msg, sig); err != NotEnoughSigsErr {
+				t.Fatal("\t\tShould return NotEnoughSigsErr, got: ", err)
+			}
+			t.Log("\t\tShould return NotEnoughSigsErr")
+		}
+		t.Log("\tGiven signature of one owner used for another")
+		{
+			btcSig := bitcoin.SignMessage(btcKey, msg, true)
+			sig := btcSig + "," + btcSig + ","
+			if err := v.Verify(addr, msg, sig); err == nil {
+				t.Fatal("\t\tShould reject the signatures, got nil")
+			}
+			t.Log("\t\tShould reject the signatures")
+		}
+		t.Log("\tGiven signature of other address")
+		{
+			if _, err := policy.EncodeSignatures(map[string]string{"1BoatSLRHtKNngkdXEeobR76b53LETtpyT": "sig"}); err != UnknownSignerErr {
+				t.Fatal("\t\tShould return UnknownSignerErr, got: ", err)
+			}
+			if err := v.Verify(addr, msg, "a,b"); err != MalformedSigsErr {
+				t.Fatal("\t\tShould return MalformedSigsErr, got: ", err)
+			}
+			t.Log("\t\tShould return errors")
+		}
+	}
+}
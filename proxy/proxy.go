@@ -5,11 +5,13 @@ package proxy
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/starchain/block"
 	"github.com/starchain/blockchain"
 	"github.com/starchain/contracts"
+	"github.com/starchain/multisig"
 	"github.com/starchain/utils"
 )
 
@@ -21,8 +23,37 @@ func New(blockchain *blockchain.Blockchain) BlockchainProxy {
 	return BlockchainProxy{blockchain}
 }
 
-func (bp BlockchainProxy) RequestMessageOwnershipVerification(addr string) (string, error) {
-	return bp.blockchain.RequestMessageOwnershipVerification(addr)
+// RequestMessageOwnershipVerification method issues the challenge to the
+// address, or to the multisig policy address built of the owners
+func (bp BlockchainProxy) RequestMessageOwnershipVerification(owner contracts.ChallengeOwner, star *contracts.StarCoordinates) (contracts.Challenge, error) {
+	var (
+		result contracts.Challenge
+		coords *blockchain.StarCoordinates
+	)
+	addr := owner.Address
+	if len(owner.Owners) > 0 {
+		if addr != "" {
+			return result, contracts.OwnersWithAddressErr
+		}
+		policy, err := multisig.New(owner.Threshold, owner.Owners)
+		if err != nil {
+			return result, errors.New(fmt.Sprintf("%s: %s", contracts.InvalidOwnersErr, err))
+		}
+		addr = policy.String()
+	}
+	if star != nil {
+		coords = &blockchain.StarCoordinates{RA: star.RA, Dec: star.Dec}
+	}
+	challenge, err := bp.blockchain.RequestMessageOwnershipVerification(addr, coords)
+	if err == nil {
+		result.Address = challenge.Address
+		result.Message = challenge.Message
+		result.IssuedAt = challenge.IssuedAt
+		result.ExpiresAt = challenge.ExpiresAt
+		result.ValidFor = challenge.ValidFor
+		result.MaxClockSkew = challenge.MaxClockSkew
+	}
+	return result, err
 }
 
 func (bp BlockchainProxy) GetBlockByHeight(h int) (contracts.Block, error) {
@@ -60,12 +91,136 @@ func (bp BlockchainProxy) GetStarsByWalletAddress(addr string) []string {
 	return bp.blockchain.GetStarsByWalletAddress(addr)
 }
 
+func (bp BlockchainProxy) GetBlocksSince(height int) (contracts.BlockRange, error) {
+	blocks, err := bp.blockchain.GetBlocksSince(height, blockchain.MaxBlocksPerRange)
+	if err != nil {
+		return contracts.BlockRange{}, err
+	}
+	result := contracts.BlockRange{
+		ChainHeight: bp.blockchain.GetChainHeight(),
+		Blocks:      make([][]byte, len(blocks)),
+	}
+	for i, block := range blocks {
+		if result.Blocks[i], err = json.Marshal(block); err != nil {
+			return contracts.BlockRange{}, err
+		}
+	}
+	return result, nil
+}
+
+func (bp BlockchainProxy) GetInclusionProof(height int) (contracts.InclusionProof, error) {
+	proof, err := bp.blockchain.GetInclusionProof(height)
+	if err != nil {
+		return contracts.InclusionProof{}, err
+	}
+	result := contracts.InclusionProof{
+		Height:    proof.Height,
+		BlockHash: utils.HashToStr(proof.BlockHash),
+		Root:      utils.HashToStr(proof.Root),
+		Size:      proof.Proof.Size,
+		Path:      make([]string, len(proof.Proof.Path)),
+		Peaks:     make([]string, len(proof.Proof.Peaks)),
+	}
+	for i, hash := range proof.Proof.Path {
+		result.Path[i] = utils.HashToStr(hash)
+	}
+	for i, hash := range proof.Proof.Peaks {
+		result.Peaks[i] = utils.HashToStr(hash)
+	}
+	return result, nil
+}
+
+func (bp BlockchainProxy) GetLatestTreeHead() (contracts.TreeHead, error) {
+	head, err := bp.blockchain.GetLatestTreeHead()
+	if err != nil {
+		return contracts.TreeHead{}, err
+	}
+	return mapTreeHeadToContract(head), nil
+}
+
+func (bp BlockchainProxy) GetTreeHeads() []contracts.TreeHead {
+	heads := bp.blockchain.GetTreeHeads()
+	result := make([]contracts.TreeHead, len(heads))
+	for i, head := range heads {
+		result[i] = mapTreeHeadToContract(head)
+	}
+	return result
+}
+
+func (bp BlockchainProxy) GetTreeHeadConsistency(oldHeight int, newHeight int) (contracts.TreeHeadConsistency, error) {
+	consistency, err := bp.blockchain.GetTreeHeadConsistency(oldHeight, newHeight)
+	if err != nil {
+		return contracts.TreeHeadConsistency{}, err
+	}
+	result := contracts.TreeHeadConsistency{
+		OldHeight:   consistency.OldHeight,
+		NewHeight:   consistency.NewHeight,
+		OldRoot:     utils.HashToStr(consistency.OldRoot),
+		NewRoot:     utils.HashToStr(consistency.NewRoot),
+		OldPeaks:    make([]string, len(consistency.Proof.OldPeaks)),
+		Paths:       make([][]string, len(consistency.Proof.Paths)),
+		NewPeaks:    make([]string, len(consistency.Proof.NewPeaks)),
+		OldHeadPath: []string{},
+		NewHeadPath: []string{},
+	}
+	for i, hash := range consistency.Proof.OldPeaks {
+		result.OldPeaks[i] = utils.HashToStr(hash)
+	}
+	for i, path := range consistency.Proof.Paths {
+		result.Paths[i] = make([]string, len(path))
+		for j, hash := range path {
+			result.Paths[i][j] = utils.HashToStr(hash)
+		}
+	}
+	for i, hash := range consistency.Proof.NewPeaks {
+		result.NewPeaks[i] = utils.HashToStr(hash)
+	}
+	for _, hash := range consistency.OldHeadProof.Path {
+		result.OldHeadPath = append(result.OldHeadPath, utils.HashToStr(hash))
+	}
+	for _, hash := range consistency.NewHeadProof.Path {
+		result.NewHeadPath = append(result.NewHeadPath, utils.HashToStr(hash))
+	}
+	return result, nil
+}
+
+func mapTreeHeadToContract(head blockchain.TreeHead) contracts.TreeHead {
+	return contracts.TreeHead{
+		Height:    head.Height,
+		HeadHash:  utils.HashToStr(head.HeadHash),
+		Root:      utils.HashToStr(head.Root),
+		Timestamp: head.Timestamp,
+		KeyID:     head.KeyID,
+		Signature: hex.EncodeToString(head.Signature),
+	}
+}
+
 func (bp BlockchainProxy) SubmitStar(star contracts.StarData) (contracts.Block, error) {
 	var req blockchain.StarRequest
 	req.Addr = star.Address
 	req.Msg = star.Message
 	req.StarData = star.Data
 	req.Sig = star.Signature
+	if len(star.Signatures) > 0 {
+		// signatures are encoded in the order of canonical owners,
+		// the order the blockchain verifies them in
+		req.Addr = bp.blockchain.CanonicalAddress(star.Address)
+		policy, err := multisig.Parse(req.Addr)
+		if err != nil {
+			return contracts.Block{}, err
+		}
+		sigs := make(map[string]string, len(star.Signatures))
+		for owner, sig := range star.Signatures {
+			owner = bp.blockchain.CanonicalAddress(owner)
+			if _, ok := sigs[owner]; ok {
+				return contracts.Block{}, multisig.DuplicateOwnerErr
+			}
+			sigs[owner] = sig
+		}
+		if req.Sig, err = policy.EncodeSignatures(sigs); err != nil {
+			return contracts.Block{}, err
+		}
+	}
 	b, err := bp.blockchain.SubmitStar(req)
 	if err != nil {
 		return contracts.Block{}, err
@@ -81,18 +236,73 @@ func MapBlockToContract(block *block.Block) contracts.Block {
 	result.Owner = block.GetOwner()
 	result.PreviousBlockHash = utils.HashToStr(block.GetPrevHash())
 	result.Time = block.GetTimestamp()
+	result.Signature = hex.EncodeToString(block.GetSignature())
+	result.KeyID = block.GetKeyID()
+	result.Message = block.GetMessage()
+	result.MessageSignature = block.GetMessageSignature()
+	result.HashVersion = int(block.GetHashVersion())
+	result.Difficulty = block.GetDifficulty()
+	result.Nonce = block.GetNonce()
+	return result
+}
+
+func (bp BlockchainProxy) Validate(opts contracts.ValidationOptions) (bool, []contracts.Finding) {
+	errs := bp.blockchain.ValidateChainWith(blockchain.ValidationOptions{
+		VerifyProofs: opts.VerifyProofs,
+		Full:         opts.Full,
+	})
+	return len(errs) == 0, mapFindingsToContract(errs)
+}
+
+func (bp BlockchainProxy) StartValidation(opts contracts.ValidationOptions) (contracts.ValidationJob, error) {
+	job, err := bp.blockchain.StartValidation(blockchain.ValidationOptions{
+		VerifyProofs: opts.VerifyProofs,
+		Full:         opts.Full,
+	})
+	return mapJobToContract(job), err
+}
+
+func (bp BlockchainProxy) GetValidation(id string) (contracts.ValidationJob, error) {
+	job, err := bp.blockchain.GetValidation(id)
+	return mapJobToContract(job), err
+}
+
+func (bp BlockchainProxy) CancelValidation(id string) (contracts.ValidationJob, error) {
+	job, err := bp.blockchain.CancelValidation(id)
+	return mapJobToContract(job), err
+}
+
+func mapJobToContract(job blockchain.ValidationJob) contracts.ValidationJob {
+	result := contracts.ValidationJob{
+		ID:         job.ID,
+		State:      job.State,
+		Checked:    job.Checked,
+		Total:      job.Total,
+		Valid:      job.State == blockchain.JobDone && len(job.Errors) == 0,
+		ErrorLog:   make([]string, len(job.Errors)),
+		Findings:   mapFindingsToContract(job.Errors),
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+	for i, e := range job.Errors {
+		result.ErrorLog[i] = e.Error()
+	}
 	return result
 }
 
-func (bp BlockchainProxy) Validate() (bool, []string) {
-	var msgs []string
-	errs := bp.blockchain.ValidateChain()
-	isValid := len(errs) == 0
-	if !isValid {
-		msgs := make([]string, len(errs))
-		for i, e := range errs {
-			msgs[i] = e.Error()
+// mapFindingsToContract fn maps validation errors to findings,
+// errors which are not *blockchain.Finding have only the message
+func mapFindingsToContract(errs []error) []contracts.Finding {
+	findings := make([]contracts.Finding, len(errs))
+	for i, e := range errs {
+		findings[i].Message = e.Error()
+		var finding *blockchain.Finding
+		if errors.As(e, &finding) {
+			findings[i].Kind = finding.Kind
+			findings[i].Height = finding.Height
+			findings[i].Expected = finding.Expected
+			findings[i].Actual = finding.Actual
 		}
 	}
-	return isValid, msgs
+	return findings
 }
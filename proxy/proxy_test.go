@@ -1,8 +1,20 @@
 package proxy
 
 import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/starchain/bitcoin"
+	blockpkg "github.com/starchain/block"
 	"github.com/starchain/blockchain"
 	"github.com/starchain/contracts"
+	"github.com/starchain/ethereum"
+	"github.com/starchain/mmr"
+	"github.com/starchain/multisig"
+	"github.com/starchain/utils"
+	"strings"
 	"testing"
 	"time"
 )
@@ -14,27 +26,55 @@ func (b BlockchainClockMock) GetTime() int64 {
 }
 
 var (
-	addr  string          = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
-	clock contracts.Clock = BlockchainClockMock{}
+	addr       string          = "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe"
+	clock      contracts.Clock = BlockchainClockMock{}
+	starKey, _                 = btcec.PrivKeyFromBytes([]byte("starchain test key #1 - 32 bytes"))
+	starAddr   string          = bitcoin.P2PKHAddress(starKey.PubKey(), true)
 )
 
 func TestRequestMessageOwnershipVerification(t *testing.T) {
 	t.Log("TestRequestMessageOwnershipVerification")
 	{
-		bchain := blockchain.New(clock)
+		bchain := blockchain.New(clock, blockchain.DefaultConfig())
 		proxy := New(bchain)
 		t.Log("\tGiven an address: ", addr)
 		{
-			msg, err := proxy.RequestMessageOwnershipVerification(addr)
+			challenge, err := proxy.RequestMessageOwnershipVerification(contracts.ChallengeOwner{Address: addr}, nil)
 			if err != nil {
 				t.Fatal("\t\tShould return message without err, got err: ", err)
 			}
 			t.Log("\t\tShould return message without error")
-			expectedMsg := "1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe:1592156792:starRegistry"
-			if msg != expectedMsg {
-				t.Fatal("\t\tShould return correct message, got: ", msg)
+			expectedPrefix := "starchain.local wants you to prove ownership of the address:\n1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe\n"
+			if !strings.HasPrefix(challenge.Message, expectedPrefix) {
+				t.Fatal("\t\tShould return correct message, got: ", challenge.Message)
 			}
 			t.Log("\t\tShould return correct message")
+			if challenge.IssuedAt != 1592156792 || challenge.ExpiresAt != 1592156792+blockchain.FIVE_MIN {
+				t.Fatal("\t\tShould return challenge time range, got: ", challenge)
+			}
+			t.Log("\t\tShould return challenge time range")
+			if challenge.Address != addr {
+				t.Fatal("\t\tShould issue challenge to the address, got: ", challenge.Address)
+			}
+			t.Log("\t\tShould issue challenge to the address")
+		}
+		t.Log("\tGiven owners of co-owned star")
+		{
+			owners := []string{addr, "0x14791697260E4c9A71f18484C9f997B308e59325"}
+			challenge, err := proxy.RequestMessageOwnershipVerification(contracts.ChallengeOwner{Owners: owners, Threshold: 2}, nil)
+			policy, _ := multisig.New(2, owners)
+			if err != nil || challenge.Address != policy.String() || !strings.Contains(challenge.Message, policy.String()) {
+				t.Fatal("\t\tShould issue challenge to the policy address, got: ", challenge, err)
+			}
+			t.Log("\t\tShould issue challenge to the policy address")
+			if _, err := proxy.RequestMessageOwnershipVerification(contracts.ChallengeOwner{Owners: owners, Threshold: 3}, nil); err == nil || !strings.HasPrefix(err.Error(), contracts.InvalidOwnersErr.Error()) {
+				t.Fatal("\t\tShould return InvalidOwnersErr, got: ", err)
+			}
+			t.Log("\t\tShould return InvalidOwnersErr")
+			if _, err := proxy.RequestMessageOwnershipVerification(contracts.ChallengeOwner{Address: addr, Owners: owners, Threshold: 1}, nil); err != contracts.OwnersWithAddressErr {
+				t.Fatal("\t\tShould return OwnersWithAddressErr, got: ", err)
+			}
+			t.Log("\t\tShould return OwnersWithAddressErr")
 		}
 	}
 }
@@ -42,7 +82,7 @@ func TestRequestMessageOwnershipVerification(t *testing.T) {
 func TestGetBlockByHeight(t *testing.T) {
 	t.Log("TestGetBlockByHeight")
 	{
-		bchain := blockchain.New(clock)
+		bchain := blockchain.New(clock, blockchain.DefaultConfig())
 		proxy := New(bchain)
 		h := 0
 		t.Log("\tGiven a proper block height argument", h)
@@ -61,14 +101,22 @@ func TestGetBlockByHeight(t *testing.T) {
 				t.Fatal("\t\tShould return block with correct data, got:", string(block.Body))
 			}
 			t.Log("\t\tShould return block with correct data")
-			if block.Hash != "8a9a61241b4825dfa8884c04678899974ddfde55532a2fbadc07fc78472c8731" {
+			if block.Hash != "e37975bf29f4e3a1ecddd20ceeba63f67738ee35ae2e931ca6004f973233e81d" {
 				t.Fatal("\t\tShould return block with correct hash, got:", block.Hash)
 			}
 			t.Log("\t\tShould return block with correct hash")
+			if block.HashVersion != int(blockpkg.HashV2) {
+				t.Fatal("\t\tShould return block with hash version, got:", block.HashVersion)
+			}
+			t.Log("\t\tShould return block with hash version")
 			if block.Owner != "" {
 				t.Fatal("\t\tShould return block with correct owner, got:", block.Owner)
 			}
 			t.Log("\t\tShould return block with correct owner")
+			if block.KeyID != blockpkg.KeyID(bchain.GetProducerKey()) || len(block.Signature) != 2*ed25519.SignatureSize {
+				t.Fatal("\t\tShould return block signed by the node, got:", block.KeyID, block.Signature)
+			}
+			t.Log("\t\tShould return block signed by the node")
 			if block.PreviousBlockHash != "0000000000000000000000000000000000000000000000000000000000000000" {
 				t.Fatal("\t\tShould return block with correct PreviousBlockHash, got:", block.PreviousBlockHash)
 			}
@@ -89,9 +137,9 @@ func TestGetBlockByHeight(t *testing.T) {
 func TestGetBlockByHash(t *testing.T) {
 	t.Log("TestGetBlockByHash")
 	{
-		bchain := blockchain.New(clock)
+		bchain := blockchain.New(clock, blockchain.DefaultConfig())
 		proxy := New(bchain)
-		hash := "8a9a61241b4825dfa8884c04678899974ddfde55532a2fbadc07fc78472c8731"
+		hash := "e37975bf29f4e3a1ecddd20ceeba63f67738ee35ae2e931ca6004f973233e81d"
 		t.Log("\tGiven a proper block hash argument", hash)
 		{
 			block, err := proxy.GetBlockByHash(hash)
@@ -136,7 +184,7 @@ func TestGetBlockByHash(t *testing.T) {
 func TestGetStarsByWalletAddress(t *testing.T) {
 	t.Log("TestGetStarsByWalletAddress")
 	{
-		bchain := blockchain.New(clock)
+		bchain := blockchain.New(clock, blockchain.DefaultConfig())
 		proxy := New(bchain)
 		starsData := [][]byte{
 			[]byte("Data 1"),
@@ -171,15 +219,16 @@ func TestGetStarsByWalletAddress(t *testing.T) {
 func TestSubmitStar(t *testing.T) {
 	t.Log("TestSubmitStar")
 	{
-		bchain := blockchain.New(clock)
+		bchain := blockchain.New(clock, blockchain.DefaultConfig())
 		proxy := New(bchain)
 		t.Log("\tGiven proper star data")
 		{
 			var star contracts.StarData
-			star.Address = addr
-			star.Message = addr + ":1592156792:starRegistry"
+			star.Address = starAddr
+			challenge, _ := proxy.RequestMessageOwnershipVerification(contracts.ChallengeOwner{Address: starAddr}, nil)
+			star.Message = challenge.Message
 			star.Data = []byte("New Star")
-			star.Signature = "Sig"
+			star.Signature = bitcoin.SignMessage(starKey, star.Message, true)
 			block, err := proxy.SubmitStar(star)
 			if err != nil {
 				t.Fatal("\t\tShould return block without err, got err: ", err)
@@ -193,18 +242,76 @@ func TestSubmitStar(t *testing.T) {
 				t.Fatal("\t\tShould return block with correct data, got:", block.Body)
 			}
 			t.Log("\t\tShould return block with correct data")
-			if block.Hash != "c02b0315cdb0461a4bb4945ad132f28084ae8bb20f4038bc1ed3e181097aed2f" {
+			// Message contains random nonce, so the hash is calculated
+			genesisHash, _ := hex.DecodeString("e37975bf29f4e3a1ecddd20ceeba63f67738ee35ae2e931ca6004f973233e81d")
+			var prevHash [sha256.Size]byte
+			copy(prevHash[:], genesisHash)
+			expected := blockpkg.NewStar(clock.GetTime(), 1, starAddr, &prevHash, star.Data, star.Message, star.Signature)
+			if block.Hash != utils.HashToStr(expected.GetHash()) {
 				t.Fatal("\t\tShould return block with correct hash, got:", block.Hash)
 			}
 			t.Log("\t\tShould return block with correct hash")
-			if block.Owner != addr {
+			if block.Message != star.Message || block.MessageSignature != star.Signature {
+				t.Fatal("\t\tShould return block with ownership proof, got:", block.Message, block.MessageSignature)
+			}
+			t.Log("\t\tShould return block with ownership proof")
+			if block.Owner != starAddr {
 				t.Fatal("\t\tShould return block with correct owner, got:", block.Owner)
 			}
 			t.Log("\t\tShould return block with correct owner")
-			if block.PreviousBlockHash != "8a9a61241b4825dfa8884c04678899974ddfde55532a2fbadc07fc78472c8731" {
+			if block.PreviousBlockHash != "e37975bf29f4e3a1ecddd20ceeba63f67738ee35ae2e931ca6004f973233e81d" {
 				t.Fatal("\t\tShould return block with correct PreviousBlockHash, got:", block.PreviousBlockHash)
 			}
 		}
+		t.Log("\tGiven star co-owned by multisig policy")
+		{
+			otherKey, _ := btcec.PrivKeyFromBytes([]byte("starchain test key #2 - 32 bytes"))
+			otherAddr := bitcoin.P2PKHAddress(otherKey.PubKey(), true)
+			policy, _ := multisig.New(2, []string{starAddr, otherAddr})
+			var star contracts.StarData
+			star.Address = policy.String()
+			challenge, _ := proxy.RequestMessageOwnershipVerification(contracts.ChallengeOwner{Address: star.Address}, nil)
+			star.Message = challenge.Message
+			star.Data = []byte("Our Star")
+			star.Signatures = map[string]string{
+				starAddr:  bitcoin.SignMessage(starKey, star.Message, true),
+				otherAddr: bitcoin.SignMessage(otherKey, star.Message, true),
+			}
+			block, err := proxy.SubmitStar(star)
+			if err != nil {
+				t.Fatal("\t\tShould return block without err, got err: ", err)
+			}
+			if block.Owner != star.Address || len(proxy.GetStarsByWalletAddress(otherAddr)) != 1 {
+				t.Fatal("\t\tShould return block owned by the policy, got:", block.Owner)
+			}
+			t.Log("\t\tShould return block owned by the policy")
+		}
+		t.Log("\tGiven star co-owned by policy with owners spelled in lower case")
+		{
+			otherKey, _ := btcec.PrivKeyFromBytes([]byte("starchain test key #2 - 32 bytes"))
+			ethAddr := ethereum.PubKeyToAddress(otherKey.PubKey())
+			owners := []string{starAddr, strings.ToLower(ethAddr)}
+			challenge, _ := proxy.RequestMessageOwnershipVerification(contracts.ChallengeOwner{Owners: owners, Threshold: 2}, nil)
+			canonical, _ := multisig.New(2, []string{starAddr, ethAddr})
+			if challenge.Address != canonical.String() {
+				t.Fatal("\t\tShould issue challenge to the canonical policy, got:", challenge.Address)
+			}
+			t.Log("\t\tShould issue challenge to the canonical policy")
+			policy, _ := multisig.New(2, owners)
+			var star contracts.StarData
+			star.Address = policy.String()
+			star.Message = challenge.Message
+			star.Data = []byte("Our other Star")
+			star.Signatures = map[string]string{
+				starAddr:                 bitcoin.SignMessage(starKey, star.Message, true),
+				strings.ToLower(ethAddr): ethereum.SignMessage(otherKey, star.Message),
+			}
+			block, err := proxy.SubmitStar(star)
+			if err != nil || block.Owner != canonical.String() {
+				t.Fatal("\t\tShould return block owned by the canonical policy, got:", block.Owner, err)
+			}
+			t.Log("\t\tShould return block owned by the canonical policy")
+		}
 		t.Log("\tGiven wrong message")
 		{
 			var star contracts.StarData
@@ -227,9 +334,9 @@ func TestValidate(t *testing.T) {
 		{
 			t.Log("\t\tWhen no changes are made to the blockchain")
 			{
-				bchain := blockchain.New(clock)
+				bchain := blockchain.New(clock, blockchain.DefaultConfig())
 				proxy := New(bchain)
-				isValid, errs := proxy.Validate()
+				isValid, errs := proxy.Validate(contracts.ValidationOptions{})
 				if len(errs) > 0 {
 					t.Fatal("\t\t\tShould not return any errors, got:", errs)
 				}
@@ -242,11 +349,11 @@ func TestValidate(t *testing.T) {
 			t.Log("\t\tWhen new block is added")
 			{
 				starsData := []byte("Data 1")
-				bchain := blockchain.New(clock)
+				bchain := blockchain.New(clock, blockchain.DefaultConfig())
 				owner := "abcdef"
 				bchain.AddBlock(owner, starsData)
 				proxy := New(bchain)
-				isValid, errs := proxy.Validate()
+				isValid, errs := proxy.Validate(contracts.ValidationOptions{})
 				if len(errs) > 0 {
 					t.Fatal("\t\t\tShould not return any errors, got:", errs)
 				}
@@ -256,6 +363,127 @@ func TestValidate(t *testing.T) {
 				}
 				t.Log("\t\tShould be vaild")
 			}
+			t.Log("\t\tWhen block has no ownership proof")
+			{
+				bchain := blockchain.New(clock, blockchain.DefaultConfig())
+				bchain.AddBlock("abcdef", []byte("Data 1"))
+				proxy := New(bchain)
+				isValid, findings := proxy.Validate(contracts.ValidationOptions{VerifyProofs: true})
+				if isValid {
+					t.Fatal("\t\t\tShould be invalid, got:", isValid)
+				}
+				t.Log("\t\tShould be invalid")
+				if len(findings) != 1 || findings[0].Kind != blockchain.FindingBadProof || findings[0].Height != 1 || findings[0].Message == "" {
+					t.Fatal("\t\t\tShould return the finding, got:", findings)
+				}
+				t.Log("\t\tShould return the finding")
+			}
+		}
+	}
+}
+
+func decodeHash(t *testing.T, s string) [sha256.Size]byte {
+	var hash [sha256.Size]byte
+	buf, err := hex.DecodeString(s)
+	if err != nil || len(buf) != sha256.Size {
+		t.Fatal("\t\tShould return hex encoded hash, got: ", s)
+	}
+	copy(hash[:], buf)
+	return hash
+}
+
+func TestGetInclusionProof(t *testing.T) {
+	t.Log("GetInclusionProof")
+	{
+		t.Log("\tGiven chain with 3 blocks")
+		{
+			bchain := blockchain.New(clock, blockchain.DefaultConfig())
+			bchain.AddBlock("abcdef", []byte("Data 1"))
+			bchain.AddBlock("abcdef", []byte("Data 2"))
+			proxy := New(bchain)
+			result, err := proxy.GetInclusionProof(1)
+			if err != nil || result.Height != 1 || result.Size != 3 {
+				t.Fatal("\t\tShould return the proof, got: ", result, err)
+			}
+			block, _ := proxy.GetBlockByHeight(1)
+			if result.BlockHash != block.Hash {
+				t.Fatal("\t\tShould return hash of the block, got: ", result.BlockHash)
+			}
+			t.Log("\t\tShould return the proof")
+			proof := mmr.Proof{Index: uint64(result.Height), Size: result.Size}
+			for _, s := range result.Path {
+				proof.Path = append(proof.Path, decodeHash(t, s))
+			}
+			for _, s := range result.Peaks {
+				proof.Peaks = append(proof.Peaks, decodeHash(t, s))
+			}
+			if err := mmr.Verify(decodeHash(t, result.Root), decodeHash(t, result.BlockHash), proof); err != nil {
+				t.Fatal("\t\tShould verify the decoded proof, got err: ", err)
+			}
+			t.Log("\t\tShould verify the decoded proof")
+			if _, err := proxy.GetInclusionProof(3); err == nil {
+				t.Fatal("\t\tShould return error for missing block")
+			}
+			t.Log("\t\tShould return error for missing block")
+		}
+	}
+}
+
+func TestGetTreeHeadConsistency(t *testing.T) {
+	t.Log("GetTreeHeadConsistency")
+	{
+		t.Log("\tGiven tree heads published at heights 1 and 4")
+		{
+			bchain := blockchain.New(clock, blockchain.DefaultConfig())
+			bchain.AddBlock("abcdef", []byte("Data 1"))
+			bchain.PublishTreeHead()
+			for i := 2; i <= 4; i++ {
+				bchain.AddBlock("abcdef", []byte(fmt.Sprintf("Data %d", i)))
+			}
+			bchain.PublishTreeHead()
+			proxy := New(bchain)
+			if heads := proxy.GetTreeHeads(); len(heads) != 2 || heads[0].Height != 1 || heads[1].Height != 4 {
+				t.Fatal("\t\tShould return both tree heads, got: ", heads)
+			}
+			if head, err := proxy.GetLatestTreeHead(); err != nil || head.Height != 4 || len(head.Signature) != 2*ed25519.SignatureSize {
+				t.Fatal("\t\tShould return the latest tree head, got: ", head, err)
+			}
+			t.Log("\t\tShould return tree heads")
+			heads := proxy.GetTreeHeads()
+			result, err := proxy.GetTreeHeadConsistency(1, 4)
+			if err != nil || result.OldHeight != 1 || result.NewHeight != 4 || result.OldRoot != heads[0].Root || result.NewRoot != heads[1].Root {
+				t.Fatal("\t\tShould return the proof, got: ", result, err)
+			}
+			proof := mmr.ConsistencyProof{OldSize: 2, NewSize: 5}
+			for _, s := range result.OldPeaks {
+				proof.OldPeaks = append(proof.OldPeaks, decodeHash(t, s))
+			}
+			for _, path := range result.Paths {
+				var decoded [][sha256.Size]byte
+				for _, s := range path {
+					decoded = append(decoded, decodeHash(t, s))
+				}
+				proof.Paths = append(proof.Paths, decoded)
+			}
+			for _, s := range result.NewPeaks {
+				proof.NewPeaks = append(proof.NewPeaks, decodeHash(t, s))
+			}
+			if err := mmr.VerifyConsistency(decodeHash(t, result.OldRoot), decodeHash(t, result.NewRoot), proof); err != nil {
+				t.Fatal("\t\tShould verify the decoded proof, got err: ", err)
+			}
+			t.Log("\t\tShould verify the decoded proof")
+			headProof := mmr.Proof{Index: 4, Size: 5, Peaks: proof.NewPeaks}
+			for _, s := range result.NewHeadPath {
+				headProof.Path = append(headProof.Path, decodeHash(t, s))
+			}
+			if err := mmr.Verify(decodeHash(t, result.NewRoot), decodeHash(t, heads[1].HeadHash), headProof); err != nil {
+				t.Fatal("\t\tShould verify the head hash is the last leaf of the root, got err: ", err)
+			}
+			t.Log("\t\tShould verify the head hash is the last leaf of the root")
+			if _, err := proxy.GetTreeHeadConsistency(1, 5); err == nil {
+				t.Fatal("\t\tShould return error for height above the chain")
+			}
+			t.Log("\t\tShould return error for height above the chain")
 		}
 	}
 }
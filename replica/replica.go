@@ -0,0 +1,155 @@
+// replica package provides Follower, which keeps a local Blockchain
+// in sync with a leader node. The follower polls /blocks/since/:height
+// of the leader, every received block is verified against the local
+// chain before it is appended, so a leader serving a rewritten or forged
+// chain is refused. Writes are served by the leader only.
+package replica
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/starchain/api"
+	"github.com/starchain/block"
+	"github.com/starchain/blockchain"
+	"github.com/starchain/contracts"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultPollInterval is the number of seconds between polls of the leader
+const DefaultPollInterval int64 = 5
+
+var (
+	LeaderErr      = errors.New("Leader did not return blocks")
+	LeaderEmptyErr = errors.New("Leader returned no genesis block")
+)
+
+// Follower struct polls the leader for new blocks and appends them
+// to the chain
+type Follower struct {
+	leader string
+	chain  *blockchain.Blockchain
+	client *http.Client
+}
+
+// Factory function returning Follower of the leader URL appending to
+// the chain, http.DefaultClient is used when client is nil
+func New(leader string, chain *blockchain.Blockchain, client *http.Client) *Follower {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Follower{leader: strings.TrimSuffix(leader, "/"), chain: chain, client: client}
+}
+
+// Open fn returns Blockchain persisted in the store which follows the
+// leader. Empty store is seeded with the genesis block of the leader
+// first, so the chain starts with the same block. The leader producer
+// key has to be trusted by the config.
+func Open(ctx context.Context, leader string, client *http.Client, clock contracts.Clock, config blockchain.Config, store blockchain.Store) (*Follower, error) {
+	stored, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	follower := New(leader, nil, client)
+	if len(stored) == 0 {
+		blocks, _, err := follower.fetch(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(blocks) == 0 {
+			return nil, LeaderEmptyErr
+		}
+		if err := blockchain.Seed(store, blocks[0], config); err != nil {
+			return nil, err
+		}
+	}
+	if follower.chain, err = blockchain.Open(clock, config, store); err != nil {
+		return nil, err
+	}
+	return follower, nil
+}
+
+// Blockchain method returns the chain the follower appends to
+func (f *Follower) Blockchain() *blockchain.Blockchain {
+	return f.chain
+}
+
+// Leader method returns URL of the leader
+func (f *Follower) Leader() string {
+	return f.leader
+}
+
+// Sync method fetches blocks from the leader until the chain catches up
+// and returns the number of blocks appended
+func (f *Follower) Sync(ctx context.Context) (int, error) {
+	synced := 0
+	for {
+		blocks, leaderHeight, err := f.fetch(ctx, f.chain.GetChainHeight())
+		if err != nil {
+			return synced, err
+		}
+		appended, err := f.chain.AppendBlocks(blocks)
+		synced += appended
+		if err != nil {
+			return synced, err
+		}
+		if appended == 0 || f.chain.GetChainHeight() >= leaderHeight {
+			return synced, nil
+		}
+	}
+}
+
+// Run method syncs the chain every interval seconds until ctx is done,
+// failed syncs are logged and retried at the next poll
+func (f *Follower) Run(ctx context.Context, interval int64) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		if synced, err := f.Sync(ctx); err != nil {
+			log.Println("ERR: sync with leader failed: ", err)
+		} else if synced > 0 {
+			log.Printf("INFO: synced %d blocks from leader, chain height %d", synced, f.chain.GetChainHeight())
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fetch method returns blocks of the leader from the height on
+// together with the leader chain height
+func (f *Follower) fetch(ctx context.Context, height int) ([]*block.Block, int, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/blocks/since/%d", f.leader, height), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, 0, errors.New(fmt.Sprintf("%s: status %s", LeaderErr, res.Status))
+	}
+	var blockRange api.BlockRangeDto
+	if err := json.NewDecoder(res.Body).Decode(&blockRange); err != nil {
+		return nil, 0, errors.New(fmt.Sprintf("%s: %s", LeaderErr, err))
+	}
+	blocks := make([]*block.Block, len(blockRange.Blocks))
+	for i, encoded := range blockRange.Blocks {
+		blocks[i] = new(block.Block)
+		if err := json.Unmarshal(encoded, blocks[i]); err != nil {
+			return nil, 0, errors.New(fmt.Sprintf("%s: block at height %d: %s", LeaderErr, height+i, err))
+		}
+	}
+	return blocks, blockRange.ChainHeight, nil
+}
@@ -0,0 +1,160 @@
+package replica
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"github.com/starchain/api"
+	"github.com/starchain/blockchain"
+	"github.com/starchain/contracts"
+	"github.com/starchain/proxy"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type BlockchainClockMock struct{}
+
+func (b BlockchainClockMock) GetTime() int64 {
+	return time.Date(2020, time.June, 14, 17, 46, 32, 0, time.UTC).Unix()
+}
+
+var leaderKey = ed25519.NewKeyFromSeed([]byte("starchain leader key - 32 bytes!"))
+
+// serve fn returns the chain served by the REST API over httptest
+func serve(chain *blockchain.Blockchain) *httptest.Server {
+	var operator contracts.BlockchainOperator = proxy.New(chain)
+	return httptest.NewServer(api.Create(&operator, ""))
+}
+
+// addBlocks fn adds n star blocks with data prefixed by the prefix
+func addBlocks(chain *blockchain.Blockchain, prefix string, n int) {
+	for i := 0; i < n; i++ {
+		chain.AddBlock("1FzpnkhbAteDkU1wXDtd8kKizQhqWcsrWe", []byte(fmt.Sprintf("%s %d", prefix, i)))
+	}
+}
+
+// sameChain fn reports whether both chains hold the same blocks
+func sameChain(a *blockchain.Blockchain, b *blockchain.Blockchain) bool {
+	if a.GetChainHeight() != b.GetChainHeight() {
+		return false
+	}
+	for h := 0; h < a.GetChainHeight(); h++ {
+		blockA, _ := a.GetBlockByHeight(h)
+		blockB, _ := b.GetBlockByHeight(h)
+		if blockA.GetHash() != blockB.GetHash() {
+			return false
+		}
+	}
+	return true
+}
+
+func followerConfig() blockchain.Config {
+	return blockchain.Config{TrustedKeys: []ed25519.PublicKey{leaderKey.Public().(ed25519.PublicKey)}}
+}
+
+func TestFollower(t *testing.T) {
+	t.Log("Follower")
+	{
+		clock := BlockchainClockMock{}
+		ctx := context.Background()
+		leader := blockchain.New(clock, blockchain.Config{SigningKey: leaderKey})
+		addBlocks(leader, "Star", 5)
+		leaderServer := serve(leader)
+		defer leaderServer.Close()
+		t.Log("\tGiven follower with empty store")
+		{
+			follower, err := Open(ctx, leaderServer.URL, nil, clock, followerConfig(), blockchain.NewMemoryStore())
+			if err != nil {
+				t.Fatal("\t\tShould open the follower, got err: ", err)
+			}
+			genesis, _ := leader.GetBlockByHeight(0)
+			if head, _ := follower.Blockchain().GetBlockByHeight(0); follower.Blockchain().GetChainHeight() != 1 || head.GetHash() != genesis.GetHash() {
+				t.Fatal("\t\tShould start with the genesis block of the leader")
+			}
+			t.Log("\t\tShould start with the genesis block of the leader")
+			if synced, err := follower.Sync(ctx); err != nil || synced != 5 || !sameChain(leader, follower.Blockchain()) {
+				t.Fatal("\t\tShould sync blocks of the leader, got: ", synced, err)
+			}
+			t.Log("\t\tShould sync blocks of the leader")
+			addBlocks(leader, "More", 2*blockchain.MaxBlocksPerRange+10)
+			if synced, err := follower.Sync(ctx); err != nil || synced != 2*blockchain.MaxBlocksPerRange+10 || !sameChain(leader, follower.Blockchain()) {
+				t.Fatal("\t\tShould sync several ranges of blocks, got: ", synced, err)
+			}
+			t.Log("\t\tShould sync several ranges of blocks")
+			if synced, err := follower.Sync(ctx); err != nil || synced != 0 {
+				t.Fatal("\t\tShould sync nothing when caught up, got: ", synced, err)
+			}
+			t.Log("\t\tShould sync nothing when caught up")
+			if errs := follower.Blockchain().ValidateChain(); len(errs) != 0 {
+				t.Fatal("\t\tShould keep the replicated chain valid, got: ", errs)
+			}
+			t.Log("\t\tShould keep the replicated chain valid")
+
+			rogue := blockchain.New(clock, blockchain.Config{SigningKey: leaderKey})
+			addBlocks(rogue, "Rewritten", follower.Blockchain().GetChainHeight()+3)
+			rogueServer := serve(rogue)
+			defer rogueServer.Close()
+			height := follower.Blockchain().GetChainHeight()
+			if _, err := New(rogueServer.URL, follower.Blockchain(), nil).Sync(ctx); err == nil || !strings.HasPrefix(err.Error(), blockchain.ReplicationErr.Error()) {
+				t.Fatal("\t\tShould reject blocks of a rewritten chain, got: ", err)
+			}
+			if follower.Blockchain().GetChainHeight() != height {
+				t.Fatal("\t\tShould not append blocks of a rewritten chain")
+			}
+			t.Log("\t\tShould reject blocks of a rewritten chain")
+		}
+		t.Log("\tGiven follower which does not trust the leader key")
+		{
+			store := blockchain.NewMemoryStore()
+			if _, err := Open(ctx, leaderServer.URL, nil, clock, blockchain.Config{}, store); err == nil {
+				t.Fatal("\t\tShould refuse the chain of the leader")
+			}
+			if stored, _ := store.Load(); len(stored) != 0 {
+				t.Fatal("\t\tShould not write the genesis block of the leader")
+			}
+			t.Log("\t\tShould refuse the chain of the leader")
+		}
+		t.Log("\tGiven follower serving the REST API")
+		{
+			follower, err := Open(ctx, leaderServer.URL, nil, clock, followerConfig(), blockchain.NewMemoryStore())
+			if err != nil {
+				t.Fatal("\t\tShould open the follower, got err: ", err)
+			}
+			runCtx, cancel := context.WithCancel(ctx)
+			done := make(chan struct{})
+			go func() {
+				follower.Run(runCtx, 60)
+				close(done)
+			}()
+			deadline := time.Now().Add(5 * time.Second)
+			for !sameChain(leader, follower.Blockchain()) && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			cancel()
+			<-done
+			if !sameChain(leader, follower.Blockchain()) {
+				t.Fatal("\t\tShould sync in background")
+			}
+			t.Log("\t\tShould sync in background")
+			var operator contracts.BlockchainOperator = proxy.New(follower.Blockchain())
+			followerServer := httptest.NewServer(api.CreateFollower(&operator, follower.Leader(), ""))
+			defer followerServer.Close()
+			client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}}
+			response, err := client.Get(followerServer.URL + "/block/3")
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatal("\t\tShould serve reads, got: ", response, err)
+			}
+			t.Log("\t\tShould serve reads")
+			response, err = client.Post(followerServer.URL+"/submitstar", "application/json", strings.NewReader("{}"))
+			if err != nil || response.StatusCode != http.StatusTemporaryRedirect || response.Header.Get("Location") != leaderServer.URL+"/submitstar" {
+				t.Fatal("\t\tShould redirect writes to the leader, got: ", response, err)
+			}
+			t.Log("\t\tShould redirect writes to the leader")
+		}
+	}
+}
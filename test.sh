@@ -10,15 +10,18 @@ echo
 # TEST 2. Make request of ownership
 curl -s -X POST \
   -H 'Content-Type: application/json' \
-  -d '{"address":"1CAvNmCrxSRympnSoVxYKLuXdDthyB74xu"}' \
+  -d '{"address":"1CAvNmCrxSRympnSoVxYKLuXdDthyB74xu","star":{"dec":"68° 52'"'"' 56.9","ra":"16h 29m 1.0s"}}' \
   localhost:8000/requestValidation
 echo
 
 # TEST 3. Submit your Star
+# Message has to be the one returned by TEST 2, signed by the address owner, e.g.:
+#   ./starchain keys generate alice
+#   curl -s -X POST -d '{"address":"<alice address>"}' localhost:8000/requestValidation | jq -r .message | ./starchain keys sign alice
 curl -s -X POST -H 'Content-Type: application/json' localhost:8000/submitStar -d @- <<\EOF | jq
   { "address": "1CAvNmCrxSRympnSoVxYKLuXdDthyB74xu",
     "signature": "H8MtnshsXv4Aw1VVGZKAyhKLyya9ebYyMnLgTW13B7aAILqQNiaHox28vsLok39Zf36msVEFWQoAj7stPSJ6yIQ=",
-    "message": "1CAvNmCrxSRympnSoVxYKLuXdDthyB74xu:1590921500:starRegistry",
+    "message": "starchain.local wants you to prove ownership of the address:\n1CAvNmCrxSRympnSoVxYKLuXdDthyB74xu\n\nRegister a star in the Starchain registry.\n\nVersion: 1\nChain ID: starchain-1\nNonce: <nonce>\nIssued At: <issuedAt>\nExpiration Time: <expirationTime>\nStar RA: 16h 29m 1.0s\nStar Dec: 68° 52' 56.9",
     "star": {
       "dec": "68° 52' 56.9",
       "ra": "16h 29m 1.0s",